@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"muzz-project/metrics"
+	"muzz-project/storage/mysql"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// registry holds every metric served on the admin port, added to as the databases are opened
+var registry = metrics.NewRegistry()
+
+// serveAdmin serves the metrics at /metrics on the adminPort setting, and returns the server so it can be closed on
+// shutdown, or nil when the port is 0
+func serveAdmin() *http.Server {
+	if cfg.AdminPort == 0 {
+		return nil
+	}
+	lis, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.AdminPort)))
+	if err != nil {
+		fatal("failed to listen on the admin port", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(registry))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin server failed", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", lis.Addr()))
+	return server
+}
+
+// registerDBStats exports db's connection pool statistics, labelled with name
+func registerDBStats(name string, db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// registerRetryStats exports how many mysql operations r has retried, by the failure retried after
+func registerRetryStats(r *mysql.Retrier) {
+	for reason, count := range map[string]func(mysql.RetryStats) uint64{
+		"deadlock":          func(s mysql.RetryStats) uint64 { return s.Deadlocks },
+		"lock_wait_timeout": func(s mysql.RetryStats) uint64 { return s.LockWaitTimeouts },
+		"connection_error":  func(s mysql.RetryStats) uint64 { return s.ConnectionErrors },
+	} {
+		registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   metrics.Namespace,
+			Subsystem:   "mysql",
+			Name:        "retries_total",
+			Help:        "MySQL operations retried after a transient failure, by the failure.",
+			ConstLabels: prometheus.Labels{"reason": reason},
+		}, func() float64 { return float64(count(r.Stats())) }))
+	}
+	registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "mysql",
+		Name:      "retries_exhausted_total",
+		Help:      "MySQL operations that failed with a transient error and weren't retried again.",
+	}, func() float64 { return float64(r.Stats().GaveUp) }))
+}
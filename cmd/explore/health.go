@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"muzz-project/readiness"
+	"time"
+)
+
+// pingTimeout is how long each attempt at reaching a database gets, so one that drops packets doesn't use up the
+// whole startup timeout
+const pingTimeout = 5 * time.Second
+
+// dependencies are checked for readiness while serving, added as the databases are opened
+var dependencies []readiness.Check
+
+// waitForDB waits for db to accept connections, retrying with backoff for up to the startupTimeout setting so a server
+// started alongside its database doesn't crash, then adds it to the readiness checks and exports its pool statistics
+func waitForDB(name string, db *sql.DB) {
+	ping := func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		return db.PingContext(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.StartupTimeout)
+	defer cancel()
+	if err := readiness.WaitFor(ctx, name, ping, 100*time.Millisecond, 5*time.Second); err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	slog.Info("connected", "database", name)
+
+	dependencies = append(dependencies, readiness.Check{Name: name, Run: ping})
+	registerDBStats(name, db)
+}
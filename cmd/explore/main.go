@@ -1,63 +1,348 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"muzz-project/config"
+	"muzz-project/logging"
+	"muzz-project/metrics"
+	"muzz-project/readiness"
+	"muzz-project/score"
+	"muzz-project/storage"
+	"muzz-project/storage/cache"
+	"muzz-project/storage/instrumented"
 	"muzz-project/storage/mysql"
+	"muzz-project/storage/postgres"
+	"muzz-project/storage/replica"
+	"muzz-project/storage/sqlite"
+	"muzz-project/tracing"
 	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	// Quotas reset at midnight in each user's time zone, and the container doesn't ship a zoneinfo database
+	_ "time/tzdata"
 
 	"muzz-project/service"
 	"muzz-project/service/protos"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-var (
-	port        string
-	host        string
-	database    string
-	password    string
-	user        string
-	maxPageSize int
-)
+const scoreBatchSize = 1000
 
-func init() {
-	flag.StringVar(&port, "port", "8080", "port to listen on")
-	flag.StringVar(&host, "host", "0.0.0.0", "host to listen on")
-	flag.StringVar(&database, "db", "testdb", "database name")
-	flag.StringVar(&password, "password", "rootpassword", "database password")
-	flag.StringVar(&user, "user", "root", "database user")
-	flag.IntVar(&maxPageSize, "maxPageSize", 1000, "maximum number of db rows to be returned in one query")
-}
+var cfg *config.Config
 
 func main() {
-	flag.Parse()
-
+	var args []string
 	var err error
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?parseTime=true", user, password, "db", database)
-	db, err := sql.Open("mysql", dsn)
-	log.Printf("connected to db")
+	cfg, args, err = config.Load("explore", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+		log.Fatalf("Invalid config: %v", err)
 	}
-	defer db.Close()
+	slog.SetDefault(logging.New(os.Stderr, cfg.LogFormat, cfg.Level()))
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			fmt.Print(cfg)
+		case "migrate":
+			runMigrate(args[1:])
+		case "repair-counters":
+			runRepairCounters()
+		case "rebalance":
+			runRebalance(args[1:])
+		case "backfill":
+			runBackfill(args[1:])
+		default:
+			log.Fatalf("Unknown command %q, must be config, migrate, repair-counters, rebalance or backfill", args[0])
+		}
+		return
+	}
+	slog.Info("starting", "config", cfg)
+	stopTracing := setUpTracing()
+
+	s, closeStorage := openStorage()
+	//Wrapped beneath the cache so cache hits aren't counted or traced as queries
+	s = instrumented.NewInstrumentedStorage(s, registry, instrumented.WithTracerProvider(tracerProvider))
 
-	s := mysql.NewMysqlStorage(db, maxPageSize)
+	if cfg.CacheSize > 0 {
+		cached := cache.NewCachedStorage(s, cache.NewLRU(cfg.CacheSize), cache.WithCountTTL(cfg.CacheTTL), cache.WithLikesTTL(cfg.CacheTTL))
+		background.Go(func(ctx context.Context) { logCacheStats(ctx, cached, time.Minute) })
+		s = cached
+	}
 
-	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%s", host, port))
+	lis, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		fatal("failed to listen", err)
+	}
+
+	if cfg.ScoreInterval > 0 {
+		background.Go(func(ctx context.Context) { score.NewJob(s, scoreBatchSize).Run(ctx, cfg.ScoreInterval) })
+	}
+
+	serverMetrics := metrics.NewServerMetrics(registry)
+	rpcLogger := logging.NewInterceptor(slog.Default(), logHashKey())
+	rpcTracer := tracing.NewInterceptor(tracerProvider)
+	//Tracing comes first so the RPC's log lines carry its trace ID
+	interceptors := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(rpcTracer.UnaryInterceptor(), rpcLogger.UnaryInterceptor(), serverMetrics.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(rpcTracer.StreamInterceptor(), rpcLogger.StreamInterceptor(), serverMetrics.StreamInterceptor()),
+	}
+	healthServer := health.NewServer()
+	grpcServer, internalServer := newServers(s, healthServer, interceptors...)
+	services := []string{"", protos.ExploreService_ServiceDesc.ServiceName}
+	if internalServer != nil {
+		services = append(services, protos.ExploreInternalService_ServiceDesc.ServiceName)
+	}
+	checker := readiness.NewChecker(healthServer, services, dependencies, cfg.HealthInterval)
+	background.Go(checker.Run)
+
+	admin := serveAdmin()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	served := make(chan error, 2)
+	go func() {
+		served <- grpcServer.Serve(lis)
+	}()
+	slog.Info("server listening", "address", lis.Addr().String())
+	servers := []*grpc.Server{grpcServer}
+	if internalServer != nil {
+		internalLis, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.InternalPort)))
+		if err != nil {
+			fatal("failed to listen on the internal port", err)
+		}
+		go func() {
+			served <- internalServer.Serve(internalLis)
+		}()
+		slog.Info("internal server listening", "address", internalLis.Addr().String())
+		servers = append(servers, internalServer)
+	}
+
+	select {
+	case err := <-served:
+		fatal("failed to serve", err)
+	case <-ctx.Done():
+	}
+	//A second signal kills the server straight away
+	stop()
+	slog.Info("shutting down")
+	shutdown(healthServer, servers...)
+	closeStorage()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	stopTracing(flushCtx)
+	cancel()
+	//Metrics are served until the end so the drain can be watched
+	if admin != nil {
+		admin.Close()
+	}
+	slog.Info("shut down")
+}
+
+// newServers returns the server for clients, serving the explore service, and the internal server for other services,
+// serving the internal service, or nil when the internalPort setting is 0. The internal service exposes data clients
+// must never see, so it's never served to clients. Both servers serve health checks from healthServer.
+func newServers(s storage.Storage, healthServer *health.Server, opts ...grpc.ServerOption) (*grpc.Server, *grpc.Server) {
+	public := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(public, healthServer)
+	protos.RegisterExploreServiceServer(public, service.NewExploreService(s, cfg.MaxPageSize,
+		service.WithLocationPrecision(cfg.LocationPrecision),
+		service.WithDailyLikeLimit(cfg.DailyLikeLimit),
+	))
+	if cfg.InternalPort == 0 {
+		return public, nil
 	}
 
-	grpcServer := grpc.NewServer()
+	internal := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(internal, healthServer)
+	protos.RegisterExploreInternalServiceServer(internal, service.NewExploreInternalService(s))
+	return public, internal
+}
 
-	protos.RegisterExploreServiceServer(grpcServer, service.NewExploreService(s, maxPageSize))
-	log.Printf("server listening at %s", lis.Addr())
-	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+// logCacheStats logs the cache's hit rate every interval, and once more when ctx is cancelled
+func logCacheStats(ctx context.Context, c *cache.CachedStorage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		stats := c.Stats()
+		slog.Info("cache stats", "hits", stats.Hits, "misses", stats.Misses, "evictions", stats.Evictions, "errors", stats.Errors)
+		if ctx.Err() != nil {
+			return
+		}
 	}
+}
 
+// newRetrier returns a retrier for mysql operations, using the retryAttempts setting
+func newRetrier() *mysql.Retrier {
+	policy := mysql.DefaultRetryPolicy
+	policy.MaxAttempts = cfg.RetryAttempts
+	return mysql.NewRetrier(policy)
+}
+
+// logRetryStats logs how many mysql operations have been retried every interval, and once more when ctx is cancelled
+func logRetryStats(ctx context.Context, r *mysql.Retrier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		stats := r.Stats()
+		slog.Info("mysql retries", "deadlocks", stats.Deadlocks, "lock_wait_timeouts", stats.LockWaitTimeouts, "connection_errors", stats.ConnectionErrors, "gave_up", stats.GaveUp)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// openStorage connects to the storage chosen by the driver setting, and returns it with a function closing it
+func openStorage() (storage.Storage, func()) {
+	switch cfg.Driver {
+	case "mysql":
+		db, err := openDB("mysql", cfg.MySQLDSN())
+		if err != nil {
+			fatal("failed to connect to the database", err)
+		}
+		waitForDB("mysql", db)
+
+		m := newMigrator(db)
+		if cfg.AutoMigrate {
+			migrateUp("mysql", m)
+		}
+		//Without -autoMigrate the server isn't ready until somebody migrates
+		dependencies = append(dependencies, readiness.Check{Name: "schema", Run: m.Check})
+
+		//Shared so retries are counted across the primary, replicas and shards
+		retrier := newRetrier()
+		background.Go(func(ctx context.Context) { logRetryStats(ctx, retrier, time.Minute) })
+		registerRetryStats(retrier)
+
+		if cfg.ShardMap != "" {
+			s, shardDBs := openSharded(db, retrier)
+			return s, closeAll(append(shardDBs, db))
+		}
+
+		replicaDBs := openReplicas("mysql")
+		var replicas []storage.Storage
+		for _, replicaDB := range replicaDBs {
+			replicas = append(replicas, mysql.NewMysqlStorage(replicaDB, cfg.MaxPageSize, mysql.WithRetrier(retrier), mysql.WithTracerProvider(tracerProvider)))
+		}
+
+		s := replica.NewReplicatedStorage(mysql.NewMysqlStorage(db, cfg.MaxPageSize, mysql.WithRetrier(retrier), mysql.WithTracerProvider(tracerProvider)), replicas,
+			replica.WithPinWindow(cfg.PinWindow),
+			replica.WithPositions(mysql.NewGTIDPositions(db, replicaDBs)),
+		)
+		return s, closeAll(append(replicaDBs, db))
+	case "postgres":
+		db, err := openDB("pgx", cfg.PostgresDSN())
+		if err != nil {
+			fatal("failed to connect to the database", err)
+		}
+		waitForDB("postgres", db)
+
+		replicaDBs := openReplicas("pgx")
+		var replicas []storage.Storage
+		for _, replicaDB := range replicaDBs {
+			replicas = append(replicas, postgres.NewPostgresStorage(replicaDB, cfg.MaxPageSize))
+		}
+
+		s := replica.NewReplicatedStorage(postgres.NewPostgresStorage(db, cfg.MaxPageSize), replicas, replica.WithPinWindow(cfg.PinWindow))
+		return s, closeAll(append(replicaDBs, db))
+	case "sqlite":
+		path := cfg.DSN
+		if path == "" {
+			path = "explore.db"
+		}
+		//SQLite sets up its own single connection, so the pool settings aren't used
+		db, err := sqlite.Open(path)
+		if err != nil {
+			fatal("failed to open the database", err)
+		}
+		slog.Info("opened the database", "path", path)
+		dependencies = append(dependencies, readiness.Check{Name: "sqlite", Run: db.PingContext})
+		registerDBStats("sqlite", db)
+
+		return sqlite.NewSqliteStorage(db, cfg.MaxPageSize), func() { db.Close() }
+	default:
+		m, err := newDummyMemoryStorage(cfg.MaxPageSize)
+		if err != nil {
+			fatal("failed to set up in-memory storage", err)
+		}
+		return m, func() {}
+	}
+}
+
+// openDB opens a database with the pool settings
+func openDB(driverName string, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// openReplicas connects to each of the replicas in the replicaDsns setting
+func openReplicas(driverName string) []*sql.DB {
+	var dbs []*sql.DB
+	for i, replicaDSN := range cfg.Replicas() {
+		db, err := openDB(driverName, replicaDSN)
+		if err != nil {
+			fatal("failed to connect to replica", err)
+		}
+		waitForDB(fmt.Sprintf("replica %d", i+1), db)
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+// logHashKey returns the logHashKey setting, or a random key if it isn't set
+func logHashKey() []byte {
+	if cfg.LogHashKey != "" {
+		return []byte(cfg.LogHashKey)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		fatal("failed to generate a key to hash user IDs with", err)
+	}
+	return key
+}
+
+// fatal logs msg and err then exits
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
+
+func closeAll(dbs []*sql.DB) func() {
+	return func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}
 }
@@ -7,11 +7,20 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go/wait"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
 	"log"
+	"muzz-project/config"
+	"muzz-project/recommend"
 	"muzz-project/service"
 	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"muzz-project/storage/memory"
 	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/migrate"
+	"muzz-project/storage/storagetest"
 	"net"
 	"os"
 	"testing"
@@ -21,7 +30,9 @@ import (
 )
 
 var (
-	connectionStringVar string
+	connectionStringVar     string
+	rootConnectionStringVar string
+	conformanceDatabases    int
 )
 
 func TestMain(m *testing.M) {
@@ -81,22 +92,17 @@ func setupMySQL(ctx context.Context) (testcontainers.Container, string) {
 	host, _ := container.Host(ctx)
 	dbConnStr := fmt.Sprintf("testuser:testpassword@tcp(%s:%s)/testdb?parseTime=true", host, portObj.Port())
 	connectionStringVar = dbConnStr
+	rootConnectionStringVar = fmt.Sprintf("root:root@tcp(%s:%s)/", host, portObj.Port())
 
 	return container, dbConnStr
 }
 
 func initialiseDB(db *sql.DB) {
-	_, err := db.Exec(CreateUserTable)
-	if err != nil {
-		log.Fatalf("Failed to create user table: %v", err)
-	}
-
-	_, err = db.Exec(CreateDecisionsTable)
-	if err != nil {
-		log.Fatalf("Failed to create decisions table: %v", err)
+	if err := migrateDB(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
-	_, err = db.Exec(AddDummyUserData)
+	_, err := db.Exec(AddDummyUserData)
 	if err != nil {
 		log.Fatalf("Failed to add user data: %v", err)
 	}
@@ -108,7 +114,16 @@ func initialiseDB(db *sql.DB) {
 
 }
 
-func startServer(port string) {
+func migrateDB(db *sql.DB) error {
+	m, err := migrate.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background())
+	return err
+}
+
+func startServer(port string, opts ...service.Option) {
 	db, err := sql.Open("mysql", connectionStringVar)
 	if err != nil {
 		log.Fatalf("Failed to connect to MySQL: %v", err)
@@ -116,7 +131,7 @@ func startServer(port string) {
 
 	defer db.Close()
 
-	s := mysql.NewMysqlStorage(db, maxPageSize)
+	s := mysql.NewMysqlStorage(db, config.Default().MaxPageSize)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
@@ -125,7 +140,7 @@ func startServer(port string) {
 
 	grpcServer := grpc.NewServer()
 
-	protos.RegisterExploreServiceServer(grpcServer, service.NewExploreService(s, maxPageSize))
+	protos.RegisterExploreServiceServer(grpcServer, service.NewExploreService(s, config.Default().MaxPageSize, opts...))
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
@@ -341,6 +356,287 @@ func TestPutDecision_NoLike(t *testing.T) {
 
 }
 
+func TestListCandidates_MaxDistance(t *testing.T) {
+	ctx := context.Background()
+	port := "50057"
+
+	go startServer(port)
+
+	time.Sleep(5 * time.Second)
+
+	client, conn, err := getClientAndConnection(port, time.Second*10)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	locations := map[string][2]float64{
+		"1": {51.5074, -0.1278}, // London
+		"6": {51.5500, -0.1000}, // ~5km away
+		"7": {48.8566, 2.3522},  // Paris
+	}
+	for userId, location := range locations {
+		_, err := client.UpdateLocation(ctx, &protos.UpdateLocationRequest{
+			UserId:    userId,
+			Latitude:  location[0],
+			Longitude: location[1],
+		})
+		assert.NoError(t, err)
+	}
+
+	nearby, err := client.ListCandidates(ctx, &protos.ListCandidatesRequest{
+		UserId:        "1",
+		MaxDistanceKm: uint32Ptr(50),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, nearby.GetCandidates(), 1)
+	assert.Equal(t, "6", nearby.GetCandidates()[0].GetUserId())
+	assert.Equal(t, uint32(5), nearby.GetCandidates()[0].GetDistanceKm())
+
+	//user 1 has already made a decision on users 2-5, users without a location come last
+	all, err := client.ListCandidates(ctx, &protos.ListCandidatesRequest{
+		UserId: "1",
+	})
+	assert.NoError(t, err)
+	var userIds []string
+	for _, c := range all.GetCandidates() {
+		userIds = append(userIds, c.GetUserId())
+	}
+	assert.Equal(t, []string{"6", "7", "8", "9", "10"}, userIds)
+}
+
+// Runs after TestListCandidates_MaxDistance as setting profiles changes who user 1 can see
+func TestPreferences_FilterCandidatesAndLikes(t *testing.T) {
+	ctx := context.Background()
+	port := "50058"
+
+	go startServer(port)
+
+	time.Sleep(5 * time.Second)
+
+	client, conn, err := getClientAndConnection(port, time.Second*10)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	profiles := map[string]*protos.UpdateProfileRequest{
+		"9": {Birthdate: "1990-01-01", Gender: protos.Gender_GENDER_FEMALE},
+		"2": {Birthdate: "1992-01-01", Gender: protos.Gender_GENDER_MALE},
+		"3": {Birthdate: "1988-01-01", Gender: protos.Gender_GENDER_MALE},
+		"4": {Birthdate: "1992-01-01", Gender: protos.Gender_GENDER_FEMALE},
+		"5": {Birthdate: "1994-01-01", Gender: protos.Gender_GENDER_MALE},
+		"7": {Birthdate: "1960-01-01", Gender: protos.Gender_GENDER_MALE},
+	}
+	for userId, profile := range profiles {
+		profile.UserId = userId
+		_, err := client.UpdateProfile(ctx, profile)
+		assert.NoError(t, err)
+	}
+
+	_, err = client.UpdatePreferences(ctx, &protos.UpdatePreferencesRequest{
+		UserId: "9",
+		Preferences: &protos.Preferences{
+			MinAge:  uint32Ptr(25),
+			MaxAge:  uint32Ptr(45),
+			Genders: []protos.Gender{protos.Gender_GENDER_MALE},
+		},
+	})
+	assert.NoError(t, err)
+
+	//user 5 only wants to see men so shouldn't be shown to user 9
+	_, err = client.UpdatePreferences(ctx, &protos.UpdatePreferencesRequest{
+		UserId: "5",
+		Preferences: &protos.Preferences{
+			Genders: []protos.Gender{protos.Gender_GENDER_MALE},
+		},
+	})
+	assert.NoError(t, err)
+
+	preferences, err := client.GetPreferences(ctx, &protos.GetPreferencesRequest{UserId: "9"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(25), preferences.GetPreferences().GetMinAge())
+	assert.Equal(t, []protos.Gender{protos.Gender_GENDER_MALE}, preferences.GetPreferences().GetGenders())
+
+	candidates, err := client.ListCandidates(ctx, &protos.ListCandidatesRequest{UserId: "9"})
+	assert.NoError(t, err)
+	var candidateIds []string
+	for _, c := range candidates.GetCandidates() {
+		candidateIds = append(candidateIds, c.GetUserId())
+	}
+	assert.Equal(t, []string{"2", "3"}, candidateIds)
+
+	//users 3, 6 and 9 like user 9 but only user 3 fits their preferences
+	likes, err := client.ListLikedYou(ctx, &protos.ListLikedYouRequest{
+		RecipientUserId:    "9",
+		MatchesPreferences: boolPtr(true),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, likes.GetLikers(), 1)
+	assert.Equal(t, "3", likes.GetLikers()[0].GetActorId())
+}
+
+func TestPutDecision_DailyLikeLimit(t *testing.T) {
+	ctx := context.Background()
+	port := "50059"
+
+	go startServer(port, service.WithDailyLikeLimit(1))
+
+	time.Sleep(5 * time.Second)
+
+	client, conn, err := getClientAndConnection(port, time.Second*10)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = client.PutDecision(ctx, &protos.PutDecisionRequest{
+		ActorUserId:     "8",
+		RecipientUserId: "2",
+		LikedRecipient:  true,
+	})
+	assert.NoError(t, err)
+
+	quota, err := client.GetQuota(ctx, &protos.GetQuotaRequest{UserId: "8"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), quota.GetDailyLimit())
+	assert.Equal(t, uint32(0), quota.GetLikesRemaining())
+	assert.Greater(t, quota.GetResetUnixTimestamp(), uint64(time.Now().Unix()))
+
+	//passes are always allowed
+	_, err = client.PutDecision(ctx, &protos.PutDecisionRequest{
+		ActorUserId:     "8",
+		RecipientUserId: "3",
+		LikedRecipient:  false,
+	})
+	assert.NoError(t, err)
+
+	_, err = client.PutDecision(ctx, &protos.PutDecisionRequest{
+		ActorUserId:     "8",
+		RecipientUserId: "4",
+		LikedRecipient:  true,
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestListCandidates_Recommendations(t *testing.T) {
+	ctx := context.Background()
+	port := "50060"
+
+	db, err := sql.Open("mysql", connectionStringVar)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	s := mysql.NewMysqlStorage(db, 1000)
+
+	saved, err := recommend.NewJob(s, 10, 50, 10).Run(ctx)
+	assert.NoError(t, err)
+	assert.Greater(t, saved, 0)
+
+	err = s.SaveRecommendations(ctx, 2, []*storage.Recommendation{
+		{CandidateID: 9, Score: 2},
+		{CandidateID: 3, Score: 1},
+	})
+	assert.NoError(t, err)
+
+	go startServer(port)
+
+	time.Sleep(5 * time.Second)
+
+	client, conn, err := getClientAndConnection(port, time.Second*10)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	candidates, err := client.ListCandidates(ctx, &protos.ListCandidatesRequest{UserId: "2"})
+	assert.NoError(t, err)
+	var candidateIds []string
+	for _, c := range candidates.GetCandidates() {
+		candidateIds = append(candidateIds, c.GetUserId())
+	}
+	if assert.GreaterOrEqual(t, len(candidateIds), 2) {
+		assert.Equal(t, []string{"9", "3"}, candidateIds[:2])
+	}
+}
+
+func TestActivateBoost(t *testing.T) {
+	ctx := context.Background()
+	port := "50061"
+
+	db, err := sql.Open("mysql", connectionStringVar)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO BoostEntitlements (user_id, boosts_remaining) VALUES (10, 1)`)
+	assert.NoError(t, err)
+
+	go startServer(port)
+
+	time.Sleep(5 * time.Second)
+
+	client, conn, err := getClientAndConnection(port, time.Second*10)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	boost, err := client.ActivateBoost(ctx, &protos.ActivateBoostRequest{UserId: "10", DurationSeconds: 3600})
+	assert.NoError(t, err)
+	assert.True(t, boost.GetBoost().GetActive())
+	assert.Equal(t, uint64(3600), boost.GetBoost().GetEndUnixTimestamp()-boost.GetBoost().GetStartUnixTimestamp())
+
+	_, err = client.ActivateBoost(ctx, &protos.ActivateBoostRequest{UserId: "10", DurationSeconds: 3600})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.ActivateBoost(ctx, &protos.ActivateBoostRequest{UserId: "9", DurationSeconds: 3600})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	//boosted users come before recommendations
+	candidates, err := client.ListCandidates(ctx, &protos.ListCandidatesRequest{UserId: "2"})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, candidates.GetCandidates()) {
+		assert.Equal(t, "10", candidates.GetCandidates()[0].GetUserId())
+	}
+
+	_, err = client.PutDecision(ctx, &protos.PutDecisionRequest{
+		ActorUserId:     "4",
+		RecipientUserId: "10",
+		LikedRecipient:  true,
+	})
+	assert.NoError(t, err)
+
+	latest, err := client.GetBoost(ctx, &protos.GetBoostRequest{UserId: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), latest.GetBoost().GetLikesReceived())
+}
+
+func TestInternalServiceIsNotPublic(t *testing.T) {
+	ctx := context.Background()
+	defaults := config.Default()
+	cfg = &defaults
+
+	public, internal := newServers(memory.NewMemoryStorage(cfg.MaxPageSize), health.NewServer())
+	if !assert.NotNil(t, internal) {
+		return
+	}
+	serve := func(server *grpc.Server) protos.ExploreInternalServiceClient {
+		lis, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		go server.Serve(lis)
+		t.Cleanup(server.Stop)
+
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return protos.NewExploreInternalServiceClient(conn)
+	}
+
+	_, err := serve(public).GetDesirabilityScore(ctx, &protos.GetDesirabilityScoreRequest{UserId: "1"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	_, err = serve(internal).GetDesirabilityScore(ctx, &protos.GetDesirabilityScoreRequest{UserId: "1"})
+	assert.NoError(t, err)
+
+	cfg.InternalPort = 0
+	_, internal = newServers(memory.NewMemoryStorage(cfg.MaxPageSize), health.NewServer())
+	assert.Nil(t, internal)
+}
+
 func getClientAndConnection(port string, timeout time.Duration) (protos.ExploreServiceClient, *grpc.ClientConn, error) {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
@@ -353,3 +649,72 @@ func getClientAndConnection(port string, timeout time.Duration) (protos.ExploreS
 	}
 	return nil, nil, fmt.Errorf("gRPC server did not start in time")
 }
+
+func uint32Ptr(i uint32) *uint32 {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestMysqlStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, maxPageSize int, users int) storage.Storage {
+		return mysql.NewMysqlStorage(newConformanceDB(t, users), maxPageSize)
+	})
+}
+
+func TestShardedStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, maxPageSize int, users int) storage.Storage {
+		db := newConformanceDB(t, users)
+		shards := make(map[int]*sql.DB)
+		for i := 0; i < 2; i++ {
+			shards[i] = createDatabase(t)
+			m, err := migrate.NewShardMigrator(shards[i])
+			if err == nil {
+				_, err = m.Up(context.Background())
+			}
+			if err != nil {
+				t.Fatalf("Failed to migrate shard: %v", err)
+			}
+		}
+		return mysql.NewShardedStorage(db, shards, []int{0, 1}, maxPageSize)
+	})
+}
+
+// newConformanceDB returns a new migrated database holding users 1 to users
+func newConformanceDB(t *testing.T, users int) *sql.DB {
+	db := createDatabase(t)
+	if err := migrateDB(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	for i := 1; i <= users; i++ {
+		if _, err := db.Exec(`INSERT INTO Users (username, first_name, last_name) VALUES (?, 'First', 'Last')`, fmt.Sprintf("user%d", i)); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+	return db
+}
+
+// createDatabase creates an empty database, so each test starts with nothing in it
+func createDatabase(t *testing.T) *sql.DB {
+	conformanceDatabases++
+	name := fmt.Sprintf("conformance%d", conformanceDatabases)
+
+	root, err := sql.Open("mysql", rootConnectionStringVar)
+	if err != nil {
+		t.Fatalf("Failed to connect to MySQL: %v", err)
+	}
+	defer root.Close()
+
+	if _, err := root.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	db, err := sql.Open("mysql", rootConnectionStringVar+name+"?parseTime=true")
+	if err != nil {
+		t.Fatalf("Failed to connect to MySQL: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
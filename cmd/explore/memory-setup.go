@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage/memory"
+)
+
+// dummyUsers and dummyDecisions are the same as AddDummyUserData and AddDummyDecisionData
+var (
+	dummyUsers = [][3]string{
+		{"user1", "John", "Doe"},
+		{"user2", "Jane", "Smith"},
+		{"user3", "Alice", "Johnson"},
+		{"user4", "Bob", "Brown"},
+		{"user5", "Charlie", "Davis"},
+		{"user6", "David", "Miller"},
+		{"user7", "Emma", "Wilson"},
+		{"user8", "Frank", "Moore"},
+		{"user9", "Grace", "Taylor"},
+		{"user10", "Henry", "Anderson"},
+	}
+
+	dummyDecisions = []struct {
+		actorId     int
+		recipientId int
+		liked       bool
+	}{
+		{1, 2, true}, {1, 3, true}, {1, 4, false},
+		{2, 5, true}, {2, 6, false}, {2, 7, true},
+		{3, 8, false}, {3, 9, true}, {3, 10, false},
+		{4, 1, true}, {4, 2, false}, {4, 3, true},
+		{5, 6, false}, {5, 7, true}, {5, 8, false},
+		{6, 9, true}, {6, 10, false}, {6, 1, true},
+		{1, 5, false}, {7, 3, true}, {7, 4, false},
+		{8, 5, true}, {8, 6, false}, {8, 7, true},
+		{9, 8, false}, {9, 9, true}, {9, 10, false},
+		{10, 1, true}, {10, 2, false}, {10, 3, true},
+	}
+)
+
+// newDummyMemoryStorage returns a MemoryStorage holding the dummy data the MySQL database is set up with
+func newDummyMemoryStorage(maxPageSize int) (*memory.MemoryStorage, error) {
+	ctx := context.Background()
+	m := memory.NewMemoryStorage(maxPageSize)
+
+	for _, u := range dummyUsers {
+		if _, err := m.AddUser(u[0], u[1], u[2]); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range dummyDecisions {
+		if _, err := m.AddDecision(ctx, fmt.Sprintf("%d", d.actorId), fmt.Sprintf("%d", d.recipientId), d.liked); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/migrate"
+	"time"
+)
+
+const migrateUsage = "usage: explore [flags] migrate up|down|status"
+
+// migrationTarget is a database with its own migrations
+type migrationTarget struct {
+	name string
+	m    *migrate.Migrator
+}
+
+// runMigrate runs the migrate command against the MySQL database given by the database settings, and with a shard map
+// against every shard too. down only rolls back the main database, as shards have migrations of their own.
+func runMigrate(args []string) {
+	if cfg.Driver != "mysql" {
+		fatal("unsupported driver", fmt.Errorf("migrate only supports mysql, not %s", cfg.Driver))
+	}
+	if len(args) != 1 {
+		fatal("bad arguments", errors.New(migrateUsage))
+	}
+
+	db, err := openDB("mysql", cfg.MySQLDSN())
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	defer db.Close()
+	waitForDB("mysql", db)
+
+	targets := []migrationTarget{{name: "mysql", m: newMigrator(db)}}
+	if cfg.ShardMap != "" {
+		shardMap, err := mysql.LoadShardMap(cfg.ShardMap)
+		if err != nil {
+			fatal("failed to load the shard map", err)
+		}
+		for _, shard := range shardMap.Shards {
+			shardDB, err := openDB("mysql", shard.DSN)
+			if err != nil {
+				fatal("failed to connect to shard", err, "shard", shard.ID)
+			}
+			defer shardDB.Close()
+			name := fmt.Sprintf("shard %d", shard.ID)
+			waitForDB(name, shardDB)
+			targets = append(targets, migrationTarget{name: name, m: newShardMigrator(shardDB)})
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		for _, target := range targets {
+			migrateUp(target.name, target.m)
+		}
+	case "down":
+		migration, err := targets[0].m.Down(context.Background())
+		if err != nil {
+			fatal("failed to roll back", err)
+		}
+		if migration == nil {
+			slog.Info("no migrations to roll back")
+			return
+		}
+		slog.Info("rolled back", "migration", migrationName(migration.Version, migration.Name))
+	case "status":
+		for _, target := range targets {
+			statuses, err := target.m.Status(context.Background())
+			if err != nil {
+				fatal("failed to get migration status", err, "database", target.name)
+			}
+			for _, status := range statuses {
+				if status.AppliedAt == nil {
+					slog.Info("pending", "database", target.name, "migration", migrationName(status.Version, status.Name))
+				} else {
+					slog.Info("applied", "database", target.name, "migration", migrationName(status.Version, status.Name), "at", status.AppliedAt.Format(time.RFC3339))
+				}
+			}
+		}
+	default:
+		fatal("bad arguments", errors.New(migrateUsage))
+	}
+}
+
+// migrateUp applies every pending migration of the database called name, exiting if any fail
+func migrateUp(name string, m *migrate.Migrator) {
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		fatal("failed to migrate", err, "database", name)
+	}
+	for _, migration := range applied {
+		slog.Info("applied", "database", name, "migration", migrationName(migration.Version, migration.Name))
+	}
+	if len(applied) == 0 {
+		slog.Info("schema is up to date", "database", name)
+	}
+}
+
+func newMigrator(db *sql.DB) *migrate.Migrator {
+	m, err := migrate.NewMigrator(db)
+	if err != nil {
+		fatal("failed to load migrations", err)
+	}
+	return m
+}
+
+func newShardMigrator(db *sql.DB) *migrate.Migrator {
+	m, err := migrate.NewShardMigrator(db)
+	if err != nil {
+		fatal("failed to load shard migrations", err)
+	}
+	return m
+}
+
+// migrationName returns the name of a migration's file without the extension, e.g. 0001_create_users
+func migrationName(version int, name string) string {
+	return fmt.Sprintf("%04d_%s", version, name)
+}
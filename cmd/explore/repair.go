@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// runRepairCounters recounts every user's likes received, fixing and reporting any counters that have drifted
+func runRepairCounters() {
+	s, closeStorage := openStorage()
+	defer closeStorage()
+
+	drifts, err := s.RepairLikeCounters(context.Background())
+	if err != nil {
+		fatal("failed to repair like counters", err)
+	}
+	for _, drift := range drifts {
+		slog.Info("repaired like counter", "user", drift.UserID, "counted", drift.Stored, "actual", drift.Actual)
+	}
+	slog.Info("repaired like counters", "count", len(drifts))
+}
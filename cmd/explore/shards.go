@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"muzz-project/readiness"
+	"muzz-project/storage/mysql"
+	"strconv"
+)
+
+const (
+	rebalanceUsage = "usage: explore -shardMap <file> [flags] rebalance <from shard> <to shard> <users>"
+	backfillUsage  = "usage: explore -shardMap <file> [flags] backfill"
+)
+
+// openSharded connects to every shard in the shard map and returns storage sharding decisions over them, with db as
+// the main database
+func openSharded(db *sql.DB, retrier *mysql.Retrier) (*mysql.ShardedStorage, []*sql.DB) {
+	shardMap, err := mysql.LoadShardMap(cfg.ShardMap)
+	if err != nil {
+		fatal("failed to load the shard map", err)
+	}
+
+	shards := make(map[int]*sql.DB)
+	var dbs []*sql.DB
+	for _, shard := range shardMap.Shards {
+		shardDB, err := openDB("mysql", shard.DSN)
+		if err != nil {
+			fatal("failed to connect to shard", err, "shard", shard.ID)
+		}
+		name := fmt.Sprintf("shard %d", shard.ID)
+		waitForDB(name, shardDB)
+
+		m := newShardMigrator(shardDB)
+		if cfg.AutoMigrate {
+			migrateUp(name, m)
+		}
+		dependencies = append(dependencies, readiness.Check{Name: name + " schema", Run: m.Check})
+		shards[shard.ID] = shardDB
+		dbs = append(dbs, shardDB)
+	}
+
+	return mysql.NewShardedStorage(db, shards, shardMap.NewUsers, cfg.MaxPageSize, mysql.WithRetrier(retrier), mysql.WithTracerProvider(tracerProvider)), dbs
+}
+
+// runRebalance moves users from one shard to another while they keep using the service
+func runRebalance(args []string) {
+	if cfg.Driver != "mysql" || cfg.ShardMap == "" {
+		fatal("bad arguments", errors.New(rebalanceUsage))
+	}
+	if len(args) != 3 {
+		fatal("bad arguments", errors.New(rebalanceUsage))
+	}
+	from, fromErr := strconv.Atoi(args[0])
+	to, toErr := strconv.Atoi(args[1])
+	users, usersErr := strconv.Atoi(args[2])
+	if fromErr != nil || toErr != nil || usersErr != nil {
+		fatal("bad arguments", errors.New(rebalanceUsage))
+	}
+
+	db, err := openDB("mysql", cfg.MySQLDSN())
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	defer db.Close()
+
+	s, shardDBs := openSharded(db, newRetrier())
+	defer closeAll(shardDBs)()
+
+	ctx := context.Background()
+	userIds, err := s.UsersToMove(ctx, from, to, users)
+	if err != nil {
+		fatal("failed to find users on shard", err, "shard", from)
+	}
+
+	moved, err := s.MoveUsers(ctx, userIds, to, cfg.MoveGrace)
+	if err != nil {
+		fatal("failed to move users, moving them again finishes the move", err)
+	}
+	slog.Info("moved users", "count", len(moved), "from", from, "to", to)
+}
+
+// runBackfill copies the decisions made before sharding onto the shards
+func runBackfill(args []string) {
+	if cfg.Driver != "mysql" || cfg.ShardMap == "" || len(args) != 0 {
+		fatal("bad arguments", errors.New(backfillUsage))
+	}
+
+	db, err := openDB("mysql", cfg.MySQLDSN())
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	defer db.Close()
+
+	s, shardDBs := openSharded(db, newRetrier())
+	defer closeAll(shardDBs)()
+
+	copied, err := s.Backfill(context.Background())
+	if err != nil {
+		fatal("failed to backfill the shards, backfilling again finishes it", err, "copied", copied)
+	}
+	slog.Info("backfilled the shards", "decisions", copied)
+}
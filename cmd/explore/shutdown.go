@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+// workers runs background jobs until shutdown, then waits for them to finish what they're doing
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var background = newWorkers()
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs job in its own goroutine, cancelling its context on shutdown
+func (w *workers) Go(job func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		job(w.ctx)
+	}()
+}
+
+// stop cancels the jobs and waits up to timeout for them to return, reporting whether they all did
+func (w *workers) stop(timeout time.Duration) bool {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// shutdown drains the servers. Health checks report NOT_SERVING for drainDelay before new RPCs are refused, then RPCs
+// in flight have until shutdownTimeout to finish before they're cancelled. Background jobs are stopped afterwards, with
+// whatever is left of the timeout, so decisions made by the last RPCs are still scored.
+func shutdown(healthServer *health.Server, servers ...*grpc.Server) {
+	deadline := time.Now().Add(cfg.DrainDelay + cfg.ShutdownTimeout)
+	healthServer.Shutdown()
+	if cfg.DrainDelay > 0 {
+		slog.Info("draining", "delay", cfg.DrainDelay.String())
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, server := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				server.GracefulStop()
+			}()
+		}
+		wg.Wait()
+		close(stopped)
+	}()
+	timer := time.NewTimer(cfg.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		slog.Info("RPCs finished")
+	case <-timer.C:
+		slog.Warn("RPCs still running, cancelling them", "timeout", cfg.ShutdownTimeout.String())
+		for _, server := range servers {
+			server.Stop()
+		}
+		<-stopped
+	}
+
+	if !background.stop(time.Until(deadline)) {
+		slog.Warn("background jobs didn't finish in time, stopping anyway")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"muzz-project/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerProvider traces RPCs and storage calls once setUpTracing has run. Until then, or with the none exporter, no
+// spans are recorded, though the trace IDs clients send are still logged.
+var tracerProvider trace.TracerProvider = noop.NewTracerProvider()
+
+// setUpTracing sets tracerProvider from the trace settings, and returns a function exporting the spans not sent yet
+func setUpTracing() func(ctx context.Context) {
+	exporter, err := tracing.NewExporter(context.Background(), cfg.TraceExporter, cfg.TraceFile, cfg.TraceEndpoint)
+	if err != nil {
+		fatal("failed to set up the trace exporter", err)
+	}
+	if exporter == nil {
+		return func(ctx context.Context) {}
+	}
+	provider := tracing.NewProvider("explore", exporter, cfg.TraceSampleRatio)
+	tracerProvider = provider
+	slog.Info("tracing", "exporter", cfg.TraceExporter, "sample_ratio", cfg.TraceSampleRatio)
+
+	return func(ctx context.Context) {
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("failed to export the last spans", "error", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"muzz-project/storage/mysql/transfer"
+	"os"
+	"path/filepath"
+)
+
+const dataUsage = "usage: explorectl [flags] data export|import [-dir <dir>] [-format csv|jsonl] [-batchSize <n>] [-dryRun] [-restart]"
+
+// runData exports Users and Decisions to files in a directory, or imports them from one
+func runData(args []string) {
+	if len(args) == 0 {
+		log.Fatal(dataUsage)
+	}
+
+	flags := flag.NewFlagSet("data "+args[0], flag.ExitOnError)
+	dir := flags.String("dir", ".", "directory holding users.<format> and decisions.<format>")
+	formatName := flags.String("format", "csv", "file format, csv or jsonl")
+	batchSize := flags.Int("batchSize", 1000, "number of rows read or written at a time")
+	dryRun := flags.Bool("dryRun", false, "import only: check the files and report problems without importing anything")
+	restart := flags.Bool("restart", false, "import only: start from the first record instead of after the last one imported")
+	flags.Parse(args[1:])
+
+	format, err := transfer.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *batchSize < 1 {
+		log.Fatal(dataUsage)
+	}
+	usersPath := filepath.Join(*dir, "users."+string(format))
+	decisionsPath := filepath.Join(*dir, "decisions."+string(format))
+
+	db := openDB()
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "export":
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			log.Fatalf("Failed to create %s: %v", *dir, err)
+		}
+		usersFile := createFile(usersPath)
+		defer usersFile.Close()
+		decisionsFile := createFile(decisionsPath)
+		defer decisionsFile.Close()
+
+		counts, err := transfer.Export(ctx, db, transfer.NewUserWriter(usersFile, format), transfer.NewDecisionWriter(decisionsFile, format), *batchSize)
+		if err != nil {
+			log.Fatalf("Failed to export, the files are incomplete: %v", err)
+		}
+		log.Printf("exported %d users to %s and %d decisions to %s", counts.Users, usersPath, counts.Decisions, decisionsPath)
+	case "import":
+		if *dryRun && *restart {
+			log.Fatalf("-restart can't be used with -dryRun")
+		}
+		importer := transfer.NewImporter(db, *batchSize)
+		if *restart {
+			if err := importer.Restart(ctx); err != nil {
+				log.Fatalf("Failed to reset the import cursors: %v", err)
+			}
+		}
+
+		//Everything is checked before anything is written, so a bad file doesn't leave a partial import
+		var problems []transfer.Problem
+		withFile(usersPath, func(f *os.File) {
+			found, err := importer.CheckUsers(ctx, transfer.NewUserReader(f, format))
+			if err != nil {
+				log.Fatalf("Failed to check %s: %v", usersPath, err)
+			}
+			problems = append(problems, found...)
+		})
+		withFile(decisionsPath, func(f *os.File) {
+			found, err := importer.CheckDecisions(ctx, transfer.NewDecisionReader(f, format))
+			if err != nil {
+				log.Fatalf("Failed to check %s: %v", decisionsPath, err)
+			}
+			problems = append(problems, found...)
+		})
+		for _, problem := range problems {
+			log.Print(problem)
+		}
+		if len(problems) > 0 {
+			log.Fatalf("found %d problems, nothing was imported", len(problems))
+		}
+		if *dryRun {
+			log.Printf("no problems found")
+			return
+		}
+
+		withFile(usersPath, func(f *os.File) {
+			imported, err := importer.ImportUsers(ctx, transfer.NewUserReader(f, format))
+			if err != nil {
+				log.Fatalf("Failed after importing %d users, run again to resume: %v", imported, err)
+			}
+			log.Printf("imported %d users", imported)
+		})
+		withFile(decisionsPath, func(f *os.File) {
+			imported, err := importer.ImportDecisions(ctx, transfer.NewDecisionReader(f, format))
+			if err != nil {
+				log.Fatalf("Failed after importing %d decisions, run again to resume: %v", imported, err)
+			}
+			log.Printf("imported %d decisions", imported)
+		})
+	default:
+		log.Fatal(dataUsage)
+	}
+}
+
+func createFile(path string) *os.File {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", path, err)
+	}
+	return f
+}
+
+// withFile opens path for reading and calls use with it
+func withFile(path string, use func(f *os.File)) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	use(f)
+}
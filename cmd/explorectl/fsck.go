@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"muzz-project/storage/mysql/fsck"
+	"os"
+)
+
+// runFsck reports rows breaking the decision graph's invariants, repairing them with -repair, and exits with status 1
+// if any are left
+func runFsck(args []string) {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "fix the violations that can be fixed safely, deleting or recounting rows")
+	flags.Parse(args)
+
+	db := openDB()
+	defer db.Close()
+
+	results, err := fsck.Run(context.Background(), db, *repair)
+	if err != nil {
+		log.Fatalf("Failed to check %v", err)
+	}
+
+	remaining := 0
+	for _, result := range results {
+		for _, v := range result.Violations {
+			log.Printf("%s: %d: %s", result.Check, v.ID, v.Detail)
+		}
+		if len(result.Violations) == 0 {
+			continue
+		}
+		switch {
+		case !result.Repairable:
+			log.Printf("%s: %d found, fix them by hand", result.Check, len(result.Violations))
+		case *repair:
+			log.Printf("%s: %d found, %d repaired", result.Check, len(result.Violations), result.Repaired)
+		default:
+			log.Printf("%s: %d found, -repair fixes them", result.Check, len(result.Violations))
+		}
+		remaining += len(result.Violations) - result.Repaired
+	}
+
+	if remaining > 0 {
+		log.Printf("%d violations left", remaining)
+		os.Exit(1)
+	}
+	log.Printf("no violations left")
+}
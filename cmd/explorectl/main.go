@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"muzz-project/config"
+	"muzz-project/storage/mysql"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const usage = "usage: explorectl [flags] data|retention|fsck [command flags]"
+
+// cfg holds the database settings, read like the explore service's
+var cfg *config.Config
+
+// explorectl runs maintenance tasks against the explore service's MySQL database. The database settings are read like
+// the service's, from its config file, EXPLORE_ environment variables and flags.
+func main() {
+	var args []string
+	var err error
+	cfg, args, err = config.Load("explorectl", os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if cfg.Driver != "mysql" {
+		log.Fatalf("explorectl only supports mysql, not %s", cfg.Driver)
+	}
+	//Its tasks read and write Decisions on the main database, which is out of date once they're sharded
+	if cfg.ShardMap != "" {
+		log.Fatal("explorectl doesn't support sharded decisions, but -shardMap is set")
+	}
+
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	switch args[0] {
+	case "data":
+		runData(args[1:])
+	case "retention":
+		runRetention(args[1:])
+	case "fsck":
+		runFsck(args[1:])
+	default:
+		log.Fatalf("Unknown command %q, must be data, retention or fsck", args[0])
+	}
+}
+
+// openDB connects to the database with the service's pool settings, retrying statements and transactions that fail
+// transiently as the service does
+func openDB() *mysql.DB {
+	db, err := sql.Open("mysql", cfg.MySQLDSN())
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	policy := mysql.DefaultRetryPolicy
+	policy.MaxAttempts = cfg.RetryAttempts
+	return mysql.NewDB(db, mysql.WithRetrier(mysql.NewRetrier(policy)))
+}
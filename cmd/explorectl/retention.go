@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"muzz-project/storage/mysql/retention"
+	"time"
+)
+
+// runRetention archives old passes, and optionally unreciprocated likes, out of Decisions
+func runRetention(args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	months := flags.Int("months", 6, "archive decisions older than this many months")
+	likes := flags.Bool("likes", false, "archive old likes that weren't liked back too, they won't become matches if returned later")
+	archiveFile := flags.String("archiveFile", "", "JSONL file decisions are appended to, the DecisionsArchive table if empty")
+	batchSize := flags.Int("batchSize", 500, "number of decisions archived in each transaction")
+	pause := flags.Duration("pause", 100*time.Millisecond, "how long to wait between batches")
+	dryRun := flags.Bool("dryRun", false, "report how many decisions would be archived without archiving them")
+	flags.Parse(args)
+
+	if *months < 1 || *batchSize < 1 {
+		log.Fatal("-months and -batchSize must be at least 1")
+	}
+
+	db := openDB()
+	defer db.Close()
+
+	var archive retention.Archive = retention.TableArchive{}
+	if *archiveFile != "" && !*dryRun {
+		fileArchive, err := retention.OpenFileArchive(*archiveFile)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *archiveFile, err)
+		}
+		defer fileArchive.Close()
+		archive = fileArchive
+	}
+
+	opts := []retention.Option{retention.WithPause(*pause)}
+	if *likes {
+		opts = append(opts, retention.WithUnreciprocatedLikes())
+	}
+	job := retention.NewJob(db, archive, *months, *batchSize, opts...)
+
+	if *dryRun {
+		result, err := job.DryRun(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to count decisions to archive: %v", err)
+		}
+		log.Printf("would archive %d passes and %d likes", result.Passes, result.Likes)
+		return
+	}
+
+	result, err := job.Run(context.Background())
+	if err != nil {
+		log.Fatalf("Failed after archiving %d passes and %d likes: %v", result.Passes, result.Likes, err)
+	}
+	log.Printf("archived %d passes and %d likes", result.Passes, result.Likes)
+}
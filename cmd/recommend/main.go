@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"muzz-project/config"
+	"muzz-project/logging"
+	"muzz-project/readiness"
+	"muzz-project/recommend"
+	"muzz-project/storage"
+	"muzz-project/storage/mysql"
+	"os"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// pingTimeout is how long each attempt at reaching a database gets, as in the explore service
+const pingTimeout = 5 * time.Second
+
+var (
+	cfg           *config.Config
+	batchSize     int
+	maxNeighbours int
+	topN          int
+)
+
+func registerFlags(fs *flag.FlagSet) {
+	fs.IntVar(&batchSize, "batchSize", 10000, "number of decisions read from the db at a time")
+	fs.IntVar(&maxNeighbours, "neighbours", 50, "number of similar users kept for each user")
+	fs.IntVar(&topN, "topN", 100, "number of recommendations stored for each user")
+}
+
+// recommend trains a collaborative filter on every decision and replaces the stored recommendations that the explore
+// feed ranks candidates by. It's meant to be run periodically, e.g. nightly. The database settings are read like the
+// explore service's, from its config file, EXPLORE_ environment variables and flags.
+func main() {
+	var err error
+	cfg, _, err = config.Load("recommend", os.Args[1:], os.LookupEnv, registerFlags)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.LogFormat, cfg.Level()))
+	if cfg.Driver != "mysql" {
+		fatal("unsupported driver", fmt.Errorf("recommendations are only stored in mysql, not %s", cfg.Driver))
+	}
+
+	s, closeStorage := openStorage()
+	defer closeStorage()
+
+	start := time.Now()
+	saved, err := recommend.NewJob(s, batchSize, maxNeighbours, topN).Run(context.Background())
+	if err != nil {
+		fatal("failed to save recommendations", err, "users_saved", saved)
+	}
+	slog.Info("saved recommendations", "users", saved, "duration", time.Since(start).String())
+}
+
+// openStorage connects to the database, and to every shard with a shard map so decisions are read from the shards as
+// the service stores them, and returns the storage with a function closing it
+func openStorage() (storage.Storage, func()) {
+	policy := mysql.DefaultRetryPolicy
+	policy.MaxAttempts = cfg.RetryAttempts
+	retrier := mysql.NewRetrier(policy)
+
+	db := openDB("mysql", cfg.MySQLDSN())
+	dbs := []*sql.DB{db}
+	closeAll := func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}
+	if cfg.ShardMap == "" {
+		return mysql.NewMysqlStorage(db, 0, mysql.WithRetrier(retrier)), closeAll
+	}
+
+	shardMap, err := mysql.LoadShardMap(cfg.ShardMap)
+	if err != nil {
+		fatal("failed to load the shard map", err)
+	}
+	shards := make(map[int]*sql.DB)
+	for _, shard := range shardMap.Shards {
+		shardDB := openDB(fmt.Sprintf("shard %d", shard.ID), shard.DSN)
+		shards[shard.ID] = shardDB
+		dbs = append(dbs, shardDB)
+	}
+	return mysql.NewShardedStorage(db, shards, shardMap.NewUsers, 0, mysql.WithRetrier(retrier)), closeAll
+}
+
+// openDB connects to the database called name with the pool settings, and waits for it to accept connections for up
+// to the startupTimeout setting, so a run started alongside its database doesn't fail
+func openDB(name string, dsn string) *sql.DB {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		fatal("failed to connect to the database", err, "database", name)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ping := func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		return db.PingContext(ctx)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.StartupTimeout)
+	defer cancel()
+	if err := readiness.WaitFor(ctx, name, ping, 100*time.Millisecond, 5*time.Second); err != nil {
+		fatal("failed to connect to the database", err, "database", name)
+	}
+	return db
+}
+
+// fatal logs msg and err then exits
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
@@ -0,0 +1,511 @@
+// Package config loads the explore service's configuration. Every setting has a flag, a key of the same name in the
+// YAML or JSON config file and an EXPLORE_ environment variable, e.g. -dbHost, dbHost and EXPLORE_DB_HOST. Flags
+// override environment variables, which override the file, which overrides the defaults.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"muzz-project/logging"
+	"muzz-project/tracing"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix  = "EXPLORE_"
+	configFlag = "config"
+	redacted   = "xxxxx"
+
+	// maxLocationPrecision is the most decimal places locations can be stored to, roughly 100m. Any more and a stored
+	// location could give away where somebody lives.
+	maxLocationPrecision = 3
+
+	// defaultRetryAttempts matches mysql.DefaultRetryPolicy, which isn't imported so config stays free of storage
+	defaultRetryAttempts = 4
+)
+
+var drivers = []string{"mysql", "postgres", "sqlite", "memory"}
+
+type Config struct {
+	Driver          string        `yaml:"driver"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	AdminPort       int           `yaml:"adminPort"`
+	InternalPort    int           `yaml:"internalPort"`
+	DrainDelay      time.Duration `yaml:"drainDelay"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	StartupTimeout  time.Duration `yaml:"startupTimeout"`
+	HealthInterval  time.Duration `yaml:"healthInterval"`
+	LogLevel        string        `yaml:"logLevel"`
+	LogFormat       string        `yaml:"logFormat"`
+	LogHashKey      string        `yaml:"logHashKey"`
+
+	TraceExporter    string  `yaml:"traceExporter"`
+	TraceFile        string  `yaml:"traceFile"`
+	TraceEndpoint    string  `yaml:"traceEndpoint"`
+	TraceSampleRatio float64 `yaml:"traceSampleRatio"`
+
+	DSN          string `yaml:"dsn"`
+	DSNFile      string `yaml:"dsnFile"`
+	DBHost       string `yaml:"dbHost"`
+	DBPort       int    `yaml:"dbPort"`
+	Database     string `yaml:"db"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"passwordFile"`
+	ReplicaDSNs  string `yaml:"replicaDsns"`
+
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+	ConnectTimeout  time.Duration `yaml:"connectTimeout"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+
+	PinWindow     time.Duration `yaml:"pinWindow"`
+	ShardMap      string        `yaml:"shardMap"`
+	MoveGrace     time.Duration `yaml:"moveGrace"`
+	RetryAttempts int           `yaml:"retryAttempts"`
+	AutoMigrate   bool          `yaml:"autoMigrate"`
+
+	MaxPageSize       int           `yaml:"maxPageSize"`
+	LocationPrecision int           `yaml:"locationPrecision"`
+	DailyLikeLimit    int           `yaml:"dailyLikeLimit"`
+	ScoreInterval     time.Duration `yaml:"scoreInterval"`
+	CacheSize         int           `yaml:"cacheSize"`
+	CacheTTL          time.Duration `yaml:"cacheTTL"`
+}
+
+// Default returns the configuration used for settings that aren't set anywhere. There's no default password.
+func Default() Config {
+	return Config{
+		Driver:            "mysql",
+		Host:              "0.0.0.0",
+		Port:              8080,
+		AdminPort:         9090,
+		InternalPort:      8081,
+		ShutdownTimeout:   30 * time.Second,
+		StartupTimeout:    2 * time.Minute,
+		HealthInterval:    5 * time.Second,
+		LogLevel:          "info",
+		LogFormat:         "json",
+		TraceExporter:     "none",
+		TraceSampleRatio:  1,
+		DBHost:            "db",
+		Database:          "testdb",
+		User:              "root",
+		MaxIdleConns:      2,
+		PinWindow:         5 * time.Second,
+		MoveGrace:         30 * time.Second,
+		RetryAttempts:     defaultRetryAttempts,
+		MaxPageSize:       1000,
+		LocationPrecision: 2,
+		DailyLikeLimit:    100,
+		ScoreInterval:     time.Minute,
+		CacheTTL:          30 * time.Second,
+	}
+}
+
+// flagSet returns flags setting c's fields, path to the config file and the settings extra registers. Registering them
+// leaves c unchanged, so the file and environment can be applied to c through the same flags.
+func (c *Config) flagSet(name string, path *string, extra []func(fs *flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, configFlag, *path, "YAML or JSON file to read settings from, keyed by flag name")
+	fs.StringVar(&c.Driver, "driver", c.Driver, "where data is stored, mysql, postgres, sqlite or memory. memory starts with dummy data and is lost on exit")
+	fs.StringVar(&c.Host, "host", c.Host, "host to listen on")
+	fs.IntVar(&c.Port, "port", c.Port, "port to listen on")
+	fs.IntVar(&c.AdminPort, "adminPort", c.AdminPort, "port serving Prometheus metrics at /metrics, 0 to turn it off")
+	fs.IntVar(&c.InternalPort, "internalPort", c.InternalPort, "port serving the internal gRPC API, which must only be reachable by other services, 0 to turn it off")
+	fs.DurationVar(&c.DrainDelay, "drainDelay", c.DrainDelay, "how long health checks report NOT_SERVING on shutdown before new RPCs are refused, time for load balancers to stop sending them")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdownTimeout", c.ShutdownTimeout, "how long RPCs in flight and background jobs get to finish on shutdown before they're cancelled")
+	fs.DurationVar(&c.StartupTimeout, "startupTimeout", c.StartupTimeout, "how long to wait for the databases to accept connections at startup, retrying with backoff")
+	fs.DurationVar(&c.HealthInterval, "healthInterval", c.HealthInterval, "how often the databases are pinged and the schema version checked for readiness")
+	fs.StringVar(&c.LogLevel, "logLevel", c.LogLevel, "lowest level logged, debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "logFormat", c.LogFormat, "log output format, json or text")
+	fs.StringVar(&c.LogHashKey, "logHashKey", c.LogHashKey, "key user IDs are hashed with before they're logged, random if empty so hashes only match within one run")
+	fs.StringVar(&c.TraceExporter, "traceExporter", c.TraceExporter, "where spans are sent, none, stdout, file or otlp")
+	fs.StringVar(&c.TraceFile, "traceFile", c.TraceFile, "file spans are appended to as JSON, for the file exporter")
+	fs.StringVar(&c.TraceEndpoint, "traceEndpoint", c.TraceEndpoint, "URL of the OpenTelemetry collector for the otlp exporter, e.g. http://collector:4317, the OTEL_EXPORTER_OTLP_ variables are used if empty")
+	fs.Float64Var(&c.TraceSampleRatio, "traceSampleRatio", c.TraceSampleRatio, "fraction of traces started by the server that are sampled, traces clients sampled always are")
+
+	fs.StringVar(&c.DSN, "dsn", c.DSN, "database connection string, overrides the other database settings. for sqlite this is the database file, created if it doesn't exist")
+	fs.StringVar(&c.DSNFile, "dsnFile", c.DSNFile, "file holding the database connection string, instead of -dsn")
+	fs.StringVar(&c.DBHost, "dbHost", c.DBHost, "database host")
+	fs.IntVar(&c.DBPort, "dbPort", c.DBPort, "database port, 0 for the driver's default")
+	fs.StringVar(&c.Database, "db", c.Database, "database name")
+	fs.StringVar(&c.User, "user", c.User, "database user")
+	fs.StringVar(&c.Password, "password", c.Password, "database password, -passwordFile keeps it out of the process list")
+	fs.StringVar(&c.PasswordFile, "passwordFile", c.PasswordFile, "file holding the database password, instead of -password")
+	fs.StringVar(&c.ReplicaDSNs, "replicaDsns", c.ReplicaDSNs, "comma separated connection strings of read replicas, mysql and postgres only")
+
+	fs.IntVar(&c.MaxOpenConns, "maxOpenConns", c.MaxOpenConns, "most connections open to each database, 0 for no limit")
+	fs.IntVar(&c.MaxIdleConns, "maxIdleConns", c.MaxIdleConns, "most idle connections kept open to each database")
+	fs.DurationVar(&c.ConnMaxLifetime, "connMaxLifetime", c.ConnMaxLifetime, "how long a connection is reused for, 0 for ever")
+	fs.DurationVar(&c.ConnMaxIdleTime, "connMaxIdleTime", c.ConnMaxIdleTime, "how long a connection is kept idle, 0 for ever")
+	fs.DurationVar(&c.ConnectTimeout, "connectTimeout", c.ConnectTimeout, "how long connecting to the database can take, 0 for the driver's default. not added to -dsn")
+	fs.DurationVar(&c.ReadTimeout, "readTimeout", c.ReadTimeout, "mysql only, how long reading a response can take, 0 for no limit. not added to -dsn")
+	fs.DurationVar(&c.WriteTimeout, "writeTimeout", c.WriteTimeout, "mysql only, how long sending a query can take, 0 for no limit. not added to -dsn")
+
+	fs.DurationVar(&c.PinWindow, "pinWindow", c.PinWindow, "how long a user's reads go to the primary after they write, mysql with GTIDs enabled stops sooner once the replica catches up")
+	fs.StringVar(&c.ShardMap, "shardMap", c.ShardMap, "JSON file listing the mysql databases decisions are sharded over, unsharded if empty")
+	fs.DurationVar(&c.MoveGrace, "moveGrace", c.MoveGrace, "how long rebalance waits for requests using a user's old shard, longer than any request takes")
+	fs.IntVar(&c.RetryAttempts, "retryAttempts", c.RetryAttempts, "how many times mysql operations failing with deadlocks, lock wait timeouts or lost connections are tried, 1 disables retries")
+	fs.BoolVar(&c.AutoMigrate, "autoMigrate", c.AutoMigrate, "apply pending mysql schema migrations before serving")
+
+	fs.IntVar(&c.MaxPageSize, "maxPageSize", c.MaxPageSize, "maximum number of db rows to be returned in one query")
+	fs.IntVar(&c.LocationPrecision, "locationPrecision", c.LocationPrecision, "number of decimal places user locations are rounded to before being stored")
+	fs.IntVar(&c.DailyLikeLimit, "dailyLikeLimit", c.DailyLikeLimit, "likes per day for users without an entitlement, 0 for no limit")
+	fs.DurationVar(&c.ScoreInterval, "scoreInterval", c.ScoreInterval, "how often desirability scores are updated with new decisions, 0 to disable")
+	fs.IntVar(&c.CacheSize, "cacheSize", c.CacheSize, "number of like counts and first pages of likes cached in memory, 0 to disable")
+	fs.DurationVar(&c.CacheTTL, "cacheTTL", c.CacheTTL, "how long cached likes are served for")
+
+	for _, register := range extra {
+		register(fs)
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage += fmt.Sprintf(" ($%s)", envName(f.Name))
+	})
+	return fs
+}
+
+// Load returns the configuration from the defaults, the config file, the environment looked up with lookupEnv and
+// the flags in args, and the arguments after the flags. Secrets are read from their files, and the configuration is
+// validated. flag.ErrHelp is returned if -h was asked for, after printing the usage. Commands with settings of their
+// own register them with extra, and they're read from the same places.
+func Load(name string, args []string, lookupEnv func(string) (string, bool), extra ...func(fs *flag.FlagSet)) (*Config, []string, error) {
+	//The flags are parsed once to find the config file and catch bad flags before anything is read
+	var path string
+	scratch := Default()
+	if err := scratch.flagSet(name, &path, extra).Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		path, _ = lookupEnv(envName(configFlag))
+	}
+
+	c := Default()
+	fs := c.flagSet(name, &path, extra)
+	if path != "" {
+		if err := loadFile(fs, path); err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == configFlag {
+			return
+		}
+		if value, ok := lookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("$%s: %w", envName(f.Name), err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.readSecrets(); err != nil {
+		return nil, nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &c, fs.Args(), nil
+}
+
+// loadFile sets the flags named by the keys in the YAML file at path. JSON is YAML, so JSON files can be read too.
+func loadFile(fs *flag.FlagSet, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(contents, &settings); err != nil {
+		return err
+	}
+
+	var errs []error
+	for key, value := range settings {
+		if fs.Lookup(key) == nil || key == configFlag {
+			errs = append(errs, fmt.Errorf("unknown setting %q", key))
+			continue
+		}
+		var s string
+		switch value := value.(type) {
+		case nil:
+			continue
+		case []any:
+			//Lists are accepted for comma separated settings
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			s = strings.Join(items, ",")
+		case map[string]any:
+			errs = append(errs, fmt.Errorf("%s: must be a value, not a map", key))
+			continue
+		default:
+			s = fmt.Sprint(value)
+		}
+		if err := fs.Set(key, s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readSecrets sets the DSN and password from their files, trimming the trailing newline editors add
+func (c *Config) readSecrets() error {
+	if c.DSNFile != "" {
+		if c.DSN != "" {
+			return fmt.Errorf("only one of dsn and dsnFile can be set")
+		}
+		dsn, err := os.ReadFile(c.DSNFile)
+		if err != nil {
+			return fmt.Errorf("reading dsnFile: %w", err)
+		}
+		c.DSN = strings.TrimRight(string(dsn), "\r\n")
+	}
+	if c.PasswordFile != "" {
+		if c.Password != "" {
+			return fmt.Errorf("only one of password and passwordFile can be set")
+		}
+		password, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("reading passwordFile: %w", err)
+		}
+		c.Password = strings.TrimRight(string(password), "\r\n")
+	}
+	return nil
+}
+
+// Validate returns every problem with the configuration joined together, or nil if there are none
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	known := false
+	for _, driver := range drivers {
+		known = known || c.Driver == driver
+	}
+	check(known, "driver must be one of %s, not %q", strings.Join(drivers, ", "), c.Driver)
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535, not %d", c.Port)
+	check(c.AdminPort >= 0 && c.AdminPort < 65536, "adminPort must be between 0 and 65535, not %d", c.AdminPort)
+	check(c.AdminPort != c.Port, "adminPort can't be the same as port")
+	check(c.InternalPort >= 0 && c.InternalPort < 65536, "internalPort must be between 0 and 65535, not %d", c.InternalPort)
+	check(c.InternalPort != c.Port, "internalPort can't be the same as port")
+	check(c.InternalPort == 0 || c.InternalPort != c.AdminPort, "internalPort can't be the same as adminPort")
+
+	server := c.Driver == "mysql" || c.Driver == "postgres"
+	if server {
+		check(c.DSN != "" || c.Password != "", "%s needs a password, set password, passwordFile, dsn or dsnFile", c.Driver)
+		check(c.DSN != "" || c.DBHost != "", "dbHost can't be empty")
+		check(c.DSN != "" || c.Database != "", "db can't be empty")
+	}
+	check(c.DBPort >= 0 && c.DBPort < 65536, "dbPort must be between 0 and 65535, not %d", c.DBPort)
+	check(c.ReplicaDSNs == "" || server, "%s doesn't support replicas", c.Driver)
+	check(c.ShardMap == "" || c.Driver == "mysql", "shardMap is only supported by mysql")
+	check(c.ShardMap == "" || c.ReplicaDSNs == "", "shardMap can't be used with replicaDsns")
+
+	check(c.MaxOpenConns >= 0, "maxOpenConns can't be negative")
+	check(c.MaxIdleConns >= 0, "maxIdleConns can't be negative")
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"drainDelay", c.DrainDelay},
+		{"shutdownTimeout", c.ShutdownTimeout},
+		{"startupTimeout", c.StartupTimeout},
+		{"connMaxLifetime", c.ConnMaxLifetime},
+		{"connMaxIdleTime", c.ConnMaxIdleTime},
+		{"connectTimeout", c.ConnectTimeout},
+		{"readTimeout", c.ReadTimeout},
+		{"writeTimeout", c.WriteTimeout},
+		{"pinWindow", c.PinWindow},
+		{"moveGrace", c.MoveGrace},
+		{"scoreInterval", c.ScoreInterval},
+	} {
+		check(d.value >= 0, "%s can't be negative", d.name)
+	}
+	check(c.HealthInterval > 0, "healthInterval must be positive")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "logLevel must be debug, info, warn or error, not %q", c.LogLevel)
+	check(slices.Contains(logging.Formats, c.LogFormat), "logFormat must be one of %s, not %q", strings.Join(logging.Formats, ", "), c.LogFormat)
+	check(slices.Contains(tracing.Exporters, c.TraceExporter), "traceExporter must be one of %s, not %q", strings.Join(tracing.Exporters, ", "), c.TraceExporter)
+	check(c.TraceExporter != "file" || c.TraceFile != "", "the file trace exporter needs a traceFile")
+	check(c.TraceSampleRatio >= 0 && c.TraceSampleRatio <= 1, "traceSampleRatio must be between 0 and 1")
+	check(c.RetryAttempts >= 1, "retryAttempts must be at least 1")
+
+	check(c.MaxPageSize >= 1, "maxPageSize must be at least 1")
+	check(c.LocationPrecision >= 0 && c.LocationPrecision <= maxLocationPrecision, "locationPrecision must be between 0 and %d", maxLocationPrecision)
+	check(c.DailyLikeLimit >= 0, "dailyLikeLimit can't be negative")
+	check(c.CacheSize >= 0, "cacheSize can't be negative")
+	check(c.CacheSize == 0 || c.CacheTTL > 0, "cacheTTL must be positive when caching")
+
+	return errors.Join(errs...)
+}
+
+// Level returns the logLevel setting, which Validate has checked
+func (c *Config) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// LogValue logs the configuration as String prints it, with the secrets redacted
+func (c Config) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
+
+// MySQLDSN returns the DSN if one was given, otherwise one built from the database settings
+func (c *Config) MySQLDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	dsn := mysqldriver.NewConfig()
+	dsn.User = c.User
+	dsn.Passwd = c.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(c.DBHost, strconv.Itoa(c.portOr(3306)))
+	dsn.DBName = c.Database
+	dsn.ParseTime = true
+	dsn.Timeout = c.ConnectTimeout
+	dsn.ReadTimeout = c.ReadTimeout
+	dsn.WriteTimeout = c.WriteTimeout
+	return dsn.FormatDSN()
+}
+
+// PostgresDSN returns the DSN if one was given, otherwise one built from the database settings
+func (c *Config) PostgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	query := url.Values{"sslmode": {"disable"}}
+	if c.ConnectTimeout > 0 {
+		//Postgres only takes whole seconds, rounded up so a short timeout isn't none
+		query.Set("connect_timeout", strconv.Itoa(int((c.ConnectTimeout+time.Second-1)/time.Second)))
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.DBHost, strconv.Itoa(c.portOr(5432))),
+		Path:     "/" + c.Database,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
+
+// Replicas returns the replica DSNs
+func (c *Config) Replicas() []string {
+	var dsns []string
+	for _, dsn := range strings.Split(c.ReplicaDSNs, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
+
+func (c *Config) portOr(port int) int {
+	if c.DBPort != 0 {
+		return c.DBPort
+	}
+	return port
+}
+
+// String returns the configuration as YAML with the password and the passwords in DSNs redacted, so it can be logged
+func (c Config) String() string {
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	if c.LogHashKey != "" {
+		c.LogHashKey = redacted
+	}
+	c.DSN = redactDSN(c.Driver, c.DSN)
+	replicas := c.Replicas()
+	for i, dsn := range replicas {
+		replicas[i] = redactDSN(c.Driver, dsn)
+	}
+	c.ReplicaDSNs = strings.Join(replicas, ",")
+
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("unprintable config: %v", err)
+	}
+	return string(out)
+}
+
+var keywordPassword = regexp.MustCompile(`(password\s*=\s*)('(\\.|[^'])*'|\S+)`)
+
+// redactDSN hides the password in dsn, or all of it if it can't be parsed
+func redactDSN(driver string, dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	switch driver {
+	case "mysql":
+		parsed, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return redacted
+		}
+		if parsed.Passwd != "" {
+			parsed.Passwd = redacted
+		}
+		return parsed.FormatDSN()
+	case "postgres":
+		if !strings.Contains(dsn, "://") {
+			return keywordPassword.ReplaceAllString(dsn, "${1}"+redacted)
+		}
+		parsed, err := url.Parse(dsn)
+		if err != nil {
+			return redacted
+		}
+		query := parsed.Query()
+		if query.Has("password") {
+			query.Set("password", redacted)
+			parsed.RawQuery = query.Encode()
+		}
+		return parsed.Redacted()
+	default:
+		return dsn
+	}
+}
+
+// envName returns the environment variable for a flag, e.g. EXPLORE_DB_HOST for dbHost
+func envName(flagName string) string {
+	var name strings.Builder
+	name.WriteString(envPrefix)
+	runes := []rune(flagName)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			name.WriteRune('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
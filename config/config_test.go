@@ -0,0 +1,211 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "explore.yaml", "dbHost: file-host\ndbPort: 3307\nuser: file-user\npassword: secret\ncacheTTL: 1m\nreplicaDsns:\n  - a:b@tcp(r1)/db\n  - a:b@tcp(r2)/db\n")
+	jsonFile := writeFile(t, "explore.json", `{"dbHost": "json-host", "password": "secret", "autoMigrate": true, "maxOpenConns": 20}`)
+	passwordFile := writeFile(t, "password", "from-file\n")
+
+	tests := map[string]struct {
+		args    []string
+		env     map[string]string
+		want    func(c *Config)
+		wantErr string
+	}{
+		"defaults": {
+			env: map[string]string{"EXPLORE_PASSWORD": "secret"},
+			want: func(c *Config) {
+				c.Password = "secret"
+			},
+		},
+		"file": {
+			args: []string{"-config", yamlFile},
+			want: func(c *Config) {
+				c.DBHost = "file-host"
+				c.DBPort = 3307
+				c.User = "file-user"
+				c.Password = "secret"
+				c.CacheTTL = time.Minute
+				c.ReplicaDSNs = "a:b@tcp(r1)/db,a:b@tcp(r2)/db"
+			},
+		},
+		"json file from the environment": {
+			env: map[string]string{"EXPLORE_CONFIG": jsonFile},
+			want: func(c *Config) {
+				c.DBHost = "json-host"
+				c.Password = "secret"
+				c.AutoMigrate = true
+				c.MaxOpenConns = 20
+			},
+		},
+		"environment overrides the file, flags override both": {
+			args: []string{"-config", yamlFile, "-dbHost", "flag-host"},
+			env:  map[string]string{"EXPLORE_DB_HOST": "env-host", "EXPLORE_USER": "env-user", "EXPLORE_CACHE_TTL": "5s"},
+			want: func(c *Config) {
+				c.DBHost = "flag-host"
+				c.DBPort = 3307
+				c.User = "env-user"
+				c.Password = "secret"
+				c.CacheTTL = 5 * time.Second
+				c.ReplicaDSNs = "a:b@tcp(r1)/db,a:b@tcp(r2)/db"
+			},
+		},
+		"password from a file": {
+			env: map[string]string{"EXPLORE_PASSWORD_FILE": passwordFile},
+			want: func(c *Config) {
+				c.PasswordFile = passwordFile
+				c.Password = "from-file"
+			},
+		},
+		"password and password file": {
+			args:    []string{"-password", "secret", "-passwordFile", passwordFile},
+			wantErr: "only one of password and passwordFile can be set",
+		},
+		"unknown setting in the file": {
+			args:    []string{"-config", writeFile(t, "bad.yaml", "pasword: secret\n")},
+			wantErr: `unknown setting "pasword"`,
+		},
+		"bad environment variable": {
+			env:     map[string]string{"EXPLORE_MAX_PAGE_SIZE": "lots"},
+			wantErr: `$EXPLORE_MAX_PAGE_SIZE: parse error`,
+		},
+		"admin port clashes": {
+			args:    []string{"-password", "secret", "-adminPort", "8080"},
+			wantErr: "adminPort can't be the same as port",
+		},
+		"internal port clashes": {
+			args:    []string{"-password", "secret", "-internalPort", "9090"},
+			wantErr: "internalPort can't be the same as adminPort",
+		},
+		"bad log settings": {
+			args:    []string{"-password", "secret", "-logLevel", "verbose", "-logFormat", "xml"},
+			wantErr: "logLevel must be debug, info, warn or error, not \"verbose\"\nlogFormat must be one of json, text, not \"xml\"",
+		},
+		"bad trace settings": {
+			args:    []string{"-password", "secret", "-traceExporter", "file", "-traceSampleRatio", "2"},
+			wantErr: "the file trace exporter needs a traceFile\ntraceSampleRatio must be between 0 and 1",
+		},
+		"precise locations": {
+			args:    []string{"-password", "secret", "-locationPrecision", "4"},
+			wantErr: "locationPrecision must be between 0 and 3",
+		},
+		"invalid": {
+			args:    []string{"-driver", "mysql", "-maxPageSize", "0", "-cacheSize", "10", "-cacheTTL", "0s"},
+			wantErr: "mysql needs a password, set password, passwordFile, dsn or dsnFile\nmaxPageSize must be at least 1\ncacheTTL must be positive when caching",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, args, err := Load("explore", append(tt.args, "migrate", "up"), env(tt.env))
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			want := Default()
+			tt.want(&want)
+			assert.Equal(t, &want, got)
+			assert.Equal(t, []string{"migrate", "up"}, args)
+		})
+	}
+}
+
+func TestLoad_Extra(t *testing.T) {
+	var batchSize, topN int
+	extra := func(fs *flag.FlagSet) {
+		fs.IntVar(&batchSize, "batchSize", 100, "")
+		fs.IntVar(&topN, "topN", 10, "")
+	}
+
+	got, args, err := Load("recommend", []string{"-password", "secret", "-topN", "5", "extra"}, env(map[string]string{"EXPLORE_BATCH_SIZE": "50", "EXPLORE_TOP_N": "20"}), extra)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", got.Password)
+	assert.Equal(t, 50, batchSize)
+	assert.Equal(t, 5, topN)
+	assert.Equal(t, []string{"extra"}, args)
+}
+
+func TestConfig_DSNs(t *testing.T) {
+	c := Default()
+	c.Password = "p@ss"
+	c.ConnectTimeout = 1500 * time.Millisecond
+
+	assert.Equal(t, "root:p@ss@tcp(db:3306)/testdb?parseTime=true&timeout=1.5s", c.MySQLDSN())
+	assert.Equal(t, "postgres://root:p%40ss@db:5432/testdb?connect_timeout=2&sslmode=disable", c.PostgresDSN())
+
+	c.DBPort = 3307
+	assert.Equal(t, "root:p@ss@tcp(db:3307)/testdb?parseTime=true&timeout=1.5s", c.MySQLDSN())
+
+	c.DSN = "u:p@tcp(host)/db"
+	assert.Equal(t, "u:p@tcp(host)/db", c.MySQLDSN())
+}
+
+func TestConfig_String(t *testing.T) {
+	c := Default()
+	c.Password = "secret"
+	c.LogHashKey = "secret"
+	c.DSN = "root:secret@tcp(db:3306)/testdb?parseTime=true"
+	c.ReplicaDSNs = "root:secret@tcp(r1:3306)/testdb, root:secret@tcp(r2:3306)/testdb"
+
+	got := c.String()
+
+	assert.NotContains(t, got, "secret")
+	assert.Contains(t, got, "password: xxxxx\n")
+	assert.Contains(t, got, "logHashKey: xxxxx\n")
+	assert.Contains(t, got, "dsn: root:xxxxx@tcp(db:3306)/testdb?parseTime=true\n")
+	assert.Contains(t, got, "replicaDsns: root:xxxxx@tcp(r1:3306)/testdb,root:xxxxx@tcp(r2:3306)/testdb\n")
+	assert.Contains(t, got, "cacheTTL: 30s\n")
+	assert.Equal(t, "secret", c.Password)
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := map[string]struct {
+		driver string
+		dsn    string
+		want   string
+	}{
+		"mysql":               {driver: "mysql", dsn: "user:secret@tcp(db)/testdb", want: "user:xxxxx@tcp(db:3306)/testdb"},
+		"mysql unparseable":   {driver: "mysql", dsn: "secret", want: "xxxxx"},
+		"postgres url":        {driver: "postgres", dsn: "postgres://user:secret@db/testdb?sslmode=disable", want: "postgres://user:xxxxx@db/testdb?sslmode=disable"},
+		"postgres url query":  {driver: "postgres", dsn: "postgres://db/testdb?password=secret", want: "postgres://db/testdb?password=xxxxx"},
+		"postgres keyword":    {driver: "postgres", dsn: "host=db password='se cret' dbname=testdb", want: "host=db password=xxxxx dbname=testdb"},
+		"sqlite isn't secret": {driver: "sqlite", dsn: "explore.db", want: "explore.db"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactDSN(tt.driver, tt.dsn))
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "EXPLORE_DB_HOST", envName("dbHost"))
+	assert.Equal(t, "EXPLORE_CACHE_TTL", envName("cacheTTL"))
+	assert.Equal(t, "EXPLORE_REPLICA_DSNS", envName("replicaDsns"))
+	assert.Equal(t, "EXPLORE_CONN_MAX_LIFETIME", envName("connMaxLifetime"))
+}
@@ -0,0 +1,114 @@
+package geo
+
+import (
+	"math"
+)
+
+// EarthRadiusKm matches the radius used by MySQL's ST_Distance_Sphere so distances agree across backends.
+const EarthRadiusKm = 6370.986
+
+const (
+	minLatitude  = -90.0
+	maxLatitude  = 90.0
+	minLongitude = -180.0
+	maxLongitude = 180.0
+)
+
+type BoundingBox struct {
+	MinLatitude  float64
+	MaxLatitude  float64
+	MinLongitude float64
+	MaxLongitude float64
+}
+
+// CrossesAntimeridian is true when the box wraps past 180 degrees longitude, in which case a point is inside the
+// box if its longitude is >= MinLongitude OR <= MaxLongitude.
+func (b BoundingBox) CrossesAntimeridian() bool {
+	return b.MinLongitude > b.MaxLongitude
+}
+
+func (b BoundingBox) Contains(latitude, longitude float64) bool {
+	if latitude < b.MinLatitude || latitude > b.MaxLatitude {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return longitude >= b.MinLongitude || longitude <= b.MaxLongitude
+	}
+	return longitude >= b.MinLongitude && longitude <= b.MaxLongitude
+}
+
+func ValidCoordinates(latitude, longitude float64) bool {
+	return latitude >= minLatitude && latitude <= maxLatitude && longitude >= minLongitude && longitude <= maxLongitude
+}
+
+// Round reduces a coordinate to the given number of decimal places. Two decimal places is roughly 1km.
+func Round(coordinate float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(coordinate*factor) / factor
+}
+
+// DistanceKm returns the great circle distance between two points using the haversine formula.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := toRadians(lat1)
+	phi2 := toRadians(lat2)
+	dPhi := toRadians(lat2 - lat1)
+	dLambda := toRadians(lon2 - lon1)
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ApproximateKm rounds a distance up to a whole number of kilometres, with a minimum of 1, so that clients can't
+// use repeated queries to pin down somebody's exact position.
+func ApproximateKm(distanceKm float64) uint32 {
+	if distanceKm <= 1 {
+		return 1
+	}
+	return uint32(math.Ceil(distanceKm))
+}
+
+// NewBoundingBox returns the smallest latitude/longitude box containing every point within distanceKm of the
+// origin. It's used as an index-friendly pre-filter before the exact distance is calculated.
+func NewBoundingBox(latitude, longitude, distanceKm float64) BoundingBox {
+	angularDistance := distanceKm / EarthRadiusKm
+	lat := toRadians(latitude)
+	lon := toRadians(longitude)
+
+	minLat := lat - angularDistance
+	maxLat := lat + angularDistance
+
+	// Boxes covering a pole include every longitude
+	if minLat <= toRadians(minLatitude) || maxLat >= toRadians(maxLatitude) {
+		return BoundingBox{
+			MinLatitude:  math.Max(toDegrees(minLat), minLatitude),
+			MaxLatitude:  math.Min(toDegrees(maxLat), maxLatitude),
+			MinLongitude: minLongitude,
+			MaxLongitude: maxLongitude,
+		}
+	}
+
+	deltaLon := math.Asin(math.Sin(angularDistance) / math.Cos(lat))
+	minLon := lon - deltaLon
+	maxLon := lon + deltaLon
+	if minLon < toRadians(minLongitude) {
+		minLon += 2 * math.Pi
+	}
+	if maxLon > toRadians(maxLongitude) {
+		maxLon -= 2 * math.Pi
+	}
+
+	return BoundingBox{
+		MinLatitude:  toDegrees(minLat),
+		MaxLatitude:  toDegrees(maxLat),
+		MinLongitude: toDegrees(minLon),
+		MaxLongitude: toDegrees(maxLon),
+	}
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
@@ -0,0 +1,84 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := map[string]struct {
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		"same point": {
+			lat1: 51.5, lon1: -0.12, lat2: 51.5, lon2: -0.12,
+			want: 0,
+		},
+		"london to paris": {
+			lat1: 51.5074, lon1: -0.1278, lat2: 48.8566, lon2: 2.3522,
+			want: 343.5,
+		},
+		"across the antimeridian": {
+			lat1: 0, lon1: 179.5, lat2: 0, lon2: -179.5,
+			want: 111.2,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := DistanceKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+			assert.InDelta(t, tt.want, got, 0.5)
+		})
+	}
+}
+
+func TestNewBoundingBox(t *testing.T) {
+	tests := map[string]struct {
+		lat, lon, distanceKm float64
+		inside               [][2]float64
+		outside              [][2]float64
+		crossesAntimeridian  bool
+	}{
+		"london": {
+			lat: 51.5074, lon: -0.1278, distanceKm: 50,
+			inside:  [][2]float64{{51.7520, -0.3360}, {51.5074, -0.1278}},
+			outside: [][2]float64{{48.8566, 2.3522}, {52.4862, -1.8904}},
+		},
+		"near the antimeridian": {
+			lat: 0, lon: 179.9, distanceKm: 50,
+			inside:              [][2]float64{{0, -179.9}, {0.1, 179.8}},
+			outside:             [][2]float64{{0, 0}, {0, -178}},
+			crossesAntimeridian: true,
+		},
+		"covers the north pole": {
+			lat: 89.9, lon: 10, distanceKm: 50,
+			inside:  [][2]float64{{89.9, -170}, {90, 0}},
+			outside: [][2]float64{{88, 10}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			box := NewBoundingBox(tt.lat, tt.lon, tt.distanceKm)
+			assert.Equal(t, tt.crossesAntimeridian, box.CrossesAntimeridian())
+			for _, p := range tt.inside {
+				assert.True(t, box.Contains(p[0], p[1]), "expected %v inside %+v", p, box)
+			}
+			for _, p := range tt.outside {
+				assert.False(t, box.Contains(p[0], p[1]), "expected %v outside %+v", p, box)
+			}
+		})
+	}
+}
+
+func TestApproximateKm(t *testing.T) {
+	assert.Equal(t, uint32(1), ApproximateKm(0))
+	assert.Equal(t, uint32(1), ApproximateKm(0.4))
+	assert.Equal(t, uint32(2), ApproximateKm(1.01))
+	assert.Equal(t, uint32(13), ApproximateKm(12.2))
+}
+
+func TestRound(t *testing.T) {
+	assert.Equal(t, 51.51, Round(51.5074, 2))
+	assert.Equal(t, -0.1, Round(-0.1278, 1))
+	assert.Equal(t, 52.0, Round(51.5074, 0))
+}
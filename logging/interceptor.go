@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the metadata key a request ID is read from, and sent back to the client in
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength bounds the request IDs clients can choose, longer ones are replaced
+const maxRequestIDLength = 128
+
+// healthPrefix starts the methods of the gRPC health service, which are polled too often to log at info
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// serverErrors are the codes that mean the server failed rather than refused the request, which are logged at error
+var serverErrors = map[codes.Code]bool{
+	codes.Unknown:       true,
+	codes.Internal:      true,
+	codes.Unavailable:   true,
+	codes.DataLoss:      true,
+	codes.Unimplemented: true,
+}
+
+// Interceptor gives each RPC a request ID and a logger tagged with it, then logs the RPC once it's handled
+type Interceptor struct {
+	logger *slog.Logger
+	key    []byte
+}
+
+// NewInterceptor returns an Interceptor logging to logger. User IDs are logged as an HMAC keyed with key, so the logs
+// of one user's requests can be matched up without the logs saying who the user is.
+func NewInterceptor(logger *slog.Logger, key []byte) *Interceptor {
+	return &Interceptor{logger: logger, key: key}
+}
+
+// UnaryInterceptor logs unary RPCs
+func (i *Interceptor) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, logger := i.begin(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, RequestID(ctx)))
+		resp, err := handler(ctx, req)
+		i.end(ctx, logger, info.FullMethod, start, i.userIDs(req), err)
+		return resp, err
+	}
+}
+
+// StreamInterceptor logs streaming RPCs when they end, with the user IDs in the first message received
+func (i *Interceptor) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, logger := i.begin(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(RequestIDHeader, RequestID(ctx)))
+		stream := &loggedStream{ServerStream: ss, ctx: ctx, interceptor: i}
+		err := handler(srv, stream)
+		i.end(ctx, logger, info.FullMethod, start, stream.users(), err)
+		return err
+	}
+}
+
+// HashUserID returns the HMAC of userId that's logged in its place
+func (i *Interceptor) HashUserID(userId string) string {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write([]byte(userId))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside of one
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// begin returns ctx carrying the request's ID, the client's if it sent a usable one, and a logger tagged with it
+func (i *Interceptor) begin(ctx context.Context) (context.Context, *slog.Logger) {
+	id := ""
+	if ids := metadata.ValueFromIncomingContext(ctx, RequestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+		id = ids[0]
+	} else {
+		id = newRequestID()
+	}
+	logger := i.logger.With(slog.String("request_id", id))
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With(slog.String("trace_id", span.TraceID().String()))
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return WithLogger(ctx, logger), logger
+}
+
+// end logs the RPC at error if the server failed, debug for successful health checks and info otherwise
+func (i *Interceptor) end(ctx context.Context, logger *slog.Logger, method string, start time.Time, users []any, err error) {
+	st := status.Convert(err)
+	level := slog.LevelInfo
+	switch {
+	case serverErrors[st.Code()]:
+		level = slog.LevelError
+	case st.Code() == codes.OK && strings.HasPrefix(method, healthPrefix):
+		level = slog.LevelDebug
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", st.Code().String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	attrs = append(attrs, users...)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", st.Message()))
+	}
+	logger.Log(ctx, level, "rpc", attrs...)
+}
+
+// userIDs returns the hashed user IDs in req, under the names of the fields they're in
+func (i *Interceptor) userIDs(req any) []any {
+	var attrs []any
+	add := func(name string, id string) {
+		if id != "" {
+			attrs = append(attrs, slog.String(name, i.HashUserID(id)))
+		}
+	}
+	if r, ok := req.(interface{ GetUserId() string }); ok {
+		add("user", r.GetUserId())
+	}
+	if r, ok := req.(interface{ GetActorUserId() string }); ok {
+		add("actor", r.GetActorUserId())
+	}
+	if r, ok := req.(interface{ GetRecipientUserId() string }); ok {
+		add("recipient", r.GetRecipientUserId())
+	}
+	return attrs
+}
+
+// loggedStream gives the handler the context carrying the request's logger, and notes the user IDs in the first
+// message it receives
+type loggedStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	interceptor *Interceptor
+
+	once    sync.Once
+	mu      sync.Mutex
+	userIDs []any
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggedStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.userIDs = s.interceptor.userIDs(m)
+		})
+	}
+	return err
+}
+
+func (s *loggedStream) users() []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userIDs
+}
+
+// validRequestID reports whether a client's request ID is short and printable enough to log as it is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
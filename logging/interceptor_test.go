@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"muzz-project/service/protos"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// records returns the JSON records logged to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestInterceptor_UnaryInterceptor(t *testing.T) {
+	req := &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true}
+
+	tests := map[string]struct {
+		method        string
+		md            metadata.MD
+		err           error
+		wantRequestID string
+		wantLevel     string
+		wantLogged    bool
+	}{
+		"request ID from the client": {
+			method:        "/explore.ExploreService/PutDecision",
+			md:            metadata.Pairs(RequestIDHeader, "abc-123"),
+			wantRequestID: "abc-123",
+			wantLevel:     "INFO",
+			wantLogged:    true,
+		},
+		"request ID generated": {
+			method:     "/explore.ExploreService/PutDecision",
+			wantLevel:  "INFO",
+			wantLogged: true,
+		},
+		"unprintable request ID replaced": {
+			method:     "/explore.ExploreService/PutDecision",
+			md:         metadata.Pairs(RequestIDHeader, "abc\n123"),
+			wantLevel:  "INFO",
+			wantLogged: true,
+		},
+		"refused": {
+			method:        "/explore.ExploreService/PutDecision",
+			md:            metadata.Pairs(RequestIDHeader, "abc-123"),
+			err:           status.Error(codes.NotFound, "User not found"),
+			wantRequestID: "abc-123",
+			wantLevel:     "INFO",
+			wantLogged:    true,
+		},
+		"failed": {
+			method:        "/explore.ExploreService/PutDecision",
+			md:            metadata.Pairs(RequestIDHeader, "abc-123"),
+			err:           status.Error(codes.Internal, "database down"),
+			wantRequestID: "abc-123",
+			wantLevel:     "ERROR",
+			wantLogged:    true,
+		},
+		"health check": {
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			i := NewInterceptor(New(&buf, "json", slog.LevelInfo), []byte("key"))
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var handlerID string
+			_, err := i.UnaryInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req any) (any, error) {
+				handlerID = RequestID(ctx)
+				FromContext(ctx).Info("handling")
+				return nil, tt.err
+			})
+			assert.Equal(t, tt.err, err)
+
+			if tt.wantRequestID != "" {
+				assert.Equal(t, tt.wantRequestID, handlerID)
+			} else {
+				assert.Len(t, handlerID, 32)
+			}
+
+			logged := records(t, &buf)
+			assert.Equal(t, "handling", logged[0]["msg"])
+			assert.Equal(t, handlerID, logged[0]["request_id"])
+			if !tt.wantLogged {
+				assert.Len(t, logged, 1)
+				return
+			}
+			assert.Len(t, logged, 2)
+			rpc := logged[1]
+			assert.Equal(t, "rpc", rpc["msg"])
+			assert.Equal(t, tt.wantLevel, rpc["level"])
+			assert.Equal(t, handlerID, rpc["request_id"])
+			assert.Equal(t, tt.method, rpc["method"])
+			assert.Equal(t, status.Code(tt.err).String(), rpc["code"])
+			assert.Contains(t, rpc, "duration_ms")
+			assert.Equal(t, i.HashUserID("1"), rpc["actor"])
+			assert.Equal(t, i.HashUserID("2"), rpc["recipient"])
+			if tt.err != nil {
+				assert.Equal(t, status.Convert(tt.err).Message(), rpc["error"])
+			}
+		})
+	}
+}
+
+// fakeStream receives one message, a GetBoostRequest for userId
+type fakeStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	userId string
+	header metadata.MD
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) SetHeader(md metadata.MD) error {
+	s.header = md
+	return nil
+}
+
+func (s *fakeStream) RecvMsg(m any) error {
+	m.(*protos.GetBoostRequest).UserId = s.userId
+	return nil
+}
+
+func TestInterceptor_StreamInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	i := NewInterceptor(New(&buf, "json", slog.LevelInfo), []byte("key"))
+	ss := &fakeStream{ctx: context.Background(), userId: "7"}
+
+	err := i.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: "/explore.ExploreService/Watch"}, func(srv any, stream grpc.ServerStream) error {
+		assert.NotEmpty(t, RequestID(stream.Context()))
+		return stream.RecvMsg(&protos.GetBoostRequest{})
+	})
+
+	assert.NoError(t, err)
+	logged := records(t, &buf)
+	assert.Len(t, logged, 1)
+	assert.Equal(t, i.HashUserID("7"), logged[0]["user"])
+	assert.Equal(t, []string{logged[0]["request_id"].(string)}, ss.header.Get(RequestIDHeader))
+}
+
+func TestInterceptor_TraceID(t *testing.T) {
+	var buf bytes.Buffer
+	i := NewInterceptor(New(&buf, "json", slog.LevelInfo), []byte("key"))
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	_, _ = i.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/explore.ExploreService/GetQuota"}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+
+	logged := records(t, &buf)
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logged[0]["trace_id"])
+}
+
+func TestInterceptor_HashUserID(t *testing.T) {
+	i := NewInterceptor(slog.Default(), []byte("key"))
+
+	assert.Len(t, i.HashUserID("1"), 16)
+	assert.Equal(t, i.HashUserID("1"), i.HashUserID("1"))
+	assert.NotEqual(t, i.HashUserID("1"), i.HashUserID("2"))
+	assert.NotEqual(t, i.HashUserID("1"), NewInterceptor(slog.Default(), []byte("other")).HashUserID("1"))
+}
@@ -0,0 +1,36 @@
+// Package logging sets up the server's structured logger and carries a logger through the context, tagged with the ID
+// of the request being handled, so everything logged while handling a request can be found together.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// Formats are the output formats New accepts
+var Formats = []string{"json", "text"}
+
+// New returns a logger writing records at level and above to w, as JSON lines or, for the text format, key=value pairs
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, or the default logger if it doesn't carry one
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
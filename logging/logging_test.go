@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "json", slog.LevelInfo)
+
+	logger.Debug("hidden")
+	logger.Info("shown", "count", 2)
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "shown", record["msg"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, 2.0, record["count"])
+
+	buf.Reset()
+	New(&buf, "text", slog.LevelDebug).Debug("shown")
+	assert.Contains(t, buf.String(), "level=DEBUG msg=shown")
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+
+	logger := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	assert.Equal(t, logger, FromContext(WithLogger(context.Background(), logger)))
+}
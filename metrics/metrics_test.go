@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerMetrics_UnaryInterceptor(t *testing.T) {
+	reg := NewRegistry()
+	m := NewServerMetrics(reg)
+	interceptor := m.UnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/explore.ExploreService/PutDecision"}
+
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	notFound := func(ctx context.Context, req any) (any, error) { return nil, status.Error(codes.NotFound, "no such user") }
+	for _, handler := range []grpc.UnaryHandler{ok, ok, notFound} {
+		_, _ = interceptor(context.Background(), nil, info, handler)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("explore.ExploreService", "PutDecision", "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("explore.ExploreService", "PutDecision", "NotFound")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.duration))
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, `explore_grpc_requests_total{code="NotFound",method="PutDecision",service="explore.ExploreService"} 1`)
+	assert.Contains(t, body, `explore_grpc_request_duration_seconds_count{method="PutDecision",service="explore.ExploreService"} 3`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestServerMetrics_StreamInterceptor(t *testing.T) {
+	m := NewServerMetrics(NewRegistry())
+	interceptor := m.StreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/explore.ExploreService/ListLikedYou"}
+
+	err := interceptor(nil, nil, info, func(srv any, stream grpc.ServerStream) error {
+		return status.Error(codes.Canceled, "client went away")
+	})
+
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("explore.ExploreService", "ListLikedYou", "Canceled")))
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := map[string]struct {
+		fullMethod  string
+		wantService string
+		wantMethod  string
+	}{
+		"full method": {fullMethod: "/explore.ExploreService/ListLikedYou", wantService: "explore.ExploreService", wantMethod: "ListLikedYou"},
+		"no service":  {fullMethod: "ListLikedYou", wantService: "unknown", wantMethod: "ListLikedYou"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			service, method := splitMethod(tt.fullMethod)
+			assert.Equal(t, tt.wantService, service)
+			assert.Equal(t, tt.wantMethod, method)
+		})
+	}
+}
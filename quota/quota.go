@@ -0,0 +1,124 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	"time"
+)
+
+var (
+	ErrExhausted = fmt.Errorf("daily like limit reached")
+)
+
+type Status struct {
+	Unlimited bool
+	Limit     int
+	Used      int
+	// Day is the YYYY-MM-DD date in the user's time zone that the likes are counted against
+	Day      string
+	ResetsAt time.Time
+}
+
+func (s Status) Remaining() int {
+	return max(s.Limit-s.Used, 0)
+}
+
+// Limiter enforces a daily like limit that resets at midnight in each user's time zone. Users with an active
+// entitlement get its limit instead of the default.
+type Limiter struct {
+	storage    storage.Storage
+	dailyLikes int
+	now        func() time.Time
+}
+
+// NewLimiter returns a Limiter allowing dailyLikes likes per day. A dailyLikes of 0 disables the limit for everyone.
+func NewLimiter(storage storage.Storage, dailyLikes int) *Limiter {
+	return &Limiter{
+		storage:    storage,
+		dailyLikes: dailyLikes,
+		now:        time.Now,
+	}
+}
+
+func (l *Limiter) Status(ctx context.Context, userId string) (*Status, error) {
+	status, err := l.status(ctx, userId)
+	if err != nil || status.Unlimited {
+		return status, err
+	}
+
+	status.Used, err = l.storage.GetLikesUsed(ctx, userId, status.Day)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Consume takes one like from the user's quota. If none are left it returns ErrExhausted along with the Status so
+// callers can say when the quota resets. Used isn't populated on success.
+func (l *Limiter) Consume(ctx context.Context, userId string) (*Status, error) {
+	status, err := l.status(ctx, userId)
+	if err != nil || status.Unlimited {
+		return status, err
+	}
+
+	ok := false
+	if status.Limit > 0 {
+		ok, err = l.storage.ConsumeLike(ctx, userId, status.Day, status.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		status.Used = status.Limit
+		return status, ErrExhausted
+	}
+	return status, nil
+}
+
+// Refund gives back a like taken by Consume
+func (l *Limiter) Refund(ctx context.Context, userId string, status *Status) error {
+	if status.Unlimited {
+		return nil
+	}
+	return l.storage.RefundLike(ctx, userId, status.Day)
+}
+
+func (l *Limiter) status(ctx context.Context, userId string) (*Status, error) {
+	if l.dailyLikes == 0 {
+		return &Status{Unlimited: true}, nil
+	}
+
+	settings, err := l.storage.GetQuotaSettings(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	day, resetsAt := localDay(l.now(), settings.TimeZone)
+	status := &Status{
+		Limit:    l.dailyLikes,
+		Day:      day,
+		ResetsAt: resetsAt,
+	}
+
+	if settings.Entitlement != nil {
+		if settings.Entitlement.DailyLikeLimit == nil {
+			status.Unlimited = true
+		} else {
+			status.Limit = *settings.Entitlement.DailyLikeLimit
+		}
+	}
+	return status, nil
+}
+
+// localDay returns the date in timeZone at now and the following midnight, falling back to UTC for unknown zones
+func localDay(now time.Time, timeZone string) (string, time.Time) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	local := now.In(location)
+	year, month, day := local.Date()
+	return local.Format(time.DateOnly), time.Date(year, month, day+1, 0, 0, 0, 0, location)
+}
@@ -0,0 +1,158 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Consume(t *testing.T) {
+	ctx := context.Background()
+	// 23:30 in London is already the next day in Tokyo
+	now := time.Date(2024, 6, 1, 22, 30, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		dailyLikes          int
+		want                *Status
+		wantErr             error
+	}{
+		"limit disabled": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			dailyLikes:          0,
+			want:                &Status{Unlimited: true},
+			wantErr:             nil,
+		},
+		"like consumed in the user's time zone": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{TimeZone: "Asia/Tokyo"}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", "2024-06-02", 10).Times(1).Return(true, nil)
+			},
+			dailyLikes: 10,
+			want: &Status{
+				Limit:    10,
+				Day:      "2024-06-02",
+				ResetsAt: time.Date(2024, 6, 3, 0, 0, 0, 0, tokyo),
+			},
+			wantErr: nil,
+		},
+		"limit reached": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", "2024-06-01", 10).Times(1).Return(false, nil)
+			},
+			dailyLikes: 10,
+			want: &Status{
+				Limit:    10,
+				Used:     10,
+				Day:      "2024-06-01",
+				ResetsAt: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: ErrExhausted,
+		},
+		"entitlement overrides the limit": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{
+					Entitlement: &storage.Entitlement{DailyLikeLimit: intPtr(50)},
+				}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", "2024-06-01", 50).Times(1).Return(true, nil)
+			},
+			dailyLikes: 10,
+			want: &Status{
+				Limit:    50,
+				Day:      "2024-06-01",
+				ResetsAt: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: nil,
+		},
+		"unlimited entitlement": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{
+					Entitlement: &storage.Entitlement{},
+				}, nil)
+			},
+			dailyLikes: 10,
+			want: &Status{
+				Unlimited: true,
+				Limit:     10,
+				Day:       "2024-06-01",
+				ResetsAt:  time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: nil,
+		},
+		"entitlement with no likes": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{
+					Entitlement: &storage.Entitlement{DailyLikeLimit: intPtr(0)},
+				}, nil)
+			},
+			dailyLikes: 10,
+			want: &Status{
+				Day:      "2024-06-01",
+				ResetsAt: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			},
+			wantErr: ErrExhausted,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(nil, fmt.Errorf("storage error"))
+			},
+			dailyLikes: 10,
+			want:       nil,
+			wantErr:    fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			l := NewLimiter(mockStorage, tt.dailyLikes)
+			l.now = func() time.Time { return now }
+
+			got, err := l.Consume(ctx, "1")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestLimiter_Status(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	mockStorage.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{TimeZone: "Not/AZone"}, nil)
+	mockStorage.EXPECT().GetLikesUsed(gomock.Any(), "1", "2024-06-01").Times(1).Return(4, nil)
+
+	l := NewLimiter(mockStorage, 10)
+	l.now = func() time.Time { return now }
+
+	got, err := l.Status(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &Status{
+		Limit:    10,
+		Used:     4,
+		Day:      "2024-06-01",
+		ResetsAt: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+	}, got)
+	assert.Equal(t, 6, got.Remaining())
+}
+
+func intPtr(i int) *int {
+	return &i
+}
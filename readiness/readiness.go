@@ -0,0 +1,126 @@
+// Package readiness reports through the gRPC health service whether the server can handle requests, from checks on
+// what it depends on such as its databases. Liveness is reported separately, under its own service name, and only says
+// the process is up, so an orchestrator waits for a server whose database is down rather than restarting it.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Liveness is the service name liveness is reported under
+const Liveness = "liveness"
+
+// Check is a dependency the server needs to be ready
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Checker runs checks periodically, reporting the services as serving while they all pass
+type Checker struct {
+	server   *health.Server
+	services []string
+	checks   []Check
+	interval time.Duration
+
+	mu   sync.Mutex
+	last error
+}
+
+// NewChecker returns a Checker reporting on services, which should include "" for the server as a whole. They're
+// reported as not serving until the checks first pass, and Liveness is reported as serving straight away.
+func NewChecker(server *health.Server, services []string, checks []Check, interval time.Duration) *Checker {
+	for _, service := range services {
+		server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	server.SetServingStatus(Liveness, healthpb.HealthCheckResponse_SERVING)
+	return &Checker{
+		server:   server,
+		services: services,
+		checks:   checks,
+		interval: interval,
+		last:     fmt.Errorf("not checked yet"),
+	}
+}
+
+// Run checks straight away then every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce runs every check, each given until the next interval to finish, updates the services' status and returns
+// the failures joined together. Changes in readiness are logged.
+func (c *Checker) CheckOnce(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	var errs []error
+	for _, check := range c.checks {
+		if err := check.Run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", check.Name, err))
+		}
+	}
+	err := errors.Join(errs...)
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	//Once the health server has been shut down for draining these are ignored
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil && (c.last == nil || c.last.Error() != err.Error()):
+		slog.Warn("not ready", "error", err)
+	case err == nil && c.last != nil:
+		slog.Info("ready")
+	}
+	c.last = err
+	return err
+}
+
+// WaitFor runs check until it passes, waiting baseDelay between attempts and doubling it each time up to maxDelay, and
+// returns its last error if ctx is done first
+func WaitFor(ctx context.Context, name string, check func(ctx context.Context) error, baseDelay time.Duration, maxDelay time.Duration) error {
+	delay := baseDelay
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("gave up waiting for %s: %w", name, err)
+		}
+		slog.Info("waiting", "for", name, "retry_in", delay.String(), "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up waiting for %s: %w", name, err)
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
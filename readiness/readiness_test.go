@@ -0,0 +1,78 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func status(t *testing.T, server *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.NoError(t, err)
+	return resp.GetStatus()
+}
+
+func TestChecker_CheckOnce(t *testing.T) {
+	server := health.NewServer()
+	dbErr := fmt.Errorf("connection refused")
+	var pingErr error
+	checks := []Check{
+		{Name: "ping", Run: func(ctx context.Context) error { return pingErr }},
+		{Name: "schema", Run: func(ctx context.Context) error { return nil }},
+	}
+
+	c := NewChecker(server, []string{"", "explore.ExploreService"}, checks, time.Second)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, Liveness))
+
+	assert.NoError(t, c.CheckOnce(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, "explore.ExploreService"))
+
+	pingErr = dbErr
+	err := c.CheckOnce(context.Background())
+	assert.ErrorIs(t, err, dbErr)
+	assert.EqualError(t, err, "ping: connection refused")
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, "explore.ExploreService"))
+	//The process is still alive
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, server, Liveness))
+
+	//Draining isn't undone by checks passing
+	pingErr = nil
+	server.Shutdown()
+	assert.NoError(t, c.CheckOnce(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, server, ""))
+}
+
+func TestWaitFor(t *testing.T) {
+	t.Run("passes after failing", func(t *testing.T) {
+		attempts := 0
+		err := WaitFor(context.Background(), "db", func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return fmt.Errorf("connection refused")
+			}
+			return nil
+		}, time.Millisecond, 2*time.Millisecond)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		dbErr := fmt.Errorf("connection refused")
+
+		err := WaitFor(ctx, "db", func(ctx context.Context) error { return dbErr }, time.Millisecond, 5*time.Millisecond)
+
+		assert.ErrorIs(t, err, dbErr)
+		assert.EqualError(t, err, "gave up waiting for db: connection refused")
+	})
+}
@@ -0,0 +1,178 @@
+package recommend
+
+import (
+	"context"
+	"math"
+	"muzz-project/storage"
+	"sort"
+)
+
+// Model is an item-item collaborative filter over decisions. Users are items here too: two users are similar when
+// the same people tend to like or pass on both of them, and somebody is recommended the users most similar to the
+// ones they've liked.
+type Model struct {
+	// ratings is +1 for a like and -1 for a pass, keyed by actor then recipient
+	ratings    map[int64]map[int64]float64
+	neighbours map[int64][]neighbour
+}
+
+type neighbour struct {
+	userId     int64
+	similarity float64
+}
+
+// Train builds a Model from decisions in ID order, so the latest decision on a user wins. Only the maxNeighbours
+// most similar users are kept for each user, which bounds the size of the model and the cost of Recommend.
+func Train(decisions []*storage.Decision, maxNeighbours int) *Model {
+	ratings := make(map[int64]map[int64]float64)
+	for _, d := range decisions {
+		//Liking yourself says nothing about who you're like
+		if d.ActorID == d.RecipientID {
+			continue
+		}
+		if ratings[d.ActorID] == nil {
+			ratings[d.ActorID] = make(map[int64]float64)
+		}
+		rating := -1.0
+		if d.Liked {
+			rating = 1.0
+		}
+		ratings[d.ActorID][d.RecipientID] = rating
+	}
+
+	//Ratings are ±1 so the squared norm of a user's column is the number of decisions they've received
+	received := make(map[int64]float64)
+	dots := make(map[int64]map[int64]float64)
+	for _, rated := range ratings {
+		for i, ri := range rated {
+			received[i]++
+			for j, rj := range rated {
+				if i == j {
+					continue
+				}
+				if dots[i] == nil {
+					dots[i] = make(map[int64]float64)
+				}
+				dots[i][j] += ri * rj
+			}
+		}
+	}
+
+	neighbours := make(map[int64][]neighbour, len(dots))
+	for i, row := range dots {
+		var similar []neighbour
+		for j, dot := range row {
+			similarity := dot / math.Sqrt(received[i]*received[j])
+			if similarity > 0 {
+				similar = append(similar, neighbour{userId: j, similarity: similarity})
+			}
+		}
+		sortNeighbours(similar)
+		if len(similar) > maxNeighbours {
+			similar = similar[:maxNeighbours]
+		}
+		neighbours[i] = similar
+	}
+
+	return &Model{
+		ratings:    ratings,
+		neighbours: neighbours,
+	}
+}
+
+// Users returns everybody who has made a decision, in ID order
+func (m *Model) Users() []int64 {
+	users := make([]int64, 0, len(m.ratings))
+	for userId := range m.ratings {
+		users = append(users, userId)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	return users
+}
+
+// Recommend returns up to n users that userId hasn't made a decision on, best first. Each candidate scores the sum of
+// their similarity to the users userId liked, less their similarity to the users userId passed on.
+func (m *Model) Recommend(userId int64, n int) []*storage.Recommendation {
+	rated := m.ratings[userId]
+	scores := make(map[int64]float64)
+	for i, rating := range rated {
+		for _, nb := range m.neighbours[i] {
+			if _, ok := rated[nb.userId]; ok || nb.userId == userId {
+				continue
+			}
+			scores[nb.userId] += rating * nb.similarity
+		}
+	}
+
+	var recommended []neighbour
+	for candidateId, score := range scores {
+		if score > 0 {
+			recommended = append(recommended, neighbour{userId: candidateId, similarity: score})
+		}
+	}
+	sortNeighbours(recommended)
+	if len(recommended) > n {
+		recommended = recommended[:n]
+	}
+
+	recommendations := make([]*storage.Recommendation, len(recommended))
+	for i, r := range recommended {
+		recommendations[i] = &storage.Recommendation{CandidateID: r.userId, Score: r.similarity}
+	}
+	return recommendations
+}
+
+// sortNeighbours sorts by descending similarity, breaking ties by ID so training is deterministic
+func sortNeighbours(neighbours []neighbour) {
+	sort.Slice(neighbours, func(i, j int) bool {
+		if neighbours[i].similarity != neighbours[j].similarity {
+			return neighbours[i].similarity > neighbours[j].similarity
+		}
+		return neighbours[i].userId < neighbours[j].userId
+	})
+}
+
+// Job trains a Model on every decision and replaces each user's stored recommendations
+type Job struct {
+	storage       storage.Storage
+	batchSize     int
+	maxNeighbours int
+	topN          int
+}
+
+func NewJob(storage storage.Storage, batchSize int, maxNeighbours int, topN int) *Job {
+	return &Job{
+		storage:       storage,
+		batchSize:     batchSize,
+		maxNeighbours: maxNeighbours,
+		topN:          topN,
+	}
+}
+
+// Run returns how many users had their recommendations saved
+func (j *Job) Run(ctx context.Context) (int, error) {
+	var decisions []*storage.Decision
+	var cursor int64
+	for {
+		batch, err := j.storage.ListDecisionsAfter(ctx, cursor, j.batchSize)
+		if err != nil {
+			return 0, err
+		}
+		decisions = append(decisions, batch...)
+		if len(batch) < j.batchSize {
+			break
+		}
+		cursor = batch[len(batch)-1].ID
+	}
+
+	model := Train(decisions, j.maxNeighbours)
+
+	saved := 0
+	for _, userId := range model.Users() {
+		if err := j.storage.SaveRecommendations(ctx, userId, model.Recommend(userId, j.topN)); err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
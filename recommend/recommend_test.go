@@ -0,0 +1,151 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Recommend(t *testing.T) {
+	// Users 1 and 2 both like 10 and 11 and pass on 12. User 1 also likes 13, so user 2 should be recommended 13.
+	// User 3 likes 12, which the others pass on, so nothing liked by users 1 and 2 is similar.
+	decisions := []*storage.Decision{
+		{ID: 1, ActorID: 1, RecipientID: 10, Liked: true},
+		{ID: 2, ActorID: 1, RecipientID: 11, Liked: true},
+		{ID: 3, ActorID: 1, RecipientID: 12, Liked: false},
+		{ID: 4, ActorID: 1, RecipientID: 13, Liked: true},
+		{ID: 5, ActorID: 2, RecipientID: 10, Liked: true},
+		{ID: 6, ActorID: 2, RecipientID: 11, Liked: true},
+		{ID: 7, ActorID: 2, RecipientID: 12, Liked: false},
+		{ID: 8, ActorID: 3, RecipientID: 12, Liked: true},
+		{ID: 9, ActorID: 3, RecipientID: 14, Liked: true},
+		{ID: 10, ActorID: 4, RecipientID: 4, Liked: true},
+	}
+
+	tests := map[string]struct {
+		userId int64
+		n      int
+		want   []int64
+	}{
+		"similar to users they liked": {
+			userId: 2,
+			n:      10,
+			want:   []int64{13},
+		},
+		"not similar to users they passed on": {
+			userId: 1,
+			n:      10,
+			want:   nil,
+		},
+		"excludes users already decided on and themselves": {
+			userId: 3,
+			n:      10,
+			want:   nil,
+		},
+		"no decisions": {
+			userId: 99,
+			n:      10,
+			want:   nil,
+		},
+	}
+	model := Train(decisions, 50)
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []int64
+			for _, r := range model.Recommend(tt.userId, tt.n) {
+				got = append(got, r.CandidateID)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, []int64{1, 2, 3}, model.Users())
+}
+
+func TestModel_Recommend_TopN(t *testing.T) {
+	// User 1 has liked 10, and 11, 12 and 13 are each liked alongside 10 by one, two and three other users
+	decisions := []*storage.Decision{
+		{ID: 1, ActorID: 1, RecipientID: 10, Liked: true},
+		{ID: 2, ActorID: 2, RecipientID: 10, Liked: true},
+		{ID: 3, ActorID: 2, RecipientID: 11, Liked: true},
+		{ID: 4, ActorID: 2, RecipientID: 12, Liked: true},
+		{ID: 5, ActorID: 2, RecipientID: 13, Liked: true},
+		{ID: 6, ActorID: 3, RecipientID: 10, Liked: true},
+		{ID: 7, ActorID: 3, RecipientID: 12, Liked: true},
+		{ID: 8, ActorID: 3, RecipientID: 13, Liked: true},
+		{ID: 9, ActorID: 4, RecipientID: 10, Liked: true},
+		{ID: 10, ActorID: 4, RecipientID: 13, Liked: true},
+	}
+
+	model := Train(decisions, 50)
+
+	got := model.Recommend(1, 2)
+	assert.Len(t, got, 2)
+	assert.Equal(t, int64(13), got[0].CandidateID)
+	assert.Equal(t, int64(12), got[1].CandidateID)
+	assert.Greater(t, got[0].Score, got[1].Score)
+
+	//Only the single most similar user to 10 is kept
+	got = Train(decisions, 1).Recommend(1, 10)
+	assert.Len(t, got, 1)
+	assert.Equal(t, int64(13), got[0].CandidateID)
+}
+
+func TestJob_Run(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		want                int
+		wantErr             error
+	}{
+		"reads every decision and saves recommendations for each actor": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(0), 2).Times(1).Return([]*storage.Decision{
+					{ID: 1, ActorID: 1, RecipientID: 10, Liked: true},
+					{ID: 2, ActorID: 1, RecipientID: 11, Liked: true},
+				}, nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(2), 2).Times(1).Return([]*storage.Decision{
+					{ID: 3, ActorID: 2, RecipientID: 10, Liked: true},
+				}, nil)
+				storageMock.EXPECT().SaveRecommendations(gomock.Any(), int64(1), gomock.Len(0)).Times(1).Return(nil)
+				storageMock.EXPECT().SaveRecommendations(gomock.Any(), int64(2), []*storage.Recommendation{
+					{CandidateID: 11, Score: 1 / 1.4142135623730951},
+				}).Times(1).Return(nil)
+			},
+			want:    2,
+			wantErr: nil,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(0), 2).Times(1).Return([]*storage.Decision{
+					{ID: 1, ActorID: 1, RecipientID: 10, Liked: true},
+				}, nil)
+				storageMock.EXPECT().SaveRecommendations(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(fmt.Errorf("storage error"))
+			},
+			want:    0,
+			wantErr: fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			j := NewJob(mockStorage, 2, 50, 10)
+
+			got, err := j.Run(ctx)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package score
+
+import (
+	"context"
+	"math"
+	"muzz-project/logging"
+	"muzz-project/storage"
+	"time"
+)
+
+const (
+	// InitialScore is the rating of somebody who hasn't received any decisions
+	InitialScore = 1500.0
+	kFactor      = 32.0
+
+	// CommitLag is how old a decision has to be before it's scored. A decision's ID is handed out before its
+	// transaction commits, so one can become visible after a decision with a higher ID has already been read. Waiting
+	// for longer than a transaction can take, which is bounded by MySQL's 50s lock wait timeout, means every decision
+	// with a lower ID has committed by the time the cursor passes it.
+	CommitLag = time.Minute
+)
+
+// Job rates users from the decisions they receive, in the spirit of Elo. A like is worth more when it's unexpected,
+// because the actor is rated more highly than the recipient, and when the actor is selective. Each run only reads
+// decisions made since the previous run, once they're CommitLag old.
+type Job struct {
+	storage   storage.Storage
+	batchSize int
+	now       func() time.Time
+}
+
+func NewJob(storage storage.Storage, batchSize int) *Job {
+	return &Job{
+		storage:   storage,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+// Run calls RunOnce every interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := j.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("desirability scores failed", "decisions", processed, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce scores every decision made since the last run, a batch at a time, and returns how many it processed. If ctx
+// is cancelled it stops once the batch it's scoring is saved.
+func (j *Job) RunOnce(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	//Cancelling only stops the job between batches, so a shutdown doesn't throw away a batch that's nearly done
+	batchCtx := context.WithoutCancel(ctx)
+
+	cursor, err := j.storage.GetScoreCursor(batchCtx)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for {
+		decisions, err := j.storage.ListDecisionsAfter(batchCtx, cursor, j.batchSize)
+		if err != nil {
+			return processed, err
+		}
+		settled := j.settled(decisions)
+		if len(settled) == 0 {
+			return processed, nil
+		}
+
+		scores, err := j.scoreBatch(batchCtx, settled)
+		if err != nil {
+			return processed, err
+		}
+
+		cursor = settled[len(settled)-1].ID
+		if err := j.storage.SaveScores(batchCtx, scores, cursor); err != nil {
+			return processed, err
+		}
+		processed += len(settled)
+
+		if len(settled) < j.batchSize {
+			return processed, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+	}
+}
+
+// settled returns decisions up to the first one made less than CommitLag ago. The cursor mustn't pass a recent
+// decision, as a decision with a lower ID might not have committed yet.
+func (j *Job) settled(decisions []*storage.Decision) []*storage.Decision {
+	cutoff := j.now().Add(-CommitLag)
+	for i, d := range decisions {
+		if d.CreatedAt.After(cutoff) {
+			return decisions[:i]
+		}
+	}
+	return decisions
+}
+
+func (j *Job) scoreBatch(ctx context.Context, decisions []*storage.Decision) ([]*storage.Score, error) {
+	var userIds []int64
+	for _, d := range decisions {
+		userIds = append(userIds, d.ActorID, d.RecipientID)
+	}
+
+	scores, err := j.storage.GetScores(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(userId int64) *storage.Score {
+		if s, ok := scores[userId]; ok {
+			return s
+		}
+		s := &storage.Score{UserID: userId, Score: InitialScore}
+		scores[userId] = s
+		return s
+	}
+
+	now := j.now()
+	var changed []*storage.Score
+	seen := make(map[int64]bool)
+	for _, d := range decisions {
+		//Liking yourself says nothing about how desirable you are
+		if d.ActorID == d.RecipientID {
+			continue
+		}
+
+		actor := get(d.ActorID)
+		recipient := get(d.RecipientID)
+		Apply(actor, recipient, d.Liked)
+
+		for _, s := range []*storage.Score{actor, recipient} {
+			s.UpdatedAt = now
+			if !seen[s.UserID] {
+				seen[s.UserID] = true
+				changed = append(changed, s)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// Apply updates the scores for a single decision. Likes are weighted by how rarely the actor likes people and passes
+// by how often they do, so neither someone who likes everybody nor someone who passes on everybody moves scores much.
+func Apply(actor *storage.Score, recipient *storage.Score, liked bool) {
+	likeRate := (float64(actor.LikesGiven) + 1) / (float64(actor.DecisionsGiven) + 2)
+	expected := 1 / (1 + math.Pow(10, (actor.Score-recipient.Score)/400))
+
+	if liked {
+		recipient.Score += kFactor * (1 - likeRate) * (1 - expected)
+		actor.LikesGiven++
+	} else {
+		recipient.Score -= kFactor * likeRate * expected
+	}
+	actor.DecisionsGiven++
+}
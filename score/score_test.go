@@ -0,0 +1,183 @@
+package score
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	t.Run("like raises the recipient's score", func(t *testing.T) {
+		actor := &storage.Score{UserID: 1, Score: InitialScore}
+		recipient := &storage.Score{UserID: 2, Score: InitialScore}
+
+		Apply(actor, recipient, true)
+
+		assert.Greater(t, recipient.Score, InitialScore)
+		assert.Equal(t, InitialScore, actor.Score)
+		assert.Equal(t, int64(1), actor.LikesGiven)
+		assert.Equal(t, int64(1), actor.DecisionsGiven)
+	})
+
+	t.Run("pass lowers the recipient's score", func(t *testing.T) {
+		actor := &storage.Score{UserID: 1, Score: InitialScore}
+		recipient := &storage.Score{UserID: 2, Score: InitialScore}
+
+		Apply(actor, recipient, false)
+
+		assert.Less(t, recipient.Score, InitialScore)
+		assert.Equal(t, int64(0), actor.LikesGiven)
+		assert.Equal(t, int64(1), actor.DecisionsGiven)
+	})
+
+	t.Run("likes from selective users are worth more", func(t *testing.T) {
+		selective := &storage.Score{UserID: 1, Score: InitialScore, LikesGiven: 1, DecisionsGiven: 20}
+		indiscriminate := &storage.Score{UserID: 2, Score: InitialScore, LikesGiven: 20, DecisionsGiven: 20}
+		a := &storage.Score{UserID: 3, Score: InitialScore}
+		b := &storage.Score{UserID: 4, Score: InitialScore}
+
+		Apply(selective, a, true)
+		Apply(indiscriminate, b, true)
+
+		assert.Greater(t, a.Score, b.Score)
+	})
+
+	t.Run("likes from higher rated users are worth more", func(t *testing.T) {
+		high := &storage.Score{UserID: 1, Score: 1800}
+		low := &storage.Score{UserID: 2, Score: 1200}
+		a := &storage.Score{UserID: 3, Score: InitialScore}
+		b := &storage.Score{UserID: 4, Score: InitialScore}
+
+		Apply(high, a, true)
+		Apply(low, b, true)
+
+		assert.Greater(t, a.Score, b.Score)
+	})
+}
+
+func TestJob_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now().Add(-time.Hour)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		want                int
+		wantErr             error
+	}{
+		"resumes from the cursor a batch at a time": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(10), nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(10), 2).Times(1).Return([]*storage.Decision{
+					{ID: 11, ActorID: 1, RecipientID: 2, Liked: true},
+					{ID: 12, ActorID: 3, RecipientID: 2, Liked: false},
+				}, nil)
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1, 2, 3, 2}).Times(1).Return(map[int64]*storage.Score{
+					2: {UserID: 2, Score: 1600},
+				}, nil)
+				storageMock.EXPECT().SaveScores(gomock.Any(), gomock.Len(3), int64(12)).Times(1).Return(nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(12), 2).Times(1).Return([]*storage.Decision{
+					{ID: 13, ActorID: 9, RecipientID: 9, Liked: true},
+				}, nil)
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{9, 9}).Times(1).Return(map[int64]*storage.Score{}, nil)
+				storageMock.EXPECT().SaveScores(gomock.Any(), gomock.Len(0), int64(13)).Times(1).Return(nil)
+			},
+			want:    3,
+			wantErr: nil,
+		},
+		"nothing new": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(13), nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(13), 2).Times(1).Return(nil, nil)
+			},
+			want:    0,
+			wantErr: nil,
+		},
+		"stops at decisions that might have uncommitted ones before them": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(20), nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(20), 2).Times(1).Return([]*storage.Decision{
+					{ID: 21, ActorID: 1, RecipientID: 2, Liked: true, CreatedAt: arbitraryTime},
+					{ID: 23, ActorID: 3, RecipientID: 2, Liked: true, CreatedAt: time.Now()},
+				}, nil)
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1, 2}).Times(1).Return(map[int64]*storage.Score{}, nil)
+				//22 may still commit, so the cursor stops at 21 and 23 is left for the next run
+				storageMock.EXPECT().SaveScores(gomock.Any(), gomock.Len(2), int64(21)).Times(1).Return(nil)
+			},
+			want:    1,
+			wantErr: nil,
+		},
+		"only recent decisions": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(23), nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(23), 2).Times(1).Return([]*storage.Decision{
+					{ID: 24, ActorID: 1, RecipientID: 2, Liked: true, CreatedAt: time.Now()},
+				}, nil)
+			},
+			want:    0,
+			wantErr: nil,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(0), nil)
+				storageMock.EXPECT().ListDecisionsAfter(gomock.Any(), int64(0), 2).Times(1).Return([]*storage.Decision{
+					{ID: 1, ActorID: 1, RecipientID: 2, Liked: true, CreatedAt: arbitraryTime},
+				}, nil)
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1, 2}).Times(1).Return(map[int64]*storage.Score{}, nil)
+				storageMock.EXPECT().SaveScores(gomock.Any(), gomock.Len(2), int64(1)).Times(1).Return(fmt.Errorf("storage error"))
+			},
+			want:    0,
+			wantErr: fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			j := NewJob(mockStorage, 2)
+
+			got, err := j.RunOnce(ctx)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestJob_RunOnce_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	mockStorage.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(0), nil)
+	mockStorage.EXPECT().ListDecisionsAfter(gomock.Any(), int64(0), 2).Times(1).Return([]*storage.Decision{
+		{ID: 1, ActorID: 1, RecipientID: 2, Liked: true},
+		{ID: 2, ActorID: 3, RecipientID: 2, Liked: true},
+	}, nil)
+	mockStorage.EXPECT().GetScores(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+		//Shutting down part way through the batch
+		cancel()
+		return map[int64]*storage.Score{}, nil
+	})
+	//The batch is still saved, then the job stops instead of reading the next one
+	mockStorage.EXPECT().SaveScores(gomock.Any(), gomock.Len(3), int64(2)).Times(1).DoAndReturn(func(ctx context.Context, scores []*storage.Score, cursor int64) error {
+		return ctx.Err()
+	})
+
+	got, err := NewJob(mockStorage, 2).RunOnce(ctx)
+
+	assert.Equal(t, 2, got)
+	assert.ErrorIs(t, err, context.Canceled)
+}
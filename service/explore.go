@@ -2,26 +2,80 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"muzz-project/geo"
+	"muzz-project/logging"
+	"muzz-project/quota"
 	"muzz-project/service/protos"
 	"muzz-project/storage"
 	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	defaultLocationPrecision = 2
+	minimumAge               = 18
+	maxBoostDuration         = 24 * time.Hour
 )
 
 var (
-	badTokenError = fmt.Errorf("Token must be positive integer")
+	badTokenError         = fmt.Errorf("Token must be positive integer")
+	invalidLocationError  = status.Error(codes.InvalidArgument, "Latitude must be between -90 and 90 and longitude between -180 and 180")
+	userNotFoundError     = status.Error(codes.NotFound, "User not found")
+	locationUnknownError  = status.Error(codes.FailedPrecondition, "User must have a location to filter by distance")
+	badBirthdateError     = status.Error(codes.InvalidArgument, fmt.Sprintf("Birthdate must be a YYYY-MM-DD date at least %d years ago", minimumAge))
+	badGenderError        = status.Error(codes.InvalidArgument, "Gender must be specified")
+	badTimeZoneError      = status.Error(codes.InvalidArgument, "Time zone must be an IANA name e.g. Europe/London")
+	badPreferencesError   = status.Error(codes.InvalidArgument, fmt.Sprintf("Ages must be at least %d with max_age no less than min_age, and genders must be specified", minimumAge))
+	decisionExistsError   = status.Error(codes.AlreadyExists, "Decision already made on this user")
+	decisionFailedError   = status.Error(codes.Internal, "Decision couldn't be saved")
+	badBoostDurationError = status.Error(codes.InvalidArgument, fmt.Sprintf("Boost duration must be positive and no more than %d seconds", int(maxBoostDuration.Seconds())))
+	noBoostsError         = status.Error(codes.FailedPrecondition, "User has no boosts remaining")
+	boostActiveError      = status.Error(codes.AlreadyExists, "User already has an active boost")
+	boostNotFoundError    = status.Error(codes.NotFound, "User has never boosted")
 )
 
 type ExploreService struct {
-	storage     storage.Storage
-	maxPageSize int
+	storage           storage.Storage
+	maxPageSize       int
+	locationPrecision int
+	dailyLikeLimit    int
+	likeLimiter       *quota.Limiter
+}
+
+type Option func(*ExploreService)
+
+// WithLocationPrecision sets the number of decimal places locations are rounded to before being stored
+func WithLocationPrecision(decimals int) Option {
+	return func(e *ExploreService) {
+		e.locationPrecision = decimals
+	}
+}
+
+// WithDailyLikeLimit sets how many likes users without an entitlement can send per day, 0 means no limit
+func WithDailyLikeLimit(likes int) Option {
+	return func(e *ExploreService) {
+		e.dailyLikeLimit = likes
+	}
 }
 
-func NewExploreService(storage storage.Storage, maxPageSize int) *ExploreService {
-	return &ExploreService{
-		storage:     storage,
-		maxPageSize: maxPageSize,
+func NewExploreService(storage storage.Storage, maxPageSize int, opts ...Option) *ExploreService {
+	e := &ExploreService{
+		storage:           storage,
+		maxPageSize:       maxPageSize,
+		locationPrecision: defaultLocationPrecision,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.likeLimiter = quota.NewLimiter(storage, e.dailyLikeLimit)
+	return e
 }
 
 func (e ExploreService) ListLikedYou(ctx context.Context, in *protos.ListLikedYouRequest) (*protos.ListLikedYouResponse, error) {
@@ -31,26 +85,20 @@ func (e ExploreService) ListNewLikedYou(ctx context.Context, in *protos.ListLike
 	return e.listLikesHandler(ctx, in, e.storage.GetNewLikesForUser)
 }
 
-func (e ExploreService) listLikesHandler(ctx context.Context, in *protos.ListLikedYouRequest, dbFunction func(context.Context, string, int) ([]*storage.Decision, error)) (*protos.ListLikedYouResponse, error) {
-	var token = 0
-	var err error
-
-	if in.GetPaginationToken() != "" {
-		token, err = strconv.Atoi(in.GetPaginationToken())
-		if err != nil {
-			return nil, badTokenError
-		}
+func (e ExploreService) listLikesHandler(ctx context.Context, in *protos.ListLikedYouRequest, dbFunction func(context.Context, string, int, storage.LikesFilter) ([]*storage.Decision, error)) (*protos.ListLikedYouResponse, error) {
+	token, err := parsePaginationToken(in.GetPaginationToken())
+	if err != nil {
+		return nil, err
 	}
-	likes, err := dbFunction(ctx, in.GetRecipientUserId(), token)
+	filter := storage.LikesFilter{
+		MatchPreferences: in.GetMatchesPreferences(),
+	}
+	likes, err := dbFunction(ctx, in.GetRecipientUserId(), token, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	nextPaginationToken := ""
-
-	if len(likes) == e.maxPageSize {
-		nextPaginationToken = fmt.Sprintf("%d", token+e.maxPageSize)
-	}
+	nextPaginationToken := e.nextPaginationToken(token, len(likes))
 
 	out := &protos.ListLikedYouResponse{
 		Likers:              []*protos.ListLikedYouResponse_Liker{},
@@ -74,11 +122,268 @@ func (e ExploreService) CountLikedYou(ctx context.Context, in *protos.CountLiked
 	}, nil
 }
 func (e ExploreService) PutDecision(ctx context.Context, in *protos.PutDecisionRequest) (*protos.PutDecisionResponse, error) {
+	var likeQuota *quota.Status
+
+	if in.GetLikedRecipient() {
+		var err error
+		likeQuota, err = e.likeLimiter.Consume(ctx, in.GetActorUserId())
+		if errors.Is(err, quota.ErrExhausted) {
+			return nil, quotaExhaustedError(in.GetActorUserId(), likeQuota)
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, userNotFoundError
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	match, err := e.storage.AddDecision(ctx, in.GetActorUserId(), in.GetRecipientUserId(), in.GetLikedRecipient())
 	if err != nil {
+		if likeQuota != nil {
+			if refundErr := e.likeLimiter.Refund(ctx, in.GetActorUserId(), likeQuota); refundErr != nil {
+				logging.FromContext(ctx).Error("failed to refund a like for a decision that wasn't recorded", "error", refundErr)
+				return nil, errors.Join(err, refundErr)
+			}
+		}
+		if errors.Is(err, storage.ErrDuplicateDecision) {
+			return nil, decisionExistsError
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, userNotFoundError
+		}
+		if errors.Is(err, storage.ErrDecisionPartlySaved) {
+			return nil, decisionFailedError
+		}
 		return nil, err
 	}
 	return &protos.PutDecisionResponse{
 		MutualLikes: match,
 	}, nil
 }
+
+func (e ExploreService) GetQuota(ctx context.Context, in *protos.GetQuotaRequest) (*protos.GetQuotaResponse, error) {
+	likeQuota, err := e.likeLimiter.Status(ctx, in.GetUserId())
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, userNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if likeQuota.Unlimited {
+		return &protos.GetQuotaResponse{
+			Unlimited:          true,
+			ResetUnixTimestamp: resetUnixTimestamp(likeQuota),
+		}, nil
+	}
+	return &protos.GetQuotaResponse{
+		DailyLimit:         uint32(likeQuota.Limit),
+		LikesRemaining:     uint32(likeQuota.Remaining()),
+		ResetUnixTimestamp: resetUnixTimestamp(likeQuota),
+	}, nil
+}
+
+func (e ExploreService) ActivateBoost(ctx context.Context, in *protos.ActivateBoostRequest) (*protos.ActivateBoostResponse, error) {
+	duration := time.Duration(in.GetDurationSeconds()) * time.Second
+	if duration <= 0 || duration > maxBoostDuration {
+		return nil, badBoostDurationError
+	}
+
+	boost, err := e.storage.ActivateBoost(ctx, in.GetUserId(), duration)
+	if errors.Is(err, storage.ErrNoBoosts) {
+		return nil, noBoostsError
+	}
+	if errors.Is(err, storage.ErrBoostActive) {
+		return nil, boostActiveError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protos.ActivateBoostResponse{
+		Boost: boost.ToProto(),
+	}, nil
+}
+
+func (e ExploreService) GetBoost(ctx context.Context, in *protos.GetBoostRequest) (*protos.GetBoostResponse, error) {
+	boost, err := e.storage.GetLatestBoost(ctx, in.GetUserId())
+	if errors.Is(err, storage.ErrBoostNotFound) {
+		return nil, boostNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protos.GetBoostResponse{
+		Boost: boost.ToProto(),
+	}, nil
+}
+
+// quotaExhaustedError tells the client when they can like again, both as a RetryInfo delay and as an absolute time
+func quotaExhaustedError(userId string, likeQuota *quota.Status) error {
+	st := status.New(codes.ResourceExhausted, "Daily like limit reached")
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: "DAILY_LIKE_LIMIT_REACHED",
+			Domain: "explore",
+			Metadata: map[string]string{
+				"reset_time": likeQuota.ResetsAt.Format(time.RFC3339),
+			},
+		},
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{
+				{
+					Subject:     fmt.Sprintf("user:%s", userId),
+					Description: fmt.Sprintf("Limit of %d likes per day reached", likeQuota.Limit),
+				},
+			},
+		},
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(time.Until(likeQuota.ResetsAt).Round(time.Second)),
+		},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func resetUnixTimestamp(likeQuota *quota.Status) uint64 {
+	if likeQuota.ResetsAt.IsZero() {
+		return 0
+	}
+	return uint64(likeQuota.ResetsAt.Unix())
+}
+
+func (e ExploreService) UpdateLocation(ctx context.Context, in *protos.UpdateLocationRequest) (*protos.UpdateLocationResponse, error) {
+	if !geo.ValidCoordinates(in.GetLatitude(), in.GetLongitude()) {
+		return nil, invalidLocationError
+	}
+
+	//Only a coarse location is ever stored
+	latitude := geo.Round(in.GetLatitude(), e.locationPrecision)
+	longitude := geo.Round(in.GetLongitude(), e.locationPrecision)
+
+	err := e.storage.UpdateLocation(ctx, in.GetUserId(), latitude, longitude)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, userNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protos.UpdateLocationResponse{}, nil
+}
+
+func (e ExploreService) ListCandidates(ctx context.Context, in *protos.ListCandidatesRequest) (*protos.ListCandidatesResponse, error) {
+	token, err := parsePaginationToken(in.GetPaginationToken())
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := e.storage.GetCandidatesForUser(ctx, in.GetUserId(), float64(in.GetMaxDistanceKm()), token)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, userNotFoundError
+	}
+	if errors.Is(err, storage.ErrLocationUnknown) {
+		return nil, locationUnknownError
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nextPaginationToken := e.nextPaginationToken(token, len(candidates))
+
+	out := &protos.ListCandidatesResponse{
+		Candidates:          []*protos.ListCandidatesResponse_Candidate{},
+		NextPaginationToken: &nextPaginationToken,
+	}
+	for _, c := range candidates {
+		out.Candidates = append(out.Candidates, c.ToProto())
+	}
+	return out, nil
+}
+
+func (e ExploreService) UpdateProfile(ctx context.Context, in *protos.UpdateProfileRequest) (*protos.UpdateProfileResponse, error) {
+	birthdate, err := time.Parse(time.DateOnly, in.GetBirthdate())
+	if err != nil || birthdate.After(time.Now().AddDate(-minimumAge, 0, 0)) {
+		return nil, badBirthdateError
+	}
+	if in.GetGender() == protos.Gender_GENDER_UNSPECIFIED {
+		return nil, badGenderError
+	}
+	if _, err := time.LoadLocation(in.GetTimeZone()); err != nil {
+		return nil, badTimeZoneError
+	}
+
+	profile := &storage.Profile{
+		Birthdate: birthdate,
+		Gender:    in.GetGender(),
+		TimeZone:  in.GetTimeZone(),
+	}
+	err = e.storage.UpdateProfile(ctx, in.GetUserId(), profile)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, userNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protos.UpdateProfileResponse{}, nil
+}
+
+func (e ExploreService) GetPreferences(ctx context.Context, in *protos.GetPreferencesRequest) (*protos.GetPreferencesResponse, error) {
+	preferences, err := e.storage.GetPreferences(ctx, in.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	return &protos.GetPreferencesResponse{
+		Preferences: preferences.ToProto(),
+	}, nil
+}
+
+func (e ExploreService) UpdatePreferences(ctx context.Context, in *protos.UpdatePreferencesRequest) (*protos.UpdatePreferencesResponse, error) {
+	if !validPreferences(in.GetPreferences()) {
+		return nil, badPreferencesError
+	}
+
+	err := e.storage.UpdatePreferences(ctx, in.GetUserId(), storage.PreferencesFromProto(in.GetPreferences()))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, userNotFoundError
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protos.UpdatePreferencesResponse{}, nil
+}
+
+func validPreferences(p *protos.Preferences) bool {
+	if p.GetMinAge() != 0 && p.GetMinAge() < minimumAge {
+		return false
+	}
+	if p.GetMaxAge() != 0 && p.GetMaxAge() < max(p.GetMinAge(), minimumAge) {
+		return false
+	}
+	for _, g := range p.GetGenders() {
+		if g == protos.Gender_GENDER_UNSPECIFIED {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePaginationToken(paginationToken string) (int, error) {
+	if paginationToken == "" {
+		return 0, nil
+	}
+	token, err := strconv.Atoi(paginationToken)
+	if err != nil {
+		return 0, badTokenError
+	}
+	return token, nil
+}
+
+// nextPaginationToken is empty once a page comes back with fewer than maxPageSize results
+func (e ExploreService) nextPaginationToken(token int, pageSize int) string {
+	if pageSize == e.maxPageSize {
+		return fmt.Sprintf("%d", token+e.maxPageSize)
+	}
+	return ""
+}
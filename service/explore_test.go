@@ -12,6 +12,9 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestExploreService_listLikesHandler(t *testing.T) {
@@ -35,7 +38,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 	}{
 		"ListLikedYou returns results": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
-				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0).Times(1).Return([]*storage.Decision{
+				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return([]*storage.Decision{
 					{
 						ID:          1,
 						ActorID:     2,
@@ -64,7 +67,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 		},
 		"ListNewLikedYou returns results": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
-				storageMock.EXPECT().GetNewLikesForUser(gomock.Any(), "1", 0).Times(1).Return([]*storage.Decision{
+				storageMock.EXPECT().GetNewLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return([]*storage.Decision{
 					{
 						ID:          1,
 						ActorID:     2,
@@ -93,7 +96,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 		},
 		"ListLikedYou returns results with pagination": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
-				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 10).Times(1).Return([]*storage.Decision{
+				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 10, storage.LikesFilter{}).Times(1).Return([]*storage.Decision{
 					{
 						ID:          1,
 						ActorID:     2,
@@ -120,6 +123,37 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		"ListLikedYou filters by preferences": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{MatchPreferences: true}).Times(1).Return([]*storage.Decision{
+					{
+						ID:          1,
+						ActorID:     2,
+						RecipientID: 1,
+						Liked:       true,
+						CreatedAt:   arbitraryTime,
+						DistanceKm:  floatPtr(2.5),
+					},
+				}, nil)
+			},
+			maxPageSize: 10,
+			in: &protos.ListLikedYouRequest{
+				RecipientUserId:    "1",
+				MatchesPreferences: boolPtr(true),
+			},
+			dbFunctionName: "GetLikesForUser",
+			want: &protos.ListLikedYouResponse{
+				Likers: []*protos.ListLikedYouResponse_Liker{
+					{
+						ActorId:       "2",
+						UnixTimestamp: uint64(arbitraryTime.Unix()),
+						DistanceKm:    uint32Ptr(3),
+					},
+				},
+				NextPaginationToken: &emptyString,
+			},
+			wantErr: nil,
+		},
 		"ListLikedYou returns results with pagination error": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
 			maxPageSize:         10,
@@ -133,7 +167,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 		},
 		"ListLikedYou returns results with storage service error": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
-				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0).Times(1).Return(nil, fmt.Errorf("storage error"))
+				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return(nil, fmt.Errorf("storage error"))
 			},
 			maxPageSize: 10,
 			in: &protos.ListLikedYouRequest{
@@ -146,7 +180,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 		},
 		"ListLikedYou returns results with working pagination": {
 			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
-				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0).Times(1).
+				storageMock.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).
 					Return([]*storage.Decision{
 						{
 							ID:          1,
@@ -184,7 +218,7 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 				maxPageSize: tt.maxPageSize,
 			}
 
-			var dbFunction func(context.Context, string, int) ([]*storage.Decision, error)
+			var dbFunction func(context.Context, string, int, storage.LikesFilter) ([]*storage.Decision, error)
 
 			if tt.dbFunctionName == "GetLikesForUser" {
 				dbFunction = mockStorage.GetLikesForUser
@@ -201,6 +235,759 @@ func TestExploreService_listLikesHandler(t *testing.T) {
 	}
 }
 
+func TestExploreService_UpdateLocation(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		in                  *protos.UpdateLocationRequest
+		want                *protos.UpdateLocationResponse
+		wantErr             error
+	}{
+		"location is rounded before being stored": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdateLocation(gomock.Any(), "1", 51.51, -0.13).Times(1).Return(nil)
+			},
+			in: &protos.UpdateLocationRequest{
+				UserId:    "1",
+				Latitude:  51.5074,
+				Longitude: -0.1278,
+			},
+			want:    &protos.UpdateLocationResponse{},
+			wantErr: nil,
+		},
+		"invalid latitude": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateLocationRequest{
+				UserId:    "1",
+				Latitude:  91,
+				Longitude: 0,
+			},
+			want:    nil,
+			wantErr: invalidLocationError,
+		},
+		"invalid longitude": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateLocationRequest{
+				UserId:    "1",
+				Latitude:  0,
+				Longitude: -181,
+			},
+			want:    nil,
+			wantErr: invalidLocationError,
+		},
+		"unknown user": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdateLocation(gomock.Any(), "99", 0.0, 0.0).Times(1).Return(storage.ErrUserNotFound)
+			},
+			in: &protos.UpdateLocationRequest{
+				UserId: "99",
+			},
+			want:    nil,
+			wantErr: userNotFoundError,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdateLocation(gomock.Any(), "1", 0.0, 0.0).Times(1).Return(fmt.Errorf("storage error"))
+			},
+			in: &protos.UpdateLocationRequest{
+				UserId: "1",
+			},
+			want:    nil,
+			wantErr: fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.UpdateLocation(ctx, tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_ListCandidates(t *testing.T) {
+	emptyString := ""
+
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		maxPageSize         int
+		in                  *protos.ListCandidatesRequest
+		want                *protos.ListCandidatesResponse
+		wantErr             error
+	}{
+		"returns approximate distances": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 25.0, 0).Times(1).Return([]*storage.Candidate{
+					{UserID: 2, DistanceKm: floatPtr(0.2)},
+					{UserID: 3, DistanceKm: floatPtr(12.4)},
+				}, nil)
+			},
+			maxPageSize: 10,
+			in: &protos.ListCandidatesRequest{
+				UserId:        "1",
+				MaxDistanceKm: uint32Ptr(25),
+			},
+			want: &protos.ListCandidatesResponse{
+				Candidates: []*protos.ListCandidatesResponse_Candidate{
+					{UserId: "2", DistanceKm: uint32Ptr(1)},
+					{UserId: "3", DistanceKm: uint32Ptr(13)},
+				},
+				NextPaginationToken: &emptyString,
+			},
+			wantErr: nil,
+		},
+		"unknown distance is left unset": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 0.0, 1).Times(1).Return([]*storage.Candidate{
+					{UserID: 2},
+				}, nil)
+			},
+			maxPageSize: 1,
+			in: &protos.ListCandidatesRequest{
+				UserId:          "1",
+				PaginationToken: stringPtr("1"),
+			},
+			want: &protos.ListCandidatesResponse{
+				Candidates: []*protos.ListCandidatesResponse_Candidate{
+					{UserId: "2"},
+				},
+				NextPaginationToken: stringPtr("2"),
+			},
+			wantErr: nil,
+		},
+		"bad pagination token": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			maxPageSize:         10,
+			in: &protos.ListCandidatesRequest{
+				UserId:          "1",
+				PaginationToken: stringPtr("bad"),
+			},
+			want:    nil,
+			wantErr: badTokenError,
+		},
+		"user has no location": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 5.0, 0).Times(1).Return(nil, storage.ErrLocationUnknown)
+			},
+			maxPageSize: 10,
+			in: &protos.ListCandidatesRequest{
+				UserId:        "1",
+				MaxDistanceKm: uint32Ptr(5),
+			},
+			want:    nil,
+			wantErr: locationUnknownError,
+		},
+		"user not found": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 0.0, 0).Times(1).Return(nil, storage.ErrUserNotFound)
+			},
+			maxPageSize: 10,
+			in: &protos.ListCandidatesRequest{
+				UserId: "1",
+			},
+			want:    nil,
+			wantErr: userNotFoundError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, tt.maxPageSize)
+
+			got, err := e.ListCandidates(ctx, tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_UpdateProfile(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		in                  *protos.UpdateProfileRequest
+		want                *protos.UpdateProfileResponse
+		wantErr             error
+	}{
+		"profile updated": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdateProfile(gomock.Any(), "1", &storage.Profile{
+					Birthdate: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+					Gender:    protos.Gender_GENDER_FEMALE,
+					TimeZone:  "Europe/London",
+				}).Times(1).Return(nil)
+			},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "1",
+				Birthdate: "1990-05-17",
+				Gender:    protos.Gender_GENDER_FEMALE,
+				TimeZone:  stringPtr("Europe/London"),
+			},
+			want:    &protos.UpdateProfileResponse{},
+			wantErr: nil,
+		},
+		"unknown time zone": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "1",
+				Birthdate: "1990-05-17",
+				Gender:    protos.Gender_GENDER_FEMALE,
+				TimeZone:  stringPtr("Mars/Olympus_Mons"),
+			},
+			want:    nil,
+			wantErr: badTimeZoneError,
+		},
+		"malformed birthdate": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "1",
+				Birthdate: "17/05/1990",
+				Gender:    protos.Gender_GENDER_FEMALE,
+			},
+			want:    nil,
+			wantErr: badBirthdateError,
+		},
+		"too young": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "1",
+				Birthdate: time.Now().AddDate(-17, 0, 0).Format(time.DateOnly),
+				Gender:    protos.Gender_GENDER_FEMALE,
+			},
+			want:    nil,
+			wantErr: badBirthdateError,
+		},
+		"gender unspecified": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "1",
+				Birthdate: "1990-05-17",
+			},
+			want:    nil,
+			wantErr: badGenderError,
+		},
+		"unknown user": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdateProfile(gomock.Any(), "99", gomock.Any()).Times(1).Return(storage.ErrUserNotFound)
+			},
+			in: &protos.UpdateProfileRequest{
+				UserId:    "99",
+				Birthdate: "1990-05-17",
+				Gender:    protos.Gender_GENDER_FEMALE,
+			},
+			want:    nil,
+			wantErr: userNotFoundError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.UpdateProfile(ctx, tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_GetPreferences(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		want                *protos.GetPreferencesResponse
+		wantErr             error
+	}{
+		"preferences set": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetPreferences(gomock.Any(), "1").Times(1).Return(&storage.Preferences{
+					MinAge:  25,
+					Genders: storage.NewGenderSet(protos.Gender_GENDER_NON_BINARY, protos.Gender_GENDER_MALE),
+					Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_LONG_TERM,
+				}, nil)
+			},
+			want: &protos.GetPreferencesResponse{
+				Preferences: &protos.Preferences{
+					MinAge:  uint32Ptr(25),
+					Genders: []protos.Gender{protos.Gender_GENDER_MALE, protos.Gender_GENDER_NON_BINARY},
+					Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_LONG_TERM,
+				},
+			},
+			wantErr: nil,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetPreferences(gomock.Any(), "1").Times(1).Return(nil, fmt.Errorf("storage error"))
+			},
+			want:    nil,
+			wantErr: fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.GetPreferences(ctx, &protos.GetPreferencesRequest{UserId: "1"})
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_UpdatePreferences(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		in                  *protos.Preferences
+		want                *protos.UpdatePreferencesResponse
+		wantErr             error
+	}{
+		"preferences updated": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdatePreferences(gomock.Any(), "1", &storage.Preferences{
+					MinAge:  21,
+					MaxAge:  35,
+					Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE),
+					Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_FRIENDSHIP,
+				}).Times(1).Return(nil)
+			},
+			in: &protos.Preferences{
+				MinAge:  uint32Ptr(21),
+				MaxAge:  uint32Ptr(35),
+				Genders: []protos.Gender{protos.Gender_GENDER_FEMALE},
+				Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_FRIENDSHIP,
+			},
+			want:    &protos.UpdatePreferencesResponse{},
+			wantErr: nil,
+		},
+		"empty preferences clear every restriction": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().UpdatePreferences(gomock.Any(), "1", &storage.Preferences{}).Times(1).Return(nil)
+			},
+			in:      nil,
+			want:    &protos.UpdatePreferencesResponse{},
+			wantErr: nil,
+		},
+		"min age too low": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.Preferences{
+				MinAge: uint32Ptr(16),
+			},
+			want:    nil,
+			wantErr: badPreferencesError,
+		},
+		"max age below min age": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.Preferences{
+				MinAge: uint32Ptr(30),
+				MaxAge: uint32Ptr(25),
+			},
+			want:    nil,
+			wantErr: badPreferencesError,
+		},
+		"unspecified gender": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in: &protos.Preferences{
+				Genders: []protos.Gender{protos.Gender_GENDER_UNSPECIFIED},
+			},
+			want:    nil,
+			wantErr: badPreferencesError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.UpdatePreferences(ctx, &protos.UpdatePreferencesRequest{UserId: "1", Preferences: tt.in})
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_PutDecision(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		dailyLikeLimit      int
+		in                  *protos.PutDecisionRequest
+		want                *protos.PutDecisionResponse
+		wantCode            codes.Code
+	}{
+		"like without a limit": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(true, nil)
+			},
+			dailyLikeLimit: 0,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           &protos.PutDecisionResponse{MutualLikes: true},
+			wantCode:       codes.OK,
+		},
+		"like within the limit": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", gomock.Any(), 5).Times(1).Return(true, nil)
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, nil)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           &protos.PutDecisionResponse{MutualLikes: false},
+			wantCode:       codes.OK,
+		},
+		"passes don't use the quota": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", false).Times(1).Return(false, nil)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: false},
+			want:           &protos.PutDecisionResponse{MutualLikes: false},
+			wantCode:       codes.OK,
+		},
+		"limit reached": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", gomock.Any(), 5).Times(1).Return(false, nil)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           nil,
+			wantCode:       codes.ResourceExhausted,
+		},
+		"like is refunded when the decision isn't saved": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", gomock.Any(), 5).Times(1).Return(true, nil)
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, fmt.Errorf("storage error"))
+				storageMock.EXPECT().RefundLike(gomock.Any(), "1", gomock.Any()).Times(1).Return(nil)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           nil,
+			wantCode:       codes.Unknown,
+		},
+		"like is refunded when the decision is partly saved": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().ConsumeLike(gomock.Any(), "1", gomock.Any(), 5).Times(1).Return(true, nil)
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, fmt.Errorf("%w: lost connection", storage.ErrDecisionPartlySaved))
+				storageMock.EXPECT().RefundLike(gomock.Any(), "1", gomock.Any()).Times(1).Return(nil)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           nil,
+			wantCode:       codes.Internal,
+		},
+		"decision already made": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", false).Times(1).Return(false, storage.ErrDuplicateDecision)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: false},
+			want:           nil,
+			wantCode:       codes.AlreadyExists,
+		},
+		"unknown recipient": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().AddDecision(gomock.Any(), "1", "2", false).Times(1).Return(false, storage.ErrUserNotFound)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: false},
+			want:           nil,
+			wantCode:       codes.NotFound,
+		},
+		"unknown actor": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(nil, storage.ErrUserNotFound)
+			},
+			dailyLikeLimit: 5,
+			in:             &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true},
+			want:           nil,
+			wantCode:       codes.NotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10, WithDailyLikeLimit(tt.dailyLikeLimit))
+
+			got, err := e.PutDecision(ctx, tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestExploreService_PutDecision_LimitReachedDetails(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	mockStorage.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{TimeZone: "America/New_York"}, nil)
+	mockStorage.EXPECT().ConsumeLike(gomock.Any(), "1", gomock.Any(), 5).Times(1).Return(false, nil)
+
+	e := NewExploreService(mockStorage, 10, WithDailyLikeLimit(5))
+
+	_, err := e.PutDecision(ctx, &protos.PutDecisionRequest{ActorUserId: "1", RecipientUserId: "2", LikedRecipient: true})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Len(t, st.Details(), 3)
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	errorInfo := st.Details()[0].(*errdetails.ErrorInfo)
+	resetTime, err := time.Parse(time.RFC3339, errorInfo.GetMetadata()["reset_time"])
+	assert.NoError(t, err)
+	assert.Equal(t, 0, resetTime.In(newYork).Hour())
+	assert.True(t, resetTime.After(time.Now()))
+
+	retryInfo := st.Details()[2].(*errdetails.RetryInfo)
+	assert.LessOrEqual(t, retryInfo.GetRetryDelay().AsDuration(), 24*time.Hour)
+}
+
+func TestExploreService_GetQuota(t *testing.T) {
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		dailyLikeLimit      int
+		want                *protos.GetQuotaResponse
+		wantErr             error
+	}{
+		"likes remaining": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(&storage.QuotaSettings{}, nil)
+				storageMock.EXPECT().GetLikesUsed(gomock.Any(), "1", gomock.Any()).Times(1).Return(3, nil)
+			},
+			dailyLikeLimit: 5,
+			want: &protos.GetQuotaResponse{
+				DailyLimit:     5,
+				LikesRemaining: 2,
+			},
+			wantErr: nil,
+		},
+		"unlimited": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			dailyLikeLimit:      0,
+			want: &protos.GetQuotaResponse{
+				Unlimited: true,
+			},
+			wantErr: nil,
+		},
+		"user not found": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetQuotaSettings(gomock.Any(), "1").Times(1).Return(nil, storage.ErrUserNotFound)
+			},
+			dailyLikeLimit: 5,
+			want:           nil,
+			wantErr:        userNotFoundError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10, WithDailyLikeLimit(tt.dailyLikeLimit))
+
+			got, err := e.GetQuota(ctx, &protos.GetQuotaRequest{UserId: "1"})
+			if got != nil {
+				//reset time depends on when the test runs
+				got.ResetUnixTimestamp = 0
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_ActivateBoost(t *testing.T) {
+	ctx := context.Background()
+	startsAt := time.Now().Truncate(time.Second)
+	endsAt := startsAt.Add(30 * time.Minute)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		durationSeconds     uint32
+		want                *protos.ActivateBoostResponse
+		wantErr             error
+	}{
+		"boost activated": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().ActivateBoost(gomock.Any(), "1", 30*time.Minute).Times(1).Return(&storage.Boost{StartsAt: startsAt, EndsAt: endsAt}, nil)
+			},
+			durationSeconds: 1800,
+			want: &protos.ActivateBoostResponse{
+				Boost: &protos.Boost{
+					StartUnixTimestamp: uint64(startsAt.Unix()),
+					EndUnixTimestamp:   uint64(endsAt.Unix()),
+					Active:             true,
+				},
+			},
+			wantErr: nil,
+		},
+		"no duration": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			durationSeconds:     0,
+			want:                nil,
+			wantErr:             badBoostDurationError,
+		},
+		"too long": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			durationSeconds:     uint32(maxBoostDuration.Seconds()) + 1,
+			want:                nil,
+			wantErr:             badBoostDurationError,
+		},
+		"no boosts remaining": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().ActivateBoost(gomock.Any(), "1", 30*time.Minute).Times(1).Return(nil, storage.ErrNoBoosts)
+			},
+			durationSeconds: 1800,
+			want:            nil,
+			wantErr:         noBoostsError,
+		},
+		"boost already active": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().ActivateBoost(gomock.Any(), "1", 30*time.Minute).Times(1).Return(nil, storage.ErrBoostActive)
+			},
+			durationSeconds: 1800,
+			want:            nil,
+			wantErr:         boostActiveError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.ActivateBoost(ctx, &protos.ActivateBoostRequest{UserId: "1", DurationSeconds: tt.durationSeconds})
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestExploreService_GetBoost(t *testing.T) {
+	ctx := context.Background()
+	startsAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(time.Hour)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		want                *protos.GetBoostResponse
+		wantErr             error
+	}{
+		"expired boost": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetLatestBoost(gomock.Any(), "1").Times(1).Return(&storage.Boost{StartsAt: startsAt, EndsAt: endsAt, LikesReceived: 4}, nil)
+			},
+			want: &protos.GetBoostResponse{
+				Boost: &protos.Boost{
+					StartUnixTimestamp: uint64(startsAt.Unix()),
+					EndUnixTimestamp:   uint64(endsAt.Unix()),
+					Active:             false,
+					LikesReceived:      4,
+				},
+			},
+			wantErr: nil,
+		},
+		"never boosted": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetLatestBoost(gomock.Any(), "1").Times(1).Return(nil, storage.ErrBoostNotFound)
+			},
+			want:    nil,
+			wantErr: boostNotFoundError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreService(mockStorage, 10)
+
+			got, err := e.GetBoost(ctx, &protos.GetBoostRequest{UserId: "1"})
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
+
+func uint32Ptr(i uint32) *uint32 {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
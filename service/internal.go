@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"muzz-project/score"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	badUserIdError = status.Error(codes.InvalidArgument, "User ID must be an integer")
+)
+
+// ExploreInternalService exposes data for internal analysis that clients must never see
+type ExploreInternalService struct {
+	storage storage.Storage
+}
+
+func NewExploreInternalService(storage storage.Storage) *ExploreInternalService {
+	return &ExploreInternalService{
+		storage: storage,
+	}
+}
+
+func (e ExploreInternalService) GetDesirabilityScore(ctx context.Context, in *protos.GetDesirabilityScoreRequest) (*protos.GetDesirabilityScoreResponse, error) {
+	userId, err := strconv.ParseInt(in.GetUserId(), 10, 64)
+	if err != nil {
+		return nil, badUserIdError
+	}
+
+	scores, err := e.storage.GetScores(ctx, []int64{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := scores[userId]
+	if !ok {
+		return &protos.GetDesirabilityScoreResponse{
+			Score: score.InitialScore,
+		}, nil
+	}
+	return &protos.GetDesirabilityScoreResponse{
+		Score:                s.Score,
+		LikesGiven:           uint64(s.LikesGiven),
+		DecisionsGiven:       uint64(s.DecisionsGiven),
+		UpdatedUnixTimestamp: uint64(s.UpdatedAt.Unix()),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/score"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExploreInternalService_GetDesirabilityScore(t *testing.T) {
+	arbitraryTime := time.Now()
+
+	ctx := context.Background()
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	tests := map[string]struct {
+		mockStorageOutcomes func(storageMock *storageMock.MockStorage)
+		in                  *protos.GetDesirabilityScoreRequest
+		want                *protos.GetDesirabilityScoreResponse
+		wantErr             error
+	}{
+		"scored user": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1}).Times(1).Return(map[int64]*storage.Score{
+					1: {UserID: 1, Score: 1612.5, LikesGiven: 3, DecisionsGiven: 10, UpdatedAt: arbitraryTime},
+				}, nil)
+			},
+			in: &protos.GetDesirabilityScoreRequest{UserId: "1"},
+			want: &protos.GetDesirabilityScoreResponse{
+				Score:                1612.5,
+				LikesGiven:           3,
+				DecisionsGiven:       10,
+				UpdatedUnixTimestamp: uint64(arbitraryTime.Unix()),
+			},
+			wantErr: nil,
+		},
+		"user never scored": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1}).Times(1).Return(map[int64]*storage.Score{}, nil)
+			},
+			in: &protos.GetDesirabilityScoreRequest{UserId: "1"},
+			want: &protos.GetDesirabilityScoreResponse{
+				Score: score.InitialScore,
+			},
+			wantErr: nil,
+		},
+		"bad user id": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {},
+			in:                  &protos.GetDesirabilityScoreRequest{UserId: "bad"},
+			want:                nil,
+			wantErr:             badUserIdError,
+		},
+		"storage error": {
+			mockStorageOutcomes: func(storageMock *storageMock.MockStorage) {
+				storageMock.EXPECT().GetScores(gomock.Any(), []int64{1}).Times(1).Return(nil, fmt.Errorf("storage error"))
+			},
+			in:      &protos.GetDesirabilityScoreRequest{UserId: "1"},
+			want:    nil,
+			wantErr: fmt.Errorf("storage error"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.mockStorageOutcomes(mockStorage)
+
+			e := NewExploreInternalService(mockStorage)
+
+			got, err := e.GetDesirabilityScore(ctx, tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
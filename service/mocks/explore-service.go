@@ -36,6 +36,26 @@ func (m *MockExploreServiceClient) EXPECT() *MockExploreServiceClientMockRecorde
 	return m.recorder
 }
 
+// ActivateBoost mocks base method.
+func (m *MockExploreServiceClient) ActivateBoost(ctx context.Context, in *protos.ActivateBoostRequest, opts ...grpc.CallOption) (*protos.ActivateBoostResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ActivateBoost", varargs...)
+	ret0, _ := ret[0].(*protos.ActivateBoostResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateBoost indicates an expected call of ActivateBoost.
+func (mr *MockExploreServiceClientMockRecorder) ActivateBoost(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateBoost", reflect.TypeOf((*MockExploreServiceClient)(nil).ActivateBoost), varargs...)
+}
+
 // CountLikedYou mocks base method.
 func (m *MockExploreServiceClient) CountLikedYou(ctx context.Context, in *protos.CountLikedYouRequest, opts ...grpc.CallOption) (*protos.CountLikedYouResponse, error) {
 	m.ctrl.T.Helper()
@@ -56,6 +76,86 @@ func (mr *MockExploreServiceClientMockRecorder) CountLikedYou(ctx, in interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLikedYou", reflect.TypeOf((*MockExploreServiceClient)(nil).CountLikedYou), varargs...)
 }
 
+// GetBoost mocks base method.
+func (m *MockExploreServiceClient) GetBoost(ctx context.Context, in *protos.GetBoostRequest, opts ...grpc.CallOption) (*protos.GetBoostResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetBoost", varargs...)
+	ret0, _ := ret[0].(*protos.GetBoostResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoost indicates an expected call of GetBoost.
+func (mr *MockExploreServiceClientMockRecorder) GetBoost(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoost", reflect.TypeOf((*MockExploreServiceClient)(nil).GetBoost), varargs...)
+}
+
+// GetPreferences mocks base method.
+func (m *MockExploreServiceClient) GetPreferences(ctx context.Context, in *protos.GetPreferencesRequest, opts ...grpc.CallOption) (*protos.GetPreferencesResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPreferences", varargs...)
+	ret0, _ := ret[0].(*protos.GetPreferencesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreferences indicates an expected call of GetPreferences.
+func (mr *MockExploreServiceClientMockRecorder) GetPreferences(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreferences", reflect.TypeOf((*MockExploreServiceClient)(nil).GetPreferences), varargs...)
+}
+
+// GetQuota mocks base method.
+func (m *MockExploreServiceClient) GetQuota(ctx context.Context, in *protos.GetQuotaRequest, opts ...grpc.CallOption) (*protos.GetQuotaResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetQuota", varargs...)
+	ret0, _ := ret[0].(*protos.GetQuotaResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockExploreServiceClientMockRecorder) GetQuota(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockExploreServiceClient)(nil).GetQuota), varargs...)
+}
+
+// ListCandidates mocks base method.
+func (m *MockExploreServiceClient) ListCandidates(ctx context.Context, in *protos.ListCandidatesRequest, opts ...grpc.CallOption) (*protos.ListCandidatesResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListCandidates", varargs...)
+	ret0, _ := ret[0].(*protos.ListCandidatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCandidates indicates an expected call of ListCandidates.
+func (mr *MockExploreServiceClientMockRecorder) ListCandidates(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCandidates", reflect.TypeOf((*MockExploreServiceClient)(nil).ListCandidates), varargs...)
+}
+
 // ListLikedYou mocks base method.
 func (m *MockExploreServiceClient) ListLikedYou(ctx context.Context, in *protos.ListLikedYouRequest, opts ...grpc.CallOption) (*protos.ListLikedYouResponse, error) {
 	m.ctrl.T.Helper()
@@ -116,6 +216,66 @@ func (mr *MockExploreServiceClientMockRecorder) PutDecision(ctx, in interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutDecision", reflect.TypeOf((*MockExploreServiceClient)(nil).PutDecision), varargs...)
 }
 
+// UpdateLocation mocks base method.
+func (m *MockExploreServiceClient) UpdateLocation(ctx context.Context, in *protos.UpdateLocationRequest, opts ...grpc.CallOption) (*protos.UpdateLocationResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateLocation", varargs...)
+	ret0, _ := ret[0].(*protos.UpdateLocationResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLocation indicates an expected call of UpdateLocation.
+func (mr *MockExploreServiceClientMockRecorder) UpdateLocation(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLocation", reflect.TypeOf((*MockExploreServiceClient)(nil).UpdateLocation), varargs...)
+}
+
+// UpdatePreferences mocks base method.
+func (m *MockExploreServiceClient) UpdatePreferences(ctx context.Context, in *protos.UpdatePreferencesRequest, opts ...grpc.CallOption) (*protos.UpdatePreferencesResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdatePreferences", varargs...)
+	ret0, _ := ret[0].(*protos.UpdatePreferencesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePreferences indicates an expected call of UpdatePreferences.
+func (mr *MockExploreServiceClientMockRecorder) UpdatePreferences(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePreferences", reflect.TypeOf((*MockExploreServiceClient)(nil).UpdatePreferences), varargs...)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockExploreServiceClient) UpdateProfile(ctx context.Context, in *protos.UpdateProfileRequest, opts ...grpc.CallOption) (*protos.UpdateProfileResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateProfile", varargs...)
+	ret0, _ := ret[0].(*protos.UpdateProfileResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockExploreServiceClientMockRecorder) UpdateProfile(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockExploreServiceClient)(nil).UpdateProfile), varargs...)
+}
+
 // MockExploreServiceServer is a mock of ExploreServiceServer interface.
 type MockExploreServiceServer struct {
 	ctrl     *gomock.Controller
@@ -139,6 +299,21 @@ func (m *MockExploreServiceServer) EXPECT() *MockExploreServiceServerMockRecorde
 	return m.recorder
 }
 
+// ActivateBoost mocks base method.
+func (m *MockExploreServiceServer) ActivateBoost(arg0 context.Context, arg1 *protos.ActivateBoostRequest) (*protos.ActivateBoostResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateBoost", arg0, arg1)
+	ret0, _ := ret[0].(*protos.ActivateBoostResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateBoost indicates an expected call of ActivateBoost.
+func (mr *MockExploreServiceServerMockRecorder) ActivateBoost(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateBoost", reflect.TypeOf((*MockExploreServiceServer)(nil).ActivateBoost), arg0, arg1)
+}
+
 // CountLikedYou mocks base method.
 func (m *MockExploreServiceServer) CountLikedYou(arg0 context.Context, arg1 *protos.CountLikedYouRequest) (*protos.CountLikedYouResponse, error) {
 	m.ctrl.T.Helper()
@@ -154,6 +329,66 @@ func (mr *MockExploreServiceServerMockRecorder) CountLikedYou(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLikedYou", reflect.TypeOf((*MockExploreServiceServer)(nil).CountLikedYou), arg0, arg1)
 }
 
+// GetBoost mocks base method.
+func (m *MockExploreServiceServer) GetBoost(arg0 context.Context, arg1 *protos.GetBoostRequest) (*protos.GetBoostResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBoost", arg0, arg1)
+	ret0, _ := ret[0].(*protos.GetBoostResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBoost indicates an expected call of GetBoost.
+func (mr *MockExploreServiceServerMockRecorder) GetBoost(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBoost", reflect.TypeOf((*MockExploreServiceServer)(nil).GetBoost), arg0, arg1)
+}
+
+// GetPreferences mocks base method.
+func (m *MockExploreServiceServer) GetPreferences(arg0 context.Context, arg1 *protos.GetPreferencesRequest) (*protos.GetPreferencesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreferences", arg0, arg1)
+	ret0, _ := ret[0].(*protos.GetPreferencesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreferences indicates an expected call of GetPreferences.
+func (mr *MockExploreServiceServerMockRecorder) GetPreferences(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreferences", reflect.TypeOf((*MockExploreServiceServer)(nil).GetPreferences), arg0, arg1)
+}
+
+// GetQuota mocks base method.
+func (m *MockExploreServiceServer) GetQuota(arg0 context.Context, arg1 *protos.GetQuotaRequest) (*protos.GetQuotaResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuota", arg0, arg1)
+	ret0, _ := ret[0].(*protos.GetQuotaResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockExploreServiceServerMockRecorder) GetQuota(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockExploreServiceServer)(nil).GetQuota), arg0, arg1)
+}
+
+// ListCandidates mocks base method.
+func (m *MockExploreServiceServer) ListCandidates(arg0 context.Context, arg1 *protos.ListCandidatesRequest) (*protos.ListCandidatesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCandidates", arg0, arg1)
+	ret0, _ := ret[0].(*protos.ListCandidatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCandidates indicates an expected call of ListCandidates.
+func (mr *MockExploreServiceServerMockRecorder) ListCandidates(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCandidates", reflect.TypeOf((*MockExploreServiceServer)(nil).ListCandidates), arg0, arg1)
+}
+
 // ListLikedYou mocks base method.
 func (m *MockExploreServiceServer) ListLikedYou(arg0 context.Context, arg1 *protos.ListLikedYouRequest) (*protos.ListLikedYouResponse, error) {
 	m.ctrl.T.Helper()
@@ -199,6 +434,51 @@ func (mr *MockExploreServiceServerMockRecorder) PutDecision(arg0, arg1 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutDecision", reflect.TypeOf((*MockExploreServiceServer)(nil).PutDecision), arg0, arg1)
 }
 
+// UpdateLocation mocks base method.
+func (m *MockExploreServiceServer) UpdateLocation(arg0 context.Context, arg1 *protos.UpdateLocationRequest) (*protos.UpdateLocationResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLocation", arg0, arg1)
+	ret0, _ := ret[0].(*protos.UpdateLocationResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLocation indicates an expected call of UpdateLocation.
+func (mr *MockExploreServiceServerMockRecorder) UpdateLocation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLocation", reflect.TypeOf((*MockExploreServiceServer)(nil).UpdateLocation), arg0, arg1)
+}
+
+// UpdatePreferences mocks base method.
+func (m *MockExploreServiceServer) UpdatePreferences(arg0 context.Context, arg1 *protos.UpdatePreferencesRequest) (*protos.UpdatePreferencesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePreferences", arg0, arg1)
+	ret0, _ := ret[0].(*protos.UpdatePreferencesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePreferences indicates an expected call of UpdatePreferences.
+func (mr *MockExploreServiceServerMockRecorder) UpdatePreferences(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePreferences", reflect.TypeOf((*MockExploreServiceServer)(nil).UpdatePreferences), arg0, arg1)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockExploreServiceServer) UpdateProfile(arg0 context.Context, arg1 *protos.UpdateProfileRequest) (*protos.UpdateProfileResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", arg0, arg1)
+	ret0, _ := ret[0].(*protos.UpdateProfileResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockExploreServiceServerMockRecorder) UpdateProfile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockExploreServiceServer)(nil).UpdateProfile), arg0, arg1)
+}
+
 // MockUnsafeExploreServiceServer is a mock of UnsafeExploreServiceServer interface.
 type MockUnsafeExploreServiceServer struct {
 	ctrl     *gomock.Controller
@@ -233,3 +513,119 @@ func (mr *MockUnsafeExploreServiceServerMockRecorder) mustEmbedUnimplementedExpl
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "mustEmbedUnimplementedExploreServiceServer", reflect.TypeOf((*MockUnsafeExploreServiceServer)(nil).mustEmbedUnimplementedExploreServiceServer))
 }
+
+// MockExploreInternalServiceClient is a mock of ExploreInternalServiceClient interface.
+type MockExploreInternalServiceClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockExploreInternalServiceClientMockRecorder
+}
+
+// MockExploreInternalServiceClientMockRecorder is the mock recorder for MockExploreInternalServiceClient.
+type MockExploreInternalServiceClientMockRecorder struct {
+	mock *MockExploreInternalServiceClient
+}
+
+// NewMockExploreInternalServiceClient creates a new mock instance.
+func NewMockExploreInternalServiceClient(ctrl *gomock.Controller) *MockExploreInternalServiceClient {
+	mock := &MockExploreInternalServiceClient{ctrl: ctrl}
+	mock.recorder = &MockExploreInternalServiceClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExploreInternalServiceClient) EXPECT() *MockExploreInternalServiceClientMockRecorder {
+	return m.recorder
+}
+
+// GetDesirabilityScore mocks base method.
+func (m *MockExploreInternalServiceClient) GetDesirabilityScore(ctx context.Context, in *protos.GetDesirabilityScoreRequest, opts ...grpc.CallOption) (*protos.GetDesirabilityScoreResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetDesirabilityScore", varargs...)
+	ret0, _ := ret[0].(*protos.GetDesirabilityScoreResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDesirabilityScore indicates an expected call of GetDesirabilityScore.
+func (mr *MockExploreInternalServiceClientMockRecorder) GetDesirabilityScore(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDesirabilityScore", reflect.TypeOf((*MockExploreInternalServiceClient)(nil).GetDesirabilityScore), varargs...)
+}
+
+// MockExploreInternalServiceServer is a mock of ExploreInternalServiceServer interface.
+type MockExploreInternalServiceServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockExploreInternalServiceServerMockRecorder
+}
+
+// MockExploreInternalServiceServerMockRecorder is the mock recorder for MockExploreInternalServiceServer.
+type MockExploreInternalServiceServerMockRecorder struct {
+	mock *MockExploreInternalServiceServer
+}
+
+// NewMockExploreInternalServiceServer creates a new mock instance.
+func NewMockExploreInternalServiceServer(ctrl *gomock.Controller) *MockExploreInternalServiceServer {
+	mock := &MockExploreInternalServiceServer{ctrl: ctrl}
+	mock.recorder = &MockExploreInternalServiceServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExploreInternalServiceServer) EXPECT() *MockExploreInternalServiceServerMockRecorder {
+	return m.recorder
+}
+
+// GetDesirabilityScore mocks base method.
+func (m *MockExploreInternalServiceServer) GetDesirabilityScore(arg0 context.Context, arg1 *protos.GetDesirabilityScoreRequest) (*protos.GetDesirabilityScoreResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDesirabilityScore", arg0, arg1)
+	ret0, _ := ret[0].(*protos.GetDesirabilityScoreResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDesirabilityScore indicates an expected call of GetDesirabilityScore.
+func (mr *MockExploreInternalServiceServerMockRecorder) GetDesirabilityScore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDesirabilityScore", reflect.TypeOf((*MockExploreInternalServiceServer)(nil).GetDesirabilityScore), arg0, arg1)
+}
+
+// MockUnsafeExploreInternalServiceServer is a mock of UnsafeExploreInternalServiceServer interface.
+type MockUnsafeExploreInternalServiceServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnsafeExploreInternalServiceServerMockRecorder
+}
+
+// MockUnsafeExploreInternalServiceServerMockRecorder is the mock recorder for MockUnsafeExploreInternalServiceServer.
+type MockUnsafeExploreInternalServiceServerMockRecorder struct {
+	mock *MockUnsafeExploreInternalServiceServer
+}
+
+// NewMockUnsafeExploreInternalServiceServer creates a new mock instance.
+func NewMockUnsafeExploreInternalServiceServer(ctrl *gomock.Controller) *MockUnsafeExploreInternalServiceServer {
+	mock := &MockUnsafeExploreInternalServiceServer{ctrl: ctrl}
+	mock.recorder = &MockUnsafeExploreInternalServiceServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUnsafeExploreInternalServiceServer) EXPECT() *MockUnsafeExploreInternalServiceServerMockRecorder {
+	return m.recorder
+}
+
+// mustEmbedUnimplementedExploreInternalServiceServer mocks base method.
+func (m *MockUnsafeExploreInternalServiceServer) mustEmbedUnimplementedExploreInternalServiceServer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "mustEmbedUnimplementedExploreInternalServiceServer")
+}
+
+// mustEmbedUnimplementedExploreInternalServiceServer indicates an expected call of mustEmbedUnimplementedExploreInternalServiceServer.
+func (mr *MockUnsafeExploreInternalServiceServerMockRecorder) mustEmbedUnimplementedExploreInternalServiceServer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "mustEmbedUnimplementedExploreInternalServiceServer", reflect.TypeOf((*MockUnsafeExploreInternalServiceServer)(nil).mustEmbedUnimplementedExploreInternalServiceServer))
+}
@@ -19,10 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ExploreService_ListLikedYou_FullMethodName    = "/protos.ExploreService/ListLikedYou"
-	ExploreService_ListNewLikedYou_FullMethodName = "/protos.ExploreService/ListNewLikedYou"
-	ExploreService_CountLikedYou_FullMethodName   = "/protos.ExploreService/CountLikedYou"
-	ExploreService_PutDecision_FullMethodName     = "/protos.ExploreService/PutDecision"
+	ExploreService_ListLikedYou_FullMethodName      = "/protos.ExploreService/ListLikedYou"
+	ExploreService_ListNewLikedYou_FullMethodName   = "/protos.ExploreService/ListNewLikedYou"
+	ExploreService_CountLikedYou_FullMethodName     = "/protos.ExploreService/CountLikedYou"
+	ExploreService_PutDecision_FullMethodName       = "/protos.ExploreService/PutDecision"
+	ExploreService_UpdateLocation_FullMethodName    = "/protos.ExploreService/UpdateLocation"
+	ExploreService_ListCandidates_FullMethodName    = "/protos.ExploreService/ListCandidates"
+	ExploreService_UpdateProfile_FullMethodName     = "/protos.ExploreService/UpdateProfile"
+	ExploreService_GetPreferences_FullMethodName    = "/protos.ExploreService/GetPreferences"
+	ExploreService_UpdatePreferences_FullMethodName = "/protos.ExploreService/UpdatePreferences"
+	ExploreService_GetQuota_FullMethodName          = "/protos.ExploreService/GetQuota"
+	ExploreService_ActivateBoost_FullMethodName     = "/protos.ExploreService/ActivateBoost"
+	ExploreService_GetBoost_FullMethodName          = "/protos.ExploreService/GetBoost"
 )
 
 // ExploreServiceClient is the client API for ExploreService service.
@@ -33,6 +41,14 @@ type ExploreServiceClient interface {
 	ListNewLikedYou(ctx context.Context, in *ListLikedYouRequest, opts ...grpc.CallOption) (*ListLikedYouResponse, error)
 	CountLikedYou(ctx context.Context, in *CountLikedYouRequest, opts ...grpc.CallOption) (*CountLikedYouResponse, error)
 	PutDecision(ctx context.Context, in *PutDecisionRequest, opts ...grpc.CallOption) (*PutDecisionResponse, error)
+	UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error)
+	ListCandidates(ctx context.Context, in *ListCandidatesRequest, opts ...grpc.CallOption) (*ListCandidatesResponse, error)
+	UpdateProfile(ctx context.Context, in *UpdateProfileRequest, opts ...grpc.CallOption) (*UpdateProfileResponse, error)
+	GetPreferences(ctx context.Context, in *GetPreferencesRequest, opts ...grpc.CallOption) (*GetPreferencesResponse, error)
+	UpdatePreferences(ctx context.Context, in *UpdatePreferencesRequest, opts ...grpc.CallOption) (*UpdatePreferencesResponse, error)
+	GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error)
+	ActivateBoost(ctx context.Context, in *ActivateBoostRequest, opts ...grpc.CallOption) (*ActivateBoostResponse, error)
+	GetBoost(ctx context.Context, in *GetBoostRequest, opts ...grpc.CallOption) (*GetBoostResponse, error)
 }
 
 type exploreServiceClient struct {
@@ -83,6 +99,86 @@ func (c *exploreServiceClient) PutDecision(ctx context.Context, in *PutDecisionR
 	return out, nil
 }
 
+func (c *exploreServiceClient) UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateLocationResponse)
+	err := c.cc.Invoke(ctx, ExploreService_UpdateLocation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) ListCandidates(ctx context.Context, in *ListCandidatesRequest, opts ...grpc.CallOption) (*ListCandidatesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCandidatesResponse)
+	err := c.cc.Invoke(ctx, ExploreService_ListCandidates_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) UpdateProfile(ctx context.Context, in *UpdateProfileRequest, opts ...grpc.CallOption) (*UpdateProfileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateProfileResponse)
+	err := c.cc.Invoke(ctx, ExploreService_UpdateProfile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) GetPreferences(ctx context.Context, in *GetPreferencesRequest, opts ...grpc.CallOption) (*GetPreferencesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPreferencesResponse)
+	err := c.cc.Invoke(ctx, ExploreService_GetPreferences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) UpdatePreferences(ctx context.Context, in *UpdatePreferencesRequest, opts ...grpc.CallOption) (*UpdatePreferencesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdatePreferencesResponse)
+	err := c.cc.Invoke(ctx, ExploreService_UpdatePreferences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetQuotaResponse)
+	err := c.cc.Invoke(ctx, ExploreService_GetQuota_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) ActivateBoost(ctx context.Context, in *ActivateBoostRequest, opts ...grpc.CallOption) (*ActivateBoostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActivateBoostResponse)
+	err := c.cc.Invoke(ctx, ExploreService_ActivateBoost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exploreServiceClient) GetBoost(ctx context.Context, in *GetBoostRequest, opts ...grpc.CallOption) (*GetBoostResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetBoostResponse)
+	err := c.cc.Invoke(ctx, ExploreService_GetBoost_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ExploreServiceServer is the server API for ExploreService service.
 // All implementations should embed UnimplementedExploreServiceServer
 // for forward compatibility.
@@ -91,6 +187,14 @@ type ExploreServiceServer interface {
 	ListNewLikedYou(context.Context, *ListLikedYouRequest) (*ListLikedYouResponse, error)
 	CountLikedYou(context.Context, *CountLikedYouRequest) (*CountLikedYouResponse, error)
 	PutDecision(context.Context, *PutDecisionRequest) (*PutDecisionResponse, error)
+	UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error)
+	ListCandidates(context.Context, *ListCandidatesRequest) (*ListCandidatesResponse, error)
+	UpdateProfile(context.Context, *UpdateProfileRequest) (*UpdateProfileResponse, error)
+	GetPreferences(context.Context, *GetPreferencesRequest) (*GetPreferencesResponse, error)
+	UpdatePreferences(context.Context, *UpdatePreferencesRequest) (*UpdatePreferencesResponse, error)
+	GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error)
+	ActivateBoost(context.Context, *ActivateBoostRequest) (*ActivateBoostResponse, error)
+	GetBoost(context.Context, *GetBoostRequest) (*GetBoostResponse, error)
 }
 
 // UnimplementedExploreServiceServer should be embedded to have
@@ -112,6 +216,30 @@ func (UnimplementedExploreServiceServer) CountLikedYou(context.Context, *CountLi
 func (UnimplementedExploreServiceServer) PutDecision(context.Context, *PutDecisionRequest) (*PutDecisionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PutDecision not implemented")
 }
+func (UnimplementedExploreServiceServer) UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLocation not implemented")
+}
+func (UnimplementedExploreServiceServer) ListCandidates(context.Context, *ListCandidatesRequest) (*ListCandidatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCandidates not implemented")
+}
+func (UnimplementedExploreServiceServer) UpdateProfile(context.Context, *UpdateProfileRequest) (*UpdateProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProfile not implemented")
+}
+func (UnimplementedExploreServiceServer) GetPreferences(context.Context, *GetPreferencesRequest) (*GetPreferencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPreferences not implemented")
+}
+func (UnimplementedExploreServiceServer) UpdatePreferences(context.Context, *UpdatePreferencesRequest) (*UpdatePreferencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePreferences not implemented")
+}
+func (UnimplementedExploreServiceServer) GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuota not implemented")
+}
+func (UnimplementedExploreServiceServer) ActivateBoost(context.Context, *ActivateBoostRequest) (*ActivateBoostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ActivateBoost not implemented")
+}
+func (UnimplementedExploreServiceServer) GetBoost(context.Context, *GetBoostRequest) (*GetBoostResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBoost not implemented")
+}
 func (UnimplementedExploreServiceServer) testEmbeddedByValue() {}
 
 // UnsafeExploreServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -204,6 +332,150 @@ func _ExploreService_PutDecision_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ExploreService_UpdateLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).UpdateLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_UpdateLocation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).UpdateLocation(ctx, req.(*UpdateLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_ListCandidates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCandidatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).ListCandidates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_ListCandidates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).ListCandidates(ctx, req.(*ListCandidatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_UpdateProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).UpdateProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_UpdateProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).UpdateProfile(ctx, req.(*UpdateProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_GetPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPreferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).GetPreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_GetPreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).GetPreferences(ctx, req.(*GetPreferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_UpdatePreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePreferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).UpdatePreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_UpdatePreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).UpdatePreferences(ctx, req.(*UpdatePreferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_GetQuota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).GetQuota(ctx, req.(*GetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_ActivateBoost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActivateBoostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).ActivateBoost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_ActivateBoost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).ActivateBoost(ctx, req.(*ActivateBoostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExploreService_GetBoost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBoostRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreServiceServer).GetBoost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreService_GetBoost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreServiceServer).GetBoost(ctx, req.(*GetBoostRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ExploreService_ServiceDesc is the grpc.ServiceDesc for ExploreService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -227,6 +499,142 @@ var ExploreService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PutDecision",
 			Handler:    _ExploreService_PutDecision_Handler,
 		},
+		{
+			MethodName: "UpdateLocation",
+			Handler:    _ExploreService_UpdateLocation_Handler,
+		},
+		{
+			MethodName: "ListCandidates",
+			Handler:    _ExploreService_ListCandidates_Handler,
+		},
+		{
+			MethodName: "UpdateProfile",
+			Handler:    _ExploreService_UpdateProfile_Handler,
+		},
+		{
+			MethodName: "GetPreferences",
+			Handler:    _ExploreService_GetPreferences_Handler,
+		},
+		{
+			MethodName: "UpdatePreferences",
+			Handler:    _ExploreService_UpdatePreferences_Handler,
+		},
+		{
+			MethodName: "GetQuota",
+			Handler:    _ExploreService_GetQuota_Handler,
+		},
+		{
+			MethodName: "ActivateBoost",
+			Handler:    _ExploreService_ActivateBoost_Handler,
+		},
+		{
+			MethodName: "GetBoost",
+			Handler:    _ExploreService_GetBoost_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "explore-service.proto",
+}
+
+const (
+	ExploreInternalService_GetDesirabilityScore_FullMethodName = "/protos.ExploreInternalService/GetDesirabilityScore"
+)
+
+// ExploreInternalServiceClient is the client API for ExploreInternalService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// For analysis by internal tooling, not to be exposed to clients
+type ExploreInternalServiceClient interface {
+	GetDesirabilityScore(ctx context.Context, in *GetDesirabilityScoreRequest, opts ...grpc.CallOption) (*GetDesirabilityScoreResponse, error)
+}
+
+type exploreInternalServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExploreInternalServiceClient(cc grpc.ClientConnInterface) ExploreInternalServiceClient {
+	return &exploreInternalServiceClient{cc}
+}
+
+func (c *exploreInternalServiceClient) GetDesirabilityScore(ctx context.Context, in *GetDesirabilityScoreRequest, opts ...grpc.CallOption) (*GetDesirabilityScoreResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetDesirabilityScoreResponse)
+	err := c.cc.Invoke(ctx, ExploreInternalService_GetDesirabilityScore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExploreInternalServiceServer is the server API for ExploreInternalService service.
+// All implementations should embed UnimplementedExploreInternalServiceServer
+// for forward compatibility.
+//
+// For analysis by internal tooling, not to be exposed to clients
+type ExploreInternalServiceServer interface {
+	GetDesirabilityScore(context.Context, *GetDesirabilityScoreRequest) (*GetDesirabilityScoreResponse, error)
+}
+
+// UnimplementedExploreInternalServiceServer should be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedExploreInternalServiceServer struct{}
+
+func (UnimplementedExploreInternalServiceServer) GetDesirabilityScore(context.Context, *GetDesirabilityScoreRequest) (*GetDesirabilityScoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDesirabilityScore not implemented")
+}
+func (UnimplementedExploreInternalServiceServer) testEmbeddedByValue() {}
+
+// UnsafeExploreInternalServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExploreInternalServiceServer will
+// result in compilation errors.
+type UnsafeExploreInternalServiceServer interface {
+	mustEmbedUnimplementedExploreInternalServiceServer()
+}
+
+func RegisterExploreInternalServiceServer(s grpc.ServiceRegistrar, srv ExploreInternalServiceServer) {
+	// If the following call pancis, it indicates UnimplementedExploreInternalServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ExploreInternalService_ServiceDesc, srv)
+}
+
+func _ExploreInternalService_GetDesirabilityScore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDesirabilityScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExploreInternalServiceServer).GetDesirabilityScore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExploreInternalService_GetDesirabilityScore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExploreInternalServiceServer).GetDesirabilityScore(ctx, req.(*GetDesirabilityScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ExploreInternalService_ServiceDesc is the grpc.ServiceDesc for ExploreInternalService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ExploreInternalService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.ExploreInternalService",
+	HandlerType: (*ExploreInternalServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDesirabilityScore",
+			Handler:    _ExploreInternalService_GetDesirabilityScore_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "explore-service.proto",
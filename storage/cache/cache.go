@@ -0,0 +1,216 @@
+// Package cache is a read-through cache in front of another storage.Storage. It caches like counts and the first
+// page of likes, which are read on every badge refresh and app open, and passes everything else straight through.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"muzz-project/logging"
+	"muzz-project/storage"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCountTTL = 30 * time.Second
+	defaultLikesTTL = 30 * time.Second
+	// stripes is how many invalidation counters users are spread over
+	stripes = 64
+)
+
+// Store holds cached values, it's an interface so a shared store such as Redis can replace the in-process LRU.
+// Values are encoded so they can be stored out of process.
+type Store interface {
+	// Get returns false if key isn't cached or has expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// evictionCounter is implemented by stores that can report how many entries they've evicted
+type evictionCounter interface {
+	Evictions() uint64
+}
+
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions is always 0 if the store doesn't report them
+	Evictions uint64
+	// Errors counts failed store operations, reads that fail are served by the wrapped storage
+	Errors uint64
+}
+
+// CachedStorage caches GetLikesCountForUser and the first page of GetLikesForUser. AddDecision and UpdatePreferences
+// clear the affected user's entries on this node, other changes that affect likes, such as a liker moving, and
+// writes made by other nodes sharing a store are only seen once entries expire.
+type CachedStorage struct {
+	storage.Storage
+	store    Store
+	countTTL time.Duration
+	likesTTL time.Duration
+
+	generations [stripes]atomic.Uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+var _ storage.Storage = (*CachedStorage)(nil)
+
+type Option func(*CachedStorage)
+
+// WithCountTTL sets how long like counts are cached for
+func WithCountTTL(ttl time.Duration) Option {
+	return func(c *CachedStorage) {
+		c.countTTL = ttl
+	}
+}
+
+// WithLikesTTL sets how long first pages of likes are cached for
+func WithLikesTTL(ttl time.Duration) Option {
+	return func(c *CachedStorage) {
+		c.likesTTL = ttl
+	}
+}
+
+func NewCachedStorage(s storage.Storage, store Store, opts ...Option) *CachedStorage {
+	c := &CachedStorage{
+		Storage:  s,
+		store:    store,
+		countTTL: defaultCountTTL,
+		likesTTL: defaultLikesTTL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *CachedStorage) Stats() Stats {
+	stats := Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.errors.Load(),
+	}
+	if counter, ok := c.store.(evictionCounter); ok {
+		stats.Evictions = counter.Evictions()
+	}
+	return stats
+}
+
+func (c *CachedStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	id, ok := parseId(userId)
+	if !ok {
+		return c.Storage.GetLikesCountForUser(ctx, userId)
+	}
+
+	return readThrough(ctx, c, id, countKey(id), c.countTTL, func() (int64, error) {
+		return c.Storage.GetLikesCountForUser(ctx, userId)
+	})
+}
+
+func (c *CachedStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	id, ok := parseId(userId)
+	if !ok || paginationToken != 0 {
+		return c.Storage.GetLikesForUser(ctx, userId, paginationToken, filter)
+	}
+
+	return readThrough(ctx, c, id, likesKey(id, filter.MatchPreferences), c.likesTTL, func() ([]*storage.Decision, error) {
+		return c.Storage.GetLikesForUser(ctx, userId, paginationToken, filter)
+	})
+}
+
+func (c *CachedStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	reciprocal, err := c.Storage.AddDecision(ctx, actorId, recipientId, liked)
+	if err == nil {
+		if id, ok := parseId(recipientId); ok {
+			c.invalidate(ctx, id, countKey(id), likesKey(id, false), likesKey(id, true))
+		}
+	}
+	return reciprocal, err
+}
+
+func (c *CachedStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	err := c.Storage.UpdatePreferences(ctx, userId, preferences)
+	if err == nil {
+		if id, ok := parseId(userId); ok {
+			c.invalidate(ctx, id, likesKey(id, true))
+		}
+	}
+	return err
+}
+
+func (c *CachedStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	drifts, err := c.Storage.RepairLikeCounters(ctx)
+	for _, drift := range drifts {
+		c.invalidate(ctx, drift.UserID, countKey(drift.UserID))
+	}
+	return drifts, err
+}
+
+// readThrough returns the cached value of key, or on a miss stores what load returns. If userId's entries are
+// invalidated while load runs what it returns may already be stale, so it isn't stored.
+func readThrough[T any](ctx context.Context, c *CachedStorage, userId int64, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	var value T
+	cached, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.errors.Add(1)
+		logging.FromContext(ctx).Debug("cache read failed", "error", err)
+	}
+	if ok && json.Unmarshal(cached, &value) == nil {
+		c.hits.Add(1)
+		return value, nil
+	}
+	c.misses.Add(1)
+
+	generation := c.generation(userId).Load()
+	value, err = load()
+	if err != nil {
+		return value, err
+	}
+
+	if c.generation(userId).Load() == generation {
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			err = c.store.Set(ctx, key, encoded, ttl)
+		}
+		if err != nil {
+			c.errors.Add(1)
+			logging.FromContext(ctx).Debug("cache write failed", "error", err)
+		}
+	}
+	return value, nil
+}
+
+func (c *CachedStorage) invalidate(ctx context.Context, userId int64, keys ...string) {
+	c.generation(userId).Add(1)
+	if err := c.store.Delete(ctx, keys...); err != nil {
+		c.errors.Add(1)
+		//Entries that couldn't be deleted are served until they expire
+		logging.FromContext(ctx).Warn("cache invalidation failed", "error", err)
+	}
+}
+
+// generation returns the counter bumped whenever userId's entries are invalidated. Users share counters, so an
+// invalidation occasionally stops an unrelated entry being stored, which only costs a miss.
+func (c *CachedStorage) generation(userId int64) *atomic.Uint64 {
+	return &c.generations[uint64(userId)%stripes]
+}
+
+func countKey(userId int64) string {
+	return fmt.Sprintf("likes-count:%d", userId)
+}
+
+func likesKey(userId int64, matchPreferences bool) string {
+	return fmt.Sprintf("likes:%d:%t", userId, matchPreferences)
+}
+
+// parseId returns false for IDs that can't exist, which are passed through rather than cached
+func parseId(userId string) (int64, bool) {
+	id, err := strconv.ParseInt(userId, 10, 64)
+	return id, err == nil
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore is a store that's always unavailable
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, fmt.Errorf("unavailable")
+}
+
+func (failingStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return fmt.Errorf("unavailable")
+}
+
+func (failingStore) Delete(ctx context.Context, keys ...string) error {
+	return fmt.Errorf("unavailable")
+}
+
+func TestCachedStorage_GetLikesCountForUser(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	c := NewCachedStorage(mockStorage, NewLRU(10))
+
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(1).Return(int64(3), nil)
+	for i := 0; i < 2; i++ {
+		count, err := c.GetLikesCountForUser(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	}
+
+	//A like invalidates the recipient's count but not the actor's
+	mockStorage.EXPECT().AddDecision(gomock.Any(), "2", "1", true).Times(1).Return(false, nil)
+	_, err := c.AddDecision(ctx, "2", "1", true)
+	assert.NoError(t, err)
+
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(1).Return(int64(4), nil)
+	count, err := c.GetLikesCountForUser(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 2}, c.Stats())
+}
+
+func TestCachedStorage_GetLikesForUser(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	c := NewCachedStorage(mockStorage, NewLRU(10))
+
+	arbitraryTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	distance := 12.0
+	firstPage := []*storage.Decision{{ID: 2, ActorID: 3, RecipientID: 1, Liked: true, CreatedAt: arbitraryTime, DistanceKm: &distance}}
+	matchingPage := []*storage.Decision{{ID: 1, ActorID: 2, RecipientID: 1, Liked: true, CreatedAt: arbitraryTime}}
+
+	mockStorage.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return(firstPage, nil)
+	mockStorage.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{MatchPreferences: true}).Times(1).Return(matchingPage, nil)
+	for i := 0; i < 2; i++ {
+		likes, err := c.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, firstPage, likes)
+
+		likes, err = c.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{MatchPreferences: true})
+		assert.NoError(t, err)
+		assert.Equal(t, matchingPage, likes)
+	}
+
+	//Later pages aren't cached
+	mockStorage.EXPECT().GetLikesForUser(gomock.Any(), "1", 10, storage.LikesFilter{}).Times(2).Return(nil, nil)
+	for i := 0; i < 2; i++ {
+		_, err := c.GetLikesForUser(ctx, "1", 10, storage.LikesFilter{})
+		assert.NoError(t, err)
+	}
+
+	//Changing preferences only invalidates the filtered page
+	mockStorage.EXPECT().UpdatePreferences(gomock.Any(), "1", gomock.Any()).Times(1).Return(nil)
+	assert.NoError(t, c.UpdatePreferences(ctx, "1", &storage.Preferences{MinAge: 30}))
+
+	mockStorage.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{MatchPreferences: true}).Times(1).Return(nil, nil)
+	likes, err := c.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{MatchPreferences: true})
+	assert.NoError(t, err)
+	assert.Empty(t, likes)
+	likes, err = c.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, firstPage, likes)
+}
+
+func TestCachedStorage_Errors(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+
+	//Failed reads aren't cached
+	c := NewCachedStorage(mockStorage, NewLRU(10))
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(2).Return(int64(0), fmt.Errorf("db down"))
+	for i := 0; i < 2; i++ {
+		_, err := c.GetLikesCountForUser(ctx, "1")
+		assert.Error(t, err)
+	}
+
+	//Failed decisions don't invalidate anything
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "2").Times(1).Return(int64(1), nil)
+	mockStorage.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, storage.ErrDuplicateDecision)
+	_, err := c.GetLikesCountForUser(ctx, "2")
+	assert.NoError(t, err)
+	_, err = c.AddDecision(ctx, "1", "2", true)
+	assert.Equal(t, storage.ErrDuplicateDecision, err)
+	_, err = c.GetLikesCountForUser(ctx, "2")
+	assert.NoError(t, err)
+
+	//An unavailable store falls back to the wrapped storage
+	c = NewCachedStorage(mockStorage, failingStore{})
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "3").Times(1).Return(int64(5), nil)
+	count, err := c.GetLikesCountForUser(ctx, "3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	assert.Equal(t, Stats{Misses: 1, Errors: 2}, c.Stats())
+}
+
+func TestCachedStorage_Evictions(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	c := NewCachedStorage(mockStorage, NewLRU(1), WithCountTTL(time.Minute))
+
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), gomock.Any()).Times(3).Return(int64(1), nil)
+	for _, userId := range []string{"1", "2", "1"} {
+		_, err := c.GetLikesCountForUser(ctx, userId)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Stats{Misses: 3, Evictions: 2}, c.Stats())
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	l := NewLRU(2)
+	l.now = func() time.Time { return now }
+
+	assert.NoError(t, l.Set(ctx, "a", []byte("1"), time.Minute))
+	assert.NoError(t, l.Set(ctx, "b", []byte("2"), time.Minute))
+
+	//Reading a makes b the least recently used
+	value, ok, err := l.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	assert.NoError(t, l.Set(ctx, "c", []byte("3"), time.Second))
+	_, ok, _ = l.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), l.Evictions())
+	assert.Equal(t, 2, l.Len())
+
+	now = now.Add(time.Second)
+	_, ok, _ = l.Get(ctx, "c")
+	assert.False(t, ok, "expired")
+	assert.Equal(t, 1, l.Len())
+
+	assert.NoError(t, l.Delete(ctx, "a", "missing"))
+	_, ok, _ = l.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), l.Evictions(), "expiry and deletes aren't evictions")
+}
@@ -0,0 +1,250 @@
+// Package instrumented records how long each call to another storage.Storage takes and how it ended, as Prometheus
+// metrics, and traces each call with a span the storage's own spans are children of.
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"muzz-project/metrics"
+	"muzz-project/storage"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentation names the tracer spans are started with
+const instrumentation = "muzz-project/storage/instrumented"
+
+// expected are the errors storage returns for requests it rightly refuses, which aren't failures of the storage
+var expected = []error{
+	storage.ErrUserNotFound,
+	storage.ErrLocationUnknown,
+	storage.ErrDuplicateDecision,
+	storage.ErrNoBoosts,
+	storage.ErrBoostActive,
+	storage.ErrBoostNotFound,
+}
+
+// InstrumentedStorage times every method of the storage it wraps, labelling each call with its result: ok, rejected
+// for the storage package's own errors such as ErrUserNotFound, or error
+type InstrumentedStorage struct {
+	storage  storage.Storage
+	duration *prometheus.HistogramVec
+	tracer   trace.Tracer
+}
+
+var _ storage.Storage = (*InstrumentedStorage)(nil)
+
+type Option func(*InstrumentedStorage)
+
+// WithTracerProvider traces each call, in a span named for the method e.g. storage.GetLikesForUser, with a tracer
+// from tp. Calls aren't traced by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(i *InstrumentedStorage) {
+		i.tracer = tp.Tracer(instrumentation)
+	}
+}
+
+func NewInstrumentedStorage(s storage.Storage, reg prometheus.Registerer, opts ...Option) *InstrumentedStorage {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "storage",
+		Name:      "duration_seconds",
+		Help:      "How long storage calls took, by method and result: ok, rejected or error.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method", "result"})
+	reg.MustRegister(duration)
+	i := &InstrumentedStorage{
+		storage:  s,
+		duration: duration,
+		tracer:   noop.NewTracerProvider().Tracer(instrumentation),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// call is a call to the wrapped storage in progress
+type call struct {
+	method string
+	start  time.Time
+	span   trace.Span
+}
+
+// begin starts timing and tracing a call to method, returning ctx carrying its span
+func (i *InstrumentedStorage) begin(ctx context.Context, method string) (context.Context, call) {
+	ctx, span := i.tracer.Start(ctx, "storage."+method)
+	return ctx, call{method: method, start: time.Now(), span: span}
+}
+
+// end records how long c took and how it ended
+func (i *InstrumentedStorage) end(c call, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+		for _, e := range expected {
+			if errors.Is(err, e) {
+				result = "rejected"
+				break
+			}
+		}
+	}
+	i.duration.WithLabelValues(c.method, result).Observe(time.Since(c.start).Seconds())
+
+	if result == "error" {
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+	}
+	c.span.End()
+}
+
+func (i *InstrumentedStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	ctx, c := i.begin(ctx, "GetLikesForUser")
+	result, err := i.storage.GetLikesForUser(ctx, userId, paginationToken, filter)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	ctx, c := i.begin(ctx, "GetNewLikesForUser")
+	result, err := i.storage.GetNewLikesForUser(ctx, userId, paginationToken, filter)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	ctx, c := i.begin(ctx, "GetLikesCountForUser")
+	result, err := i.storage.GetLikesCountForUser(ctx, userId)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	ctx, c := i.begin(ctx, "AddDecision")
+	result, err := i.storage.AddDecision(ctx, actorId, recipientId, liked)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	ctx, c := i.begin(ctx, "UpdateLocation")
+	err := i.storage.UpdateLocation(ctx, userId, latitude, longitude)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	ctx, c := i.begin(ctx, "GetCandidatesForUser")
+	result, err := i.storage.GetCandidatesForUser(ctx, userId, maxDistanceKm, paginationToken)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	ctx, c := i.begin(ctx, "UpdateProfile")
+	err := i.storage.UpdateProfile(ctx, userId, profile)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	ctx, c := i.begin(ctx, "GetPreferences")
+	result, err := i.storage.GetPreferences(ctx, userId)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	ctx, c := i.begin(ctx, "UpdatePreferences")
+	err := i.storage.UpdatePreferences(ctx, userId, preferences)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	ctx, c := i.begin(ctx, "GetQuotaSettings")
+	result, err := i.storage.GetQuotaSettings(ctx, userId)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	ctx, c := i.begin(ctx, "GetLikesUsed")
+	result, err := i.storage.GetLikesUsed(ctx, userId, day)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	ctx, c := i.begin(ctx, "ConsumeLike")
+	result, err := i.storage.ConsumeLike(ctx, userId, day, limit)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	ctx, c := i.begin(ctx, "RefundLike")
+	err := i.storage.RefundLike(ctx, userId, day)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	ctx, c := i.begin(ctx, "ListDecisionsAfter")
+	result, err := i.storage.ListDecisionsAfter(ctx, afterId, limit)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	ctx, c := i.begin(ctx, "GetScores")
+	result, err := i.storage.GetScores(ctx, userIds)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	ctx, c := i.begin(ctx, "SaveScores")
+	err := i.storage.SaveScores(ctx, scores, lastDecisionId)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	ctx, c := i.begin(ctx, "GetScoreCursor")
+	result, err := i.storage.GetScoreCursor(ctx)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	ctx, c := i.begin(ctx, "SaveRecommendations")
+	err := i.storage.SaveRecommendations(ctx, userId, recommendations)
+	i.end(c, err)
+	return err
+}
+
+func (i *InstrumentedStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	ctx, c := i.begin(ctx, "ActivateBoost")
+	result, err := i.storage.ActivateBoost(ctx, userId, duration)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	ctx, c := i.begin(ctx, "GetLatestBoost")
+	result, err := i.storage.GetLatestBoost(ctx, userId)
+	i.end(c, err)
+	return result, err
+}
+
+func (i *InstrumentedStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	ctx, c := i.begin(ctx, "RepairLikeCounters")
+	result, err := i.storage.RepairLikeCounters(ctx)
+	i.end(c, err)
+	return result, err
+}
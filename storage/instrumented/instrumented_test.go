@@ -0,0 +1,113 @@
+package instrumented
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInstrumentedStorage(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	reg := prometheus.NewRegistry()
+	s := NewInstrumentedStorage(mockStorage, reg)
+
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(2).Return(int64(3), nil)
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "2").Times(1).Return(int64(0), fmt.Errorf("wrapped: %w", storage.ErrUserNotFound))
+	mockStorage.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, fmt.Errorf("connection refused"))
+
+	for i := 0; i < 2; i++ {
+		count, err := s.GetLikesCountForUser(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	}
+	_, err := s.GetLikesCountForUser(ctx, "2")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.AddDecision(ctx, "1", "2", true)
+	assert.EqualError(t, err, "connection refused")
+
+	assert.Equal(t, 3, testutil.CollectAndCount(s.duration))
+	for _, tt := range []struct {
+		method string
+		result string
+		want   uint64
+	}{
+		{"GetLikesCountForUser", "ok", 2},
+		{"GetLikesCountForUser", "rejected", 1},
+		{"AddDecision", "error", 1},
+	} {
+		assert.Equal(t, tt.want, sampleCount(t, reg, tt.method, tt.result), "%s %s", tt.method, tt.result)
+	}
+}
+
+func TestInstrumentedStorage_Tracing(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storageMock.NewMockStorage(mockCtrl)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s := NewInstrumentedStorage(mockStorage, prometheus.NewRegistry(), WithTracerProvider(tp))
+
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(1).DoAndReturn(func(ctx context.Context, userId string) (int64, error) {
+		//The storage's own spans are children of the call's
+		assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+		return 3, nil
+	})
+	mockStorage.EXPECT().GetLikesCountForUser(gomock.Any(), "2").Times(1).Return(int64(0), storage.ErrUserNotFound)
+	mockStorage.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, fmt.Errorf("connection refused"))
+
+	_, _ = s.GetLikesCountForUser(ctx, "1")
+	_, _ = s.GetLikesCountForUser(ctx, "2")
+	_, _ = s.AddDecision(ctx, "1", "2", true)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+	for i, want := range []struct {
+		name   string
+		failed bool
+	}{
+		{"storage.GetLikesCountForUser", false},
+		{"storage.GetLikesCountForUser", false},
+		{"storage.AddDecision", true},
+	} {
+		assert.Equal(t, want.name, spans[i].Name)
+		assert.Equal(t, want.failed, spans[i].Status.Code == codes.Error, want.name)
+	}
+}
+
+// sampleCount returns how many calls to method with result the storage duration histogram has observed
+func sampleCount(t *testing.T, reg *prometheus.Registry, method string, result string) uint64 {
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "explore_storage_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == method && labels["result"] == result {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
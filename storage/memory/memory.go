@@ -0,0 +1,621 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"muzz-project/geo"
+	"muzz-project/score"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUsernameTaken = fmt.Errorf("username already taken")
+)
+
+// distanceBandKm matches the MySQL candidate ranking
+const distanceBandKm = 10
+
+type user struct {
+	id        int64
+	username  string
+	firstName string
+	lastName  string
+	birthdate *time.Time
+	gender    *protos.Gender
+	timeZone  string
+	latitude  *float64
+	longitude *float64
+}
+
+type quotaKey struct {
+	userId int64
+	day    string
+}
+
+type decisionKey struct {
+	actorId     int64
+	recipientId int64
+}
+
+type boostEntitlement struct {
+	remaining int
+	expiresAt *time.Time
+}
+
+// MemoryStorage keeps everything in maps, for local development and tests that don't need a real database. It
+// behaves like MysqlStorage, including its ordering, pagination and constraint errors, and is safe for concurrent use.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	maxPageSize int
+
+	lastUserId     int64
+	lastDecisionId int64
+
+	users             map[int64]*user
+	usernames         map[string]int64
+	decisions         []*storage.Decision
+	decided           map[decisionKey]*storage.Decision
+	preferences       map[int64]storage.Preferences
+	entitlements      map[int64]storage.Entitlement
+	likesUsed         map[quotaKey]int
+	likesReceived     map[int64]int64
+	scores            map[int64]storage.Score
+	scoreCursor       int64
+	recommendations   map[int64][]int64
+	boostEntitlements map[int64]boostEntitlement
+	boosts            map[int64][]storage.Boost
+}
+
+var _ storage.Storage = (*MemoryStorage)(nil)
+
+func NewMemoryStorage(maxPageSize int) *MemoryStorage {
+	return &MemoryStorage{
+		maxPageSize:       maxPageSize,
+		users:             make(map[int64]*user),
+		usernames:         make(map[string]int64),
+		decided:           make(map[decisionKey]*storage.Decision),
+		preferences:       make(map[int64]storage.Preferences),
+		entitlements:      make(map[int64]storage.Entitlement),
+		likesUsed:         make(map[quotaKey]int),
+		likesReceived:     make(map[int64]int64),
+		scores:            make(map[int64]storage.Score),
+		recommendations:   make(map[int64][]int64),
+		boostEntitlements: make(map[int64]boostEntitlement),
+		boosts:            make(map[int64][]storage.Boost),
+	}
+}
+
+// AddUser stands in for the INSERT INTO Users that creates users outside this service, and returns the new user's ID
+func (m *MemoryStorage) AddUser(username string, firstName string, lastName string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.usernames[username]; ok {
+		return 0, ErrUsernameTaken
+	}
+
+	m.lastUserId++
+	m.users[m.lastUserId] = &user{
+		id:        m.lastUserId,
+		username:  username,
+		firstName: firstName,
+		lastName:  lastName,
+	}
+	m.usernames[username] = m.lastUserId
+	return m.lastUserId, nil
+}
+
+// SetEntitlement overrides userId's like quota, a nil entitlement removes any override
+func (m *MemoryStorage) SetEntitlement(userId int64, entitlement *storage.Entitlement) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entitlement == nil {
+		delete(m.entitlements, userId)
+		return
+	}
+	m.entitlements[userId] = *entitlement
+}
+
+// SetBoostEntitlement gives userId boosts to spend before expiresAt, nil meaning they never expire
+func (m *MemoryStorage) SetBoostEntitlement(userId int64, boosts int, expiresAt *time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.boostEntitlements[userId] = boostEntitlement{remaining: boosts, expiresAt: expiresAt}
+}
+
+func (m *MemoryStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return m.getLikes(userId, paginationToken, filter, false)
+}
+
+func (m *MemoryStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return m.getLikes(userId, paginationToken, filter, true)
+}
+
+func (m *MemoryStorage) getLikes(userId string, paginationToken int, filter storage.LikesFilter, onlyNew bool) ([]*storage.Decision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	recipientId, ok := parseId(userId)
+	if !ok {
+		return nil, nil
+	}
+
+	var likes []*storage.Decision
+	for _, d := range m.decisions {
+		if d.RecipientID != recipientId || !d.Liked {
+			continue
+		}
+		if onlyNew {
+			if _, ok := m.decided[decisionKey{actorId: recipientId, recipientId: d.ActorID}]; ok {
+				continue
+			}
+		}
+		actor, recipient := m.users[d.ActorID], m.users[d.RecipientID]
+		if filter.MatchPreferences && !matchesPreferences(m.preferences[recipient.id], actor, m.preferences[actor.id]) {
+			continue
+		}
+
+		like := *d
+		like.DistanceKm = distanceKm(actor, recipient)
+		likes = append(likes, &like)
+	}
+
+	sort.SliceStable(likes, func(i, j int) bool {
+		if !likes[i].CreatedAt.Equal(likes[j].CreatedAt) {
+			return likes[i].CreatedAt.After(likes[j].CreatedAt)
+		}
+		return likes[i].ID > likes[j].ID
+	})
+	return page(likes, paginationToken, m.maxPageSize), nil
+}
+
+func (m *MemoryStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	recipientId, _ := parseId(userId)
+	return m.likesReceived[recipientId], nil
+}
+
+func (m *MemoryStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actor, ok := m.user(actorId)
+	if !ok {
+		return false, storage.ErrUserNotFound
+	}
+	recipient, ok := m.user(recipientId)
+	if !ok {
+		return false, storage.ErrUserNotFound
+	}
+
+	key := decisionKey{actorId: actor.id, recipientId: recipient.id}
+	if _, ok := m.decided[key]; ok {
+		return false, storage.ErrDuplicateDecision
+	}
+
+	reciprocal := false
+	if liked {
+		previous, ok := m.decided[decisionKey{actorId: recipient.id, recipientId: actor.id}]
+		reciprocal = ok && previous.Liked
+	}
+
+	m.lastDecisionId++
+	decision := &storage.Decision{
+		ID:          m.lastDecisionId,
+		ActorID:     actor.id,
+		RecipientID: recipient.id,
+		Liked:       liked,
+		CreatedAt:   time.Now(),
+	}
+	m.decisions = append(m.decisions, decision)
+	m.decided[key] = decision
+	if liked {
+		m.likesReceived[recipient.id]++
+	}
+
+	return reciprocal, nil
+}
+
+func (m *MemoryStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(userId)
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	u.latitude = &latitude
+	u.longitude = &longitude
+	return nil
+}
+
+type candidate struct {
+	storage.Candidate
+	boosted bool
+	ranking int
+	score   float64
+}
+
+func (m *MemoryStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	me, ok := m.user(userId)
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	if maxDistanceKm > 0 && (me.latitude == nil || me.longitude == nil) {
+		return nil, storage.ErrLocationUnknown
+	}
+
+	rankings := make(map[int64]int)
+	for i, candidateId := range m.recommendations[me.id] {
+		rankings[candidateId] = i + 1
+	}
+	now := time.Now()
+
+	var candidates []*candidate
+	for _, u := range m.users {
+		if u.id == me.id {
+			continue
+		}
+		if _, ok := m.decided[decisionKey{actorId: me.id, recipientId: u.id}]; ok {
+			continue
+		}
+		if !matchesPreferences(m.preferences[me.id], u, m.preferences[u.id]) || !matchesPreferences(m.preferences[u.id], me, m.preferences[me.id]) {
+			continue
+		}
+
+		distance := distanceKm(me, u)
+		if maxDistanceKm > 0 && (distance == nil || *distance > maxDistanceKm) {
+			continue
+		}
+
+		c := &candidate{
+			Candidate: storage.Candidate{UserID: u.id, DistanceKm: distance},
+			boosted:   m.boosted(u.id, now),
+			ranking:   rankings[u.id],
+			score:     score.InitialScore,
+		}
+		if s, ok := m.scores[u.id]; ok {
+			c.score = s.Score
+		}
+		candidates = append(candidates, c)
+	}
+
+	//Boosted users come first, then recommended users, then nearby users ranked by desirability within each distance band
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.boosted != b.boosted {
+			return a.boosted
+		}
+		if (a.ranking == 0) != (b.ranking == 0) {
+			return a.ranking != 0
+		}
+		if a.ranking != b.ranking {
+			return a.ranking < b.ranking
+		}
+		if (a.DistanceKm == nil) != (b.DistanceKm == nil) {
+			return a.DistanceKm != nil
+		}
+		if a.DistanceKm != nil {
+			bandA, bandB := math.Floor(*a.DistanceKm/distanceBandKm), math.Floor(*b.DistanceKm/distanceBandKm)
+			if bandA != bandB {
+				return bandA < bandB
+			}
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.UserID < b.UserID
+	})
+
+	var out []*storage.Candidate
+	for _, c := range page(candidates, paginationToken, m.maxPageSize) {
+		candidate := c.Candidate
+		out = append(out, &candidate)
+	}
+	return out, nil
+}
+
+func (m *MemoryStorage) boosted(userId int64, now time.Time) bool {
+	for _, b := range m.boosts[userId] {
+		if !b.StartsAt.After(now) && b.EndsAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(userId)
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+
+	//Only the date is stored, like the DATE column
+	year, month, day := profile.Birthdate.Date()
+	birthdate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	gender := profile.Gender
+
+	u.birthdate = &birthdate
+	u.gender = &gender
+	u.timeZone = profile.TimeZone
+	return nil
+}
+
+func (m *MemoryStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, _ := parseId(userId)
+	preferences := m.preferences[id]
+	return &preferences, nil
+}
+
+func (m *MemoryStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(userId)
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	m.preferences[u.id] = *preferences
+	return nil
+}
+
+func (m *MemoryStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.user(userId)
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	settings := &storage.QuotaSettings{TimeZone: u.timeZone}
+	if e, ok := m.entitlements[u.id]; ok && (e.ExpiresAt == nil || e.ExpiresAt.After(time.Now())) {
+		settings.Entitlement = &e
+	}
+	return settings, nil
+}
+
+func (m *MemoryStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, _ := parseId(userId)
+	return m.likesUsed[quotaKey{userId: id, day: day}], nil
+}
+
+func (m *MemoryStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(userId)
+	if !ok {
+		return false, storage.ErrUserNotFound
+	}
+
+	key := quotaKey{userId: u.id, day: day}
+	used, ok := m.likesUsed[key]
+	//Like the upsert, the first like of the day is always taken
+	if ok && used >= limit {
+		return false, nil
+	}
+	m.likesUsed[key] = used + 1
+	return true, nil
+}
+
+func (m *MemoryStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, _ := parseId(userId)
+	key := quotaKey{userId: id, day: day}
+	if m.likesUsed[key] > 0 {
+		m.likesUsed[key]--
+	}
+	return nil
+}
+
+func (m *MemoryStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	//Decisions are appended in ID order
+	start := sort.Search(len(m.decisions), func(i int) bool { return m.decisions[i].ID > afterId })
+
+	var decisions []*storage.Decision
+	for _, d := range page(m.decisions[start:], 0, limit) {
+		decision := *d
+		decisions = append(decisions, &decision)
+	}
+	return decisions, nil
+}
+
+func (m *MemoryStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make(map[int64]*storage.Score)
+	for _, id := range userIds {
+		if s, ok := m.scores[id]; ok {
+			scores[id] = &s
+		}
+	}
+	return scores, nil
+}
+
+func (m *MemoryStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range scores {
+		m.scores[s.UserID] = *s
+	}
+	m.scoreCursor = lastDecisionId
+	return nil
+}
+
+func (m *MemoryStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.scoreCursor, nil
+}
+
+func (m *MemoryStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidateIds := make([]int64, len(recommendations))
+	for i, r := range recommendations {
+		candidateIds[i] = r.CandidateID
+	}
+	m.recommendations[userId] = candidateIds
+	return nil
+}
+
+func (m *MemoryStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	//Boosts are stored to the second
+	now := time.Now().Truncate(time.Second)
+
+	id, _ := parseId(userId)
+	entitlement, ok := m.boostEntitlements[id]
+	if !ok || entitlement.remaining <= 0 || (entitlement.expiresAt != nil && !entitlement.expiresAt.After(now)) {
+		return nil, storage.ErrNoBoosts
+	}
+	for _, b := range m.boosts[id] {
+		if b.EndsAt.After(now) {
+			return nil, storage.ErrBoostActive
+		}
+	}
+
+	entitlement.remaining--
+	m.boostEntitlements[id] = entitlement
+
+	boost := storage.Boost{
+		StartsAt: now,
+		EndsAt:   now.Add(duration),
+	}
+	m.boosts[id] = append(m.boosts[id], boost)
+	return &boost, nil
+}
+
+func (m *MemoryStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, _ := parseId(userId)
+	boosts := m.boosts[id]
+	if len(boosts) == 0 {
+		return nil, storage.ErrBoostNotFound
+	}
+
+	boost := boosts[len(boosts)-1]
+	for _, d := range m.decisions {
+		if d.RecipientID == id && d.Liked && !d.CreatedAt.Before(boost.StartsAt) && d.CreatedAt.Before(boost.EndsAt) {
+			boost.LikesReceived++
+		}
+	}
+	return &boost, nil
+}
+
+func (m *MemoryStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actual := make(map[int64]int64)
+	for _, d := range m.decisions {
+		if d.Liked {
+			actual[d.RecipientID]++
+		}
+	}
+
+	var drifts []*storage.LikeCounterDrift
+	for id := range m.users {
+		if m.likesReceived[id] != actual[id] {
+			drifts = append(drifts, &storage.LikeCounterDrift{UserID: id, Stored: m.likesReceived[id], Actual: actual[id]})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].UserID < drifts[j].UserID })
+
+	m.likesReceived = actual
+	return drifts, nil
+}
+
+func (m *MemoryStorage) user(userId string) (*user, bool) {
+	id, ok := parseId(userId)
+	if !ok {
+		return nil, false
+	}
+	u, ok := m.users[id]
+	return u, ok
+}
+
+func parseId(userId string) (int64, bool) {
+	id, err := strconv.ParseInt(userId, 10, 64)
+	return id, err == nil
+}
+
+// matchesPreferences is true when other fits userPrefs, see the MySQL condition of the same name
+func matchesPreferences(userPrefs storage.Preferences, other *user, otherPrefs storage.Preferences) bool {
+	if userPrefs.MinAge > 0 && (other.birthdate == nil || age(*other.birthdate) < userPrefs.MinAge) {
+		return false
+	}
+	if userPrefs.MaxAge > 0 && (other.birthdate == nil || age(*other.birthdate) > userPrefs.MaxAge) {
+		return false
+	}
+	if userPrefs.Genders != 0 && (other.gender == nil || !userPrefs.Genders.Contains(*other.gender)) {
+		return false
+	}
+	return userPrefs.Intent == 0 || otherPrefs.Intent == 0 || userPrefs.Intent == otherPrefs.Intent
+}
+
+// age is the number of whole years since birthdate, like TIMESTAMPDIFF(YEAR, birthdate, CURDATE())
+func age(birthdate time.Time) int {
+	today := time.Now().UTC()
+	years := today.Year() - birthdate.Year()
+	if today.Month() < birthdate.Month() || (today.Month() == birthdate.Month() && today.Day() < birthdate.Day()) {
+		years--
+	}
+	return years
+}
+
+func distanceKm(a *user, b *user) *float64 {
+	if a.latitude == nil || a.longitude == nil || b.latitude == nil || b.longitude == nil {
+		return nil
+	}
+	distance := geo.DistanceKm(*a.latitude, *a.longitude, *b.latitude, *b.longitude)
+	return &distance
+}
+
+// page applies LIMIT limit OFFSET offset
+func page[T any](items []T, offset int, limit int) []T {
+	if offset < 0 || offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
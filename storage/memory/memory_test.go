@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"muzz-project/storage/storagetest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMemoryStorage(t *testing.T, maxPageSize int, users int) *MemoryStorage {
+	m := NewMemoryStorage(maxPageSize)
+	for i := 1; i <= users; i++ {
+		_, err := m.AddUser(fmt.Sprintf("user%d", i), "First", "Last")
+		assert.NoError(t, err)
+	}
+	return m
+}
+
+func TestMemoryStorage_AddUser(t *testing.T) {
+	m := NewMemoryStorage(10)
+
+	id, err := m.AddUser("user1", "John", "Doe")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	_, err = m.AddUser("user1", "Jane", "Doe")
+	assert.Equal(t, ErrUsernameTaken, err)
+}
+
+func TestMemoryStorage_AddDecision(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 3)
+
+	tests := []struct {
+		name        string
+		actorId     string
+		recipientId string
+		liked       bool
+		want        bool
+		wantErr     error
+	}{
+		{name: "first like", actorId: "1", recipientId: "2", liked: true, want: false, wantErr: nil},
+		{name: "users match", actorId: "2", recipientId: "1", liked: true, want: true, wantErr: nil},
+		{name: "pass on a user who liked you", actorId: "3", recipientId: "2", liked: false, want: false, wantErr: nil},
+		{name: "like a user who passed", actorId: "2", recipientId: "3", liked: true, want: false, wantErr: nil},
+		{name: "decision already made", actorId: "1", recipientId: "2", liked: false, want: false, wantErr: storage.ErrDuplicateDecision},
+		{name: "unknown recipient", actorId: "1", recipientId: "99", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+		{name: "unknown actor", actorId: "abc", recipientId: "1", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+	}
+	//Decisions build on each other so run in order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.AddDecision(ctx, tt.actorId, tt.recipientId, tt.liked)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+
+	count, err := m.GetLikesCountForUser(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestMemoryStorage_GetLikesForUser(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 2, 5)
+
+	for _, actorId := range []string{"2", "3", "4", "5"} {
+		_, err := m.AddDecision(ctx, actorId, "1", actorId != "4")
+		assert.NoError(t, err)
+	}
+	_, err := m.AddDecision(ctx, "1", "3", false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.UpdateLocation(ctx, "1", 51.51, -0.13))
+	assert.NoError(t, m.UpdateLocation(ctx, "5", 51.55, -0.10))
+
+	actorIds := func(likes []*storage.Decision) []int64 {
+		var ids []int64
+		for _, l := range likes {
+			ids = append(ids, l.ActorID)
+		}
+		return ids
+	}
+
+	//Newest first, a page of 2 at a time
+	firstPage, err := m.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 3}, actorIds(firstPage))
+	if assert.NotNil(t, firstPage[0].DistanceKm) {
+		assert.InDelta(t, 4.9, *firstPage[0].DistanceKm, 0.1)
+	}
+	assert.Nil(t, firstPage[1].DistanceKm)
+
+	secondPage, err := m.GetLikesForUser(ctx, "1", 2, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, actorIds(secondPage))
+
+	newLikes, err := m.GetNewLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 2}, actorIds(newLikes))
+
+	//Only user 2 has the gender user 1 is looking for
+	assert.NoError(t, m.UpdateProfile(ctx, "2", &storage.Profile{Birthdate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: protos.Gender_GENDER_FEMALE}))
+	assert.NoError(t, m.UpdateProfile(ctx, "3", &storage.Profile{Birthdate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: protos.Gender_GENDER_MALE}))
+	assert.NoError(t, m.UpdatePreferences(ctx, "1", &storage.Preferences{Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE)}))
+
+	matching, err := m.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{MatchPreferences: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, actorIds(matching))
+}
+
+func TestMemoryStorage_GetCandidatesForUser(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 7)
+
+	locations := map[string][2]float64{
+		"1": {51.5074, -0.1278}, // London
+		"2": {51.5500, -0.1000}, // ~5km away
+		"3": {51.5100, -0.1300}, // <1km away
+		"4": {48.8566, 2.3522},  // Paris
+	}
+	for userId, location := range locations {
+		assert.NoError(t, m.UpdateLocation(ctx, userId, location[0], location[1]))
+	}
+	_, err := m.AddDecision(ctx, "1", "7", true)
+	assert.NoError(t, err)
+	assert.NoError(t, m.SaveScores(ctx, []*storage.Score{{UserID: 3, Score: 1400}}, 0))
+
+	candidateIds := func(candidates []*storage.Candidate) []int64 {
+		var ids []int64
+		for _, c := range candidates {
+			ids = append(ids, c.UserID)
+		}
+		return ids
+	}
+
+	//2 and 3 are in the same distance band so the more desirable 2 comes first, users without a location come last
+	all, err := m.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5, 6}, candidateIds(all))
+
+	nearby, err := m.GetCandidatesForUser(ctx, "1", 50, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, candidateIds(nearby))
+
+	//Boosted users come first, then recommendations
+	assert.NoError(t, m.SaveRecommendations(ctx, 1, []*storage.Recommendation{{CandidateID: 6}, {CandidateID: 4}}))
+	m.SetBoostEntitlement(5, 1, nil)
+	_, err = m.ActivateBoost(ctx, "5", time.Hour)
+	assert.NoError(t, err)
+
+	ranked, err := m.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 6, 4, 2, 3}, candidateIds(ranked))
+
+	secondPage, err := m.GetCandidatesForUser(ctx, "1", 0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, candidateIds(secondPage))
+
+	_, err = m.GetCandidatesForUser(ctx, "5", 50, 0)
+	assert.Equal(t, storage.ErrLocationUnknown, err)
+
+	_, err = m.GetCandidatesForUser(ctx, "99", 0, 0)
+	assert.Equal(t, storage.ErrUserNotFound, err)
+}
+
+func TestMemoryStorage_ConsumeLike(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := m.ConsumeLike(ctx, "1", "2024-06-01", 5)
+			assert.NoError(t, err)
+			if ok {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, consumed)
+
+	assert.NoError(t, m.RefundLike(ctx, "1", "2024-06-01"))
+	used, err := m.GetLikesUsed(ctx, "1", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, used)
+
+	used, err = m.GetLikesUsed(ctx, "1", "2024-06-02")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, used)
+
+	_, err = m.ConsumeLike(ctx, "2", "2024-06-01", 5)
+	assert.Equal(t, storage.ErrUserNotFound, err)
+}
+
+func TestMemoryStorage_GetQuotaSettings(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 2)
+	expired := time.Now().Add(-time.Hour)
+	limit := 50
+
+	assert.NoError(t, m.UpdateProfile(ctx, "1", &storage.Profile{TimeZone: "Asia/Tokyo"}))
+	m.SetEntitlement(1, &storage.Entitlement{DailyLikeLimit: &limit})
+	m.SetEntitlement(2, &storage.Entitlement{ExpiresAt: &expired})
+
+	settings, err := m.GetQuotaSettings(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.QuotaSettings{TimeZone: "Asia/Tokyo", Entitlement: &storage.Entitlement{DailyLikeLimit: &limit}}, settings)
+
+	settings, err = m.GetQuotaSettings(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.QuotaSettings{}, settings)
+
+	_, err = m.GetQuotaSettings(ctx, "3")
+	assert.Equal(t, storage.ErrUserNotFound, err)
+}
+
+func TestMemoryStorage_Boosts(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 3)
+
+	_, err := m.GetLatestBoost(ctx, "1")
+	assert.Equal(t, storage.ErrBoostNotFound, err)
+
+	_, err = m.ActivateBoost(ctx, "1", time.Hour)
+	assert.Equal(t, storage.ErrNoBoosts, err)
+
+	m.SetBoostEntitlement(1, 2, nil)
+	boost, err := m.ActivateBoost(ctx, "1", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, boost.EndsAt.Sub(boost.StartsAt))
+
+	_, err = m.ActivateBoost(ctx, "1", time.Hour)
+	assert.Equal(t, storage.ErrBoostActive, err)
+
+	_, err = m.AddDecision(ctx, "2", "1", true)
+	assert.NoError(t, err)
+	_, err = m.AddDecision(ctx, "3", "1", false)
+	assert.NoError(t, err)
+
+	latest, err := m.GetLatestBoost(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), latest.LikesReceived)
+}
+
+func TestMemoryStorage_Scores(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryStorage(t, 10, 3)
+
+	for _, recipientId := range []string{"2", "3"} {
+		_, err := m.AddDecision(ctx, "1", recipientId, true)
+		assert.NoError(t, err)
+	}
+	_, err := m.AddDecision(ctx, "2", "3", false)
+	assert.NoError(t, err)
+
+	decisions, err := m.ListDecisionsAfter(ctx, 1, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, int64(2), decisions[0].ID)
+	}
+
+	assert.NoError(t, m.SaveScores(ctx, []*storage.Score{{UserID: 2, Score: 1510}}, 3))
+
+	cursor, err := m.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), cursor)
+
+	scores, err := m.GetScores(ctx, []int64{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*storage.Score{2: {UserID: 2, Score: 1510}}, scores)
+}
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, maxPageSize int, users int) storage.Storage {
+		return newMemoryStorage(t, maxPageSize, users)
+	})
+}
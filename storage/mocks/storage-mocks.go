@@ -8,6 +8,7 @@ import (
 	context "context"
 	storage "muzz-project/storage"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,6 +36,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// ActivateBoost mocks base method.
+func (m *MockStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateBoost", ctx, userId, duration)
+	ret0, _ := ret[0].(*storage.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateBoost indicates an expected call of ActivateBoost.
+func (mr *MockStorageMockRecorder) ActivateBoost(ctx, userId, duration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateBoost", reflect.TypeOf((*MockStorage)(nil).ActivateBoost), ctx, userId, duration)
+}
+
 // AddDecision mocks base method.
 func (m *MockStorage) AddDecision(ctx context.Context, actorId, recipientId string, liked bool) (bool, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +66,51 @@ func (mr *MockStorageMockRecorder) AddDecision(ctx, actorId, recipientId, liked
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDecision", reflect.TypeOf((*MockStorage)(nil).AddDecision), ctx, actorId, recipientId, liked)
 }
 
+// ConsumeLike mocks base method.
+func (m *MockStorage) ConsumeLike(ctx context.Context, userId, day string, limit int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeLike", ctx, userId, day, limit)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeLike indicates an expected call of ConsumeLike.
+func (mr *MockStorageMockRecorder) ConsumeLike(ctx, userId, day, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeLike", reflect.TypeOf((*MockStorage)(nil).ConsumeLike), ctx, userId, day, limit)
+}
+
+// GetCandidatesForUser mocks base method.
+func (m *MockStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandidatesForUser", ctx, userId, maxDistanceKm, paginationToken)
+	ret0, _ := ret[0].([]*storage.Candidate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandidatesForUser indicates an expected call of GetCandidatesForUser.
+func (mr *MockStorageMockRecorder) GetCandidatesForUser(ctx, userId, maxDistanceKm, paginationToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandidatesForUser", reflect.TypeOf((*MockStorage)(nil).GetCandidatesForUser), ctx, userId, maxDistanceKm, paginationToken)
+}
+
+// GetLatestBoost mocks base method.
+func (m *MockStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestBoost", ctx, userId)
+	ret0, _ := ret[0].(*storage.Boost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestBoost indicates an expected call of GetLatestBoost.
+func (mr *MockStorageMockRecorder) GetLatestBoost(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestBoost", reflect.TypeOf((*MockStorage)(nil).GetLatestBoost), ctx, userId)
+}
+
 // GetLikesCountForUser mocks base method.
 func (m *MockStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
 	m.ctrl.T.Helper()
@@ -66,31 +127,220 @@ func (mr *MockStorageMockRecorder) GetLikesCountForUser(ctx, userId interface{})
 }
 
 // GetLikesForUser mocks base method.
-func (m *MockStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*storage.Decision, error) {
+func (m *MockStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLikesForUser", ctx, userId, paginationToken)
+	ret := m.ctrl.Call(m, "GetLikesForUser", ctx, userId, paginationToken, filter)
 	ret0, _ := ret[0].([]*storage.Decision)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLikesForUser indicates an expected call of GetLikesForUser.
-func (mr *MockStorageMockRecorder) GetLikesForUser(ctx, userId, paginationToken interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetLikesForUser(ctx, userId, paginationToken, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikesForUser", reflect.TypeOf((*MockStorage)(nil).GetLikesForUser), ctx, userId, paginationToken, filter)
+}
+
+// GetLikesUsed mocks base method.
+func (m *MockStorage) GetLikesUsed(ctx context.Context, userId, day string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLikesUsed", ctx, userId, day)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLikesUsed indicates an expected call of GetLikesUsed.
+func (mr *MockStorageMockRecorder) GetLikesUsed(ctx, userId, day interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikesForUser", reflect.TypeOf((*MockStorage)(nil).GetLikesForUser), ctx, userId, paginationToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikesUsed", reflect.TypeOf((*MockStorage)(nil).GetLikesUsed), ctx, userId, day)
 }
 
 // GetNewLikesForUser mocks base method.
-func (m *MockStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*storage.Decision, error) {
+func (m *MockStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetNewLikesForUser", ctx, userId, paginationToken)
+	ret := m.ctrl.Call(m, "GetNewLikesForUser", ctx, userId, paginationToken, filter)
 	ret0, _ := ret[0].([]*storage.Decision)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetNewLikesForUser indicates an expected call of GetNewLikesForUser.
-func (mr *MockStorageMockRecorder) GetNewLikesForUser(ctx, userId, paginationToken interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) GetNewLikesForUser(ctx, userId, paginationToken, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNewLikesForUser", reflect.TypeOf((*MockStorage)(nil).GetNewLikesForUser), ctx, userId, paginationToken, filter)
+}
+
+// GetPreferences mocks base method.
+func (m *MockStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreferences", ctx, userId)
+	ret0, _ := ret[0].(*storage.Preferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreferences indicates an expected call of GetPreferences.
+func (mr *MockStorageMockRecorder) GetPreferences(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreferences", reflect.TypeOf((*MockStorage)(nil).GetPreferences), ctx, userId)
+}
+
+// GetQuotaSettings mocks base method.
+func (m *MockStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuotaSettings", ctx, userId)
+	ret0, _ := ret[0].(*storage.QuotaSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuotaSettings indicates an expected call of GetQuotaSettings.
+func (mr *MockStorageMockRecorder) GetQuotaSettings(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotaSettings", reflect.TypeOf((*MockStorage)(nil).GetQuotaSettings), ctx, userId)
+}
+
+// GetScoreCursor mocks base method.
+func (m *MockStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScoreCursor", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScoreCursor indicates an expected call of GetScoreCursor.
+func (mr *MockStorageMockRecorder) GetScoreCursor(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScoreCursor", reflect.TypeOf((*MockStorage)(nil).GetScoreCursor), ctx)
+}
+
+// GetScores mocks base method.
+func (m *MockStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScores", ctx, userIds)
+	ret0, _ := ret[0].(map[int64]*storage.Score)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScores indicates an expected call of GetScores.
+func (mr *MockStorageMockRecorder) GetScores(ctx, userIds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScores", reflect.TypeOf((*MockStorage)(nil).GetScores), ctx, userIds)
+}
+
+// ListDecisionsAfter mocks base method.
+func (m *MockStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDecisionsAfter", ctx, afterId, limit)
+	ret0, _ := ret[0].([]*storage.Decision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDecisionsAfter indicates an expected call of ListDecisionsAfter.
+func (mr *MockStorageMockRecorder) ListDecisionsAfter(ctx, afterId, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDecisionsAfter", reflect.TypeOf((*MockStorage)(nil).ListDecisionsAfter), ctx, afterId, limit)
+}
+
+// RefundLike mocks base method.
+func (m *MockStorage) RefundLike(ctx context.Context, userId, day string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundLike", ctx, userId, day)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RefundLike indicates an expected call of RefundLike.
+func (mr *MockStorageMockRecorder) RefundLike(ctx, userId, day interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundLike", reflect.TypeOf((*MockStorage)(nil).RefundLike), ctx, userId, day)
+}
+
+// RepairLikeCounters mocks base method.
+func (m *MockStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RepairLikeCounters", ctx)
+	ret0, _ := ret[0].([]*storage.LikeCounterDrift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RepairLikeCounters indicates an expected call of RepairLikeCounters.
+func (mr *MockStorageMockRecorder) RepairLikeCounters(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RepairLikeCounters", reflect.TypeOf((*MockStorage)(nil).RepairLikeCounters), ctx)
+}
+
+// SaveRecommendations mocks base method.
+func (m *MockStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRecommendations", ctx, userId, recommendations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRecommendations indicates an expected call of SaveRecommendations.
+func (mr *MockStorageMockRecorder) SaveRecommendations(ctx, userId, recommendations interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRecommendations", reflect.TypeOf((*MockStorage)(nil).SaveRecommendations), ctx, userId, recommendations)
+}
+
+// SaveScores mocks base method.
+func (m *MockStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveScores", ctx, scores, lastDecisionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveScores indicates an expected call of SaveScores.
+func (mr *MockStorageMockRecorder) SaveScores(ctx, scores, lastDecisionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveScores", reflect.TypeOf((*MockStorage)(nil).SaveScores), ctx, scores, lastDecisionId)
+}
+
+// UpdateLocation mocks base method.
+func (m *MockStorage) UpdateLocation(ctx context.Context, userId string, latitude, longitude float64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLocation", ctx, userId, latitude, longitude)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLocation indicates an expected call of UpdateLocation.
+func (mr *MockStorageMockRecorder) UpdateLocation(ctx, userId, latitude, longitude interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLocation", reflect.TypeOf((*MockStorage)(nil).UpdateLocation), ctx, userId, latitude, longitude)
+}
+
+// UpdatePreferences mocks base method.
+func (m *MockStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePreferences", ctx, userId, preferences)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePreferences indicates an expected call of UpdatePreferences.
+func (mr *MockStorageMockRecorder) UpdatePreferences(ctx, userId, preferences interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePreferences", reflect.TypeOf((*MockStorage)(nil).UpdatePreferences), ctx, userId, preferences)
+}
+
+// UpdateProfile mocks base method.
+func (m *MockStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProfile", ctx, userId, profile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateProfile indicates an expected call of UpdateProfile.
+func (mr *MockStorageMockRecorder) UpdateProfile(ctx, userId, profile interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNewLikesForUser", reflect.TypeOf((*MockStorage)(nil).GetNewLikesForUser), ctx, userId, paginationToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockStorage)(nil).UpdateProfile), ctx, userId, profile)
 }
@@ -0,0 +1,151 @@
+// Package fsck checks the invariants of the decision graph in the main MySQL database, and repairs the violations
+// that can be fixed without guessing. Matches aren't stored, they're a pair of likes, so they can't disagree with
+// the decisions.
+package fsck
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/internal/sqlutil"
+)
+
+// repairBatchSize is how many rows a repair statement is run on at once
+const repairBatchSize = 500
+
+// Violation is a row breaking an invariant
+type Violation struct {
+	// ID is the decision or user the violation is about, depending on the check
+	ID     int64
+	Detail string
+}
+
+type Result struct {
+	Check      string
+	Violations []Violation
+	// Repaired is how many of the violations were repaired, always 0 if repairs weren't asked for or the check has none
+	Repaired int
+	// Repairable is false if the violations have to be fixed by hand
+	Repairable bool
+}
+
+type check struct {
+	name string
+	// query returns each violation's ID and detail
+	query string
+	// repair fixes the violations with the IDs in %s, rechecking them so a row fixed since it was found is left
+	// alone, or is empty if they can't be repaired safely
+	repair string
+}
+
+// userTables are the columns referring to users, other than those in Decisions. They all have foreign keys, so rows
+// on missing users are only left by loads run with foreign key checks off, such as restores.
+var userTables = []struct{ table, column string }{
+	{"LikeCounters", "user_id"},
+	{"Preferences", "user_id"},
+	{"Entitlements", "user_id"},
+	{"DailyLikeQuotas", "user_id"},
+	{"DesirabilityScores", "user_id"},
+	{"Recommendations", "user_id"},
+	{"Recommendations", "candidate_id"},
+	{"BoostEntitlements", "user_id"},
+	{"Boosts", "user_id"},
+	{"UserShards", "user_id"},
+	{"DecisionsArchive", "actor_id"},
+	{"DecisionsArchive", "recipient_id"},
+}
+
+// checks run in order, so a repair can rely on the ones before, e.g. like counters are recounted once bad decisions
+// are gone
+func checks() []check {
+	checks := []check{
+		{
+			name:   "self-decisions",
+			query:  "SELECT id, CONCAT('user ', actor_id, ' decided on themselves') FROM Decisions WHERE actor_id = recipient_id ORDER BY id",
+			repair: "DELETE FROM Decisions WHERE actor_id = recipient_id AND id IN (%s)",
+		},
+		{
+			name:   "decisions on missing users",
+			query:  "SELECT d.id, CONCAT('user ', IF(a.id IS NULL, d.actor_id, d.recipient_id), ' doesn''t exist') FROM Decisions d LEFT JOIN Users a ON a.id = d.actor_id LEFT JOIN Users r ON r.id = d.recipient_id WHERE a.id IS NULL OR r.id IS NULL ORDER BY d.id",
+			repair: "DELETE d FROM Decisions d LEFT JOIN Users a ON a.id = d.actor_id LEFT JOIN Users r ON r.id = d.recipient_id WHERE (a.id IS NULL OR r.id IS NULL) AND d.id IN (%s)",
+		},
+	}
+
+	for _, t := range userTables {
+		checks = append(checks, check{
+			name:   fmt.Sprintf("%s.%s on missing users", t.table, t.column),
+			query:  fmt.Sprintf("SELECT DISTINCT t.%[2]s, 'user doesn''t exist' FROM %[1]s t LEFT JOIN Users u ON u.id = t.%[2]s WHERE u.id IS NULL ORDER BY t.%[2]s", t.table, t.column),
+			repair: fmt.Sprintf("DELETE t FROM %[1]s t LEFT JOIN Users u ON u.id = t.%[2]s WHERE u.id IS NULL AND t.%[2]s IN (%%s)", t.table, t.column),
+		})
+	}
+
+	return append(checks,
+		check{
+			name:   "like counters",
+			query:  "SELECT user_id, CONCAT('counts ', stored, ' likes received, but there are ', actual) FROM (SELECT u.id AS user_id, COALESCE(lc.likes_received, 0) AS stored, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) AS actual FROM Users u LEFT JOIN LikeCounters lc ON lc.user_id = u.id) c WHERE stored <> actual ORDER BY user_id",
+			repair: "INSERT INTO LikeCounters (user_id, likes_received) SELECT u.id, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) FROM Users u WHERE u.id IN (%s) ON DUPLICATE KEY UPDATE likes_received = VALUES(likes_received)",
+		},
+		check{
+			//When they were really made isn't known, so these are left to be fixed by hand
+			name:  "decisions made in the future",
+			query: "SELECT id, CONCAT('made at ', created_at) FROM Decisions WHERE created_at > NOW() + INTERVAL 1 HOUR ORDER BY id",
+		},
+	)
+}
+
+// Run runs every check, repairing the violations each finds before the next check if repair is set. It returns
+// mysql.ErrSharded without checking anything once decisions are sharded, as the main database's are out of date and
+// repairs would recount the like counters from them.
+func Run(ctx context.Context, db *mysql.DB, repair bool) ([]*Result, error) {
+	if err := mysql.CheckUnsharded(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, c := range checks() {
+		result, err := runCheck(ctx, db, c, repair)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", c.name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runCheck(ctx context.Context, db *mysql.DB, c check, repair bool) (*Result, error) {
+	result := &Result{Check: c.name, Repairable: c.repair != ""}
+
+	rows, err := db.QueryContext(ctx, c.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v Violation
+		if err := rows.Scan(&v.ID, &v.Detail); err != nil {
+			return nil, err
+		}
+		result.Violations = append(result.Violations, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !repair || !result.Repairable {
+		return result, nil
+	}
+	for start := 0; start < len(result.Violations); start += repairBatchSize {
+		batch := result.Violations[start:min(start+repairBatchSize, len(result.Violations))]
+		ids := make([]any, len(batch))
+		for i, v := range batch {
+			ids[i] = v.ID
+		}
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(c.repair, sqlutil.Placeholders(len(ids))), ids...); err != nil {
+			return result, err
+		}
+		result.Repaired += len(batch)
+	}
+	return result, nil
+}
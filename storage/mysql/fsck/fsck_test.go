@@ -0,0 +1,134 @@
+package fsck
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/internal/sqlutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	violationColumns = []string{"id", "detail"}
+	shardedQuery     = regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM UserShards)")
+)
+
+func TestRun(t *testing.T) {
+	selfDecisions := sqlmock.NewRows(violationColumns).AddRow(9, "user 9 decided on themselves")
+	counters := sqlmock.NewRows(violationColumns).AddRow(1, "counts 3 likes received, but there are 2")
+	future := sqlmock.NewRows(violationColumns).AddRow(4, "made at 2030-01-01 00:00:00")
+
+	tests := map[string]struct {
+		repair bool
+		// rows are the violations each check finds, by name, the others find none
+		rows map[string]*sqlmock.Rows
+		// repairs are the IDs each check's repair is expected to be run on
+		repairs map[string][]driver.Value
+		failing string
+		want    map[string]*Result
+		wantErr error
+	}{
+		"reports without repairing": {
+			rows: map[string]*sqlmock.Rows{"self-decisions": selfDecisions, "like counters": counters},
+			want: map[string]*Result{
+				"self-decisions": {Check: "self-decisions", Violations: []Violation{{ID: 9, Detail: "user 9 decided on themselves"}}, Repairable: true},
+				"like counters":  {Check: "like counters", Violations: []Violation{{ID: 1, Detail: "counts 3 likes received, but there are 2"}}, Repairable: true},
+			},
+		},
+		"repairs what can be repaired": {
+			repair: true,
+			rows: map[string]*sqlmock.Rows{
+				"self-decisions": sqlmock.NewRows(violationColumns).AddRow(9, "user 9 decided on themselves"),
+				"Recommendations.candidate_id on missing users": sqlmock.NewRows(violationColumns).AddRow(12, "user doesn't exist").AddRow(13, "user doesn't exist"),
+				"decisions made in the future":                  future,
+			},
+			repairs: map[string][]driver.Value{
+				"self-decisions": {9},
+				"Recommendations.candidate_id on missing users": {12, 13},
+			},
+			want: map[string]*Result{
+				"self-decisions": {Check: "self-decisions", Violations: []Violation{{ID: 9, Detail: "user 9 decided on themselves"}}, Repaired: 1, Repairable: true},
+				"Recommendations.candidate_id on missing users": {
+					Check:      "Recommendations.candidate_id on missing users",
+					Violations: []Violation{{ID: 12, Detail: "user doesn't exist"}, {ID: 13, Detail: "user doesn't exist"}},
+					Repaired:   2,
+					Repairable: true,
+				},
+				"decisions made in the future": {Check: "decisions made in the future", Violations: []Violation{{ID: 4, Detail: "made at 2030-01-01 00:00:00"}}},
+			},
+		},
+		"stops at a failing check": {
+			failing: "decisions on missing users",
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			mock.ExpectQuery(shardedQuery).WillReturnRows(sqlmock.NewRows([]string{"sharded"}).AddRow(false))
+			var ran []string
+			for _, c := range checks() {
+				ran = append(ran, c.name)
+				query := mock.ExpectQuery(regexp.QuoteMeta(c.query))
+				if c.name == tt.failing {
+					query.WillReturnError(tt.wantErr)
+					break
+				}
+				rows, ok := tt.rows[c.name]
+				if !ok {
+					rows = sqlmock.NewRows(violationColumns)
+				}
+				query.WillReturnRows(rows)
+
+				if ids, ok := tt.repairs[c.name]; ok {
+					mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(c.repair, sqlutil.Placeholders(len(ids))))).
+						WithArgs(ids...).
+						WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+				}
+			}
+
+			got, err := Run(context.Background(), mysql.NewDB(mockDB), tt.repair)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Len(t, got, len(ran))
+			} else {
+				assert.Len(t, got, len(ran)-1)
+			}
+			for _, result := range got {
+				want, ok := tt.want[result.Check]
+				if !ok {
+					assert.Empty(t, result.Violations, result.Check)
+					assert.Zero(t, result.Repaired, result.Check)
+					continue
+				}
+				assert.Equal(t, want, result)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRun_Sharded(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	//The main database's decisions are out of date, so no checks are run and nothing is repaired
+	mock.ExpectQuery(shardedQuery).WillReturnRows(sqlmock.NewRows([]string{"sharded"}).AddRow(true))
+
+	got, err := Run(context.Background(), mysql.NewDB(mockDB), true)
+	assert.ErrorIs(t, err, mysql.ErrSharded)
+	assert.Nil(t, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"muzz-project/storage/replica"
+)
+
+// GTIDPositions tracks replication with global transaction identifiers. Without GTIDs enabled the primary's position
+// is always "", so pinned reads stay on the primary for the whole pin window.
+type GTIDPositions struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+}
+
+var _ replica.Positions = (*GTIDPositions)(nil)
+
+// NewGTIDPositions returns positions for primary and replicas, in the same order as the replica storages
+func NewGTIDPositions(primary *sql.DB, replicas []*sql.DB) *GTIDPositions {
+	return &GTIDPositions{
+		primary:  primary,
+		replicas: replicas,
+	}
+}
+
+// Primary returns every transaction the primary has executed, which includes any write it has just made
+func (g *GTIDPositions) Primary(ctx context.Context) (string, error) {
+	var executed string
+	err := g.primary.QueryRowContext(ctx, `SELECT @@GLOBAL.gtid_executed`).Scan(&executed)
+	return executed, err
+}
+
+func (g *GTIDPositions) Reached(ctx context.Context, replica int, position string) (bool, error) {
+	var reached bool
+	err := g.replicas[replica].QueryRowContext(ctx, `SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)`, position).Scan(&reached)
+	return reached, err
+}
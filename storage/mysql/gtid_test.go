@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGTIDPositions(t *testing.T) {
+	ctx := context.Background()
+
+	primaryDB, primaryMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer primaryDB.Close()
+	replicaDB, replicaMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer replicaDB.Close()
+
+	positions := NewGTIDPositions(primaryDB, []*sql.DB{replicaDB})
+
+	executed := "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5"
+	primaryMock.ExpectQuery(`SELECT @@GLOBAL.gtid_executed`).WillReturnRows(sqlmock.NewRows([]string{"gtid_executed"}).AddRow(executed))
+	replicaMock.ExpectQuery(`SELECT GTID_SUBSET\(\?, @@GLOBAL.gtid_executed\)`).WithArgs(executed).WillReturnRows(sqlmock.NewRows([]string{"subset"}).AddRow(0))
+	replicaMock.ExpectQuery(`SELECT GTID_SUBSET\(\?, @@GLOBAL.gtid_executed\)`).WithArgs(executed).WillReturnRows(sqlmock.NewRows([]string{"subset"}).AddRow(1))
+
+	position, err := positions.Primary(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, executed, position)
+
+	reached, err := positions.Reached(ctx, 0, position)
+	assert.NoError(t, err)
+	assert.False(t, reached)
+
+	reached, err = positions.Reached(ctx, 0, position)
+	assert.NoError(t, err)
+	assert.True(t, reached)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
@@ -0,0 +1,14 @@
+// Package sqlutil builds the parts of MySQL queries shared by the storage/mysql packages
+package sqlutil
+
+import "strings"
+
+// Placeholders returns n comma separated placeholders, e.g. "?, ?, ?" for 3
+func Placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// ValuesList returns placeholders for rows rows of columns columns, e.g. "(?, ?), (?, ?)" for 2 rows of 2 columns
+func ValuesList(rows int, columns int) string {
+	return strings.TrimSuffix(strings.Repeat("("+Placeholders(columns)+"), ", rows), ", ")
+}
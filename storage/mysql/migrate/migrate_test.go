@@ -0,0 +1,318 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_users", Up: "CREATE TABLE Users (id INT);", Down: "DROP TABLE Users;"},
+	{Version: 2, Name: "add_names", Up: "ALTER TABLE Users ADD first_name VARCHAR(50);\nALTER TABLE Users ADD last_name VARCHAR(50);", Down: "ALTER TABLE Users DROP first_name, DROP last_name;"},
+}
+
+func TestLoad(t *testing.T) {
+	for _, dir := range []string{"migrations", "shard_migrations"} {
+		migrations, err := load(files, dir)
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, migrations) {
+			assert.Equal(t, 1, migrations[0].Version)
+			//Databases created before migrations already have these tables, so later columns would never be added
+			assert.NotContains(t, migrations[0].Up, "latitude")
+		}
+		for i := 1; i < len(migrations); i++ {
+			assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+		}
+	}
+
+	tests := map[string]struct {
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		"ordered by version": {
+			fsys: fstest.MapFS{
+				"migrations/0010_b.up.sql":   {Data: []byte("up b")},
+				"migrations/0010_b.down.sql": {Data: []byte("down b")},
+				"migrations/0002_a.up.sql":   {Data: []byte("up a")},
+				"migrations/0002_a.down.sql": {Data: []byte("down a")},
+			},
+			want: []Migration{
+				{Version: 2, Name: "a", Up: "up a", Down: "down a"},
+				{Version: 10, Name: "b", Up: "up b", Down: "down b"},
+			},
+		},
+		"missing down": {
+			fsys:    fstest.MapFS{"migrations/0001_a.up.sql": {Data: []byte("up a")}},
+			wantErr: true,
+		},
+		"badly named": {
+			fsys:    fstest.MapFS{"migrations/create_users.sql": {Data: []byte("up a")}},
+			wantErr: true,
+		},
+		"names don't match": {
+			fsys: fstest.MapFS{
+				"migrations/0001_a.up.sql":   {Data: []byte("up a")},
+				"migrations/0001_b.down.sql": {Data: []byte("down b")},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := load(tt.fsys, "migrations")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- Users can't be deleted
+CREATE TABLE Users (
+    id INT,
+    name VARCHAR(50) DEFAULT 'a;b'
+);
+
+INSERT INTO Users (id) VALUES (1);
+DROP TABLE Old`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE Users (\n    id INT,\n    name VARCHAR(50) DEFAULT 'a;b'\n)",
+		"INSERT INTO Users (id) VALUES (1)",
+		"DROP TABLE Old",
+	}, splitStatements(script))
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...int) {
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range versions {
+		rows.AddRow(v, time.Now())
+	}
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).WillReturnRows(rows)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       []int
+		wantErr    error
+	}{
+		"new database": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WithArgs(lockName, 60).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock)
+				mock.ExpectExec(`CREATE TABLE Users \(id INT\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO schema_migrations \(version, name\) VALUES \(\?, \?\)`).WithArgs(1, "create_users").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`ALTER TABLE Users ADD first_name`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`ALTER TABLE Users ADD last_name`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO schema_migrations \(version, name\) VALUES \(\?, \?\)`).WithArgs(2, "add_names").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WithArgs(lockName).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want:    []int{1, 2},
+			wantErr: nil,
+		},
+		"partly migrated": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock, 1)
+				mock.ExpectExec(`ALTER TABLE Users ADD first_name`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`ALTER TABLE Users ADD last_name`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO schema_migrations \(version, name\) VALUES \(\?, \?\)`).WithArgs(2, "add_names").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want:    []int{2},
+			wantErr: nil,
+		},
+		"up to date": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock, 1, 2)
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want:    nil,
+			wantErr: nil,
+		},
+		"another migration running": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+			},
+			want:    nil,
+			wantErr: ErrLocked,
+		},
+		"migration fails": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock, 1)
+				mock.ExpectExec(`ALTER TABLE Users ADD first_name`).WillReturnError(sql.ErrConnDone)
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want:    nil,
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := &Migrator{
+				db:          mockDB,
+				migrations:  testMigrations,
+				lockTimeout: time.Minute,
+			}
+			got, err := m.Up(ctx)
+
+			var versions []int
+			for _, migration := range got {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tt.want, versions)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       *Migration
+	}{
+		"rolls back the latest": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock, 1, 2)
+				mock.ExpectExec(`ALTER TABLE Users DROP first_name, DROP last_name`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \?`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: &testMigrations[1],
+		},
+		"nothing applied": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+				expectApplied(mock)
+				mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := &Migrator{
+				db:          mockDB,
+				migrations:  testMigrations,
+				lockTimeout: time.Minute,
+			}
+			got, err := m.Down(ctx)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Status(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	expectApplied(mock, 1)
+
+	m := &Migrator{
+		db:         mockDB,
+		migrations: testMigrations,
+	}
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.NotNil(t, statuses[0].AppliedAt)
+		assert.Nil(t, statuses[1].AppliedAt)
+	}
+}
+
+func TestMigrator_Check(t *testing.T) {
+	versionsQuery := regexp.QuoteMeta("SELECT version FROM schema_migrations")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		wantErr    error
+		wantMsg    string
+	}{
+		"up to date": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(versionsQuery).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1).AddRow(2))
+			},
+		},
+		"newer migrations applied": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(versionsQuery).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1).AddRow(2).AddRow(3))
+			},
+		},
+		"pending": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(versionsQuery).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+			},
+			wantErr: ErrPending,
+			wantMsg: "schema is behind, migrations are pending: 0002_add_names",
+		},
+		"never migrated": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(versionsQuery).WillReturnError(&mysql.MySQLError{Number: 1146})
+			},
+			wantErr: ErrPending,
+			wantMsg: "schema is behind, migrations are pending: nothing has been applied",
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(versionsQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+			wantMsg: sql.ErrConnDone.Error(),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := &Migrator{
+				db:         mockDB,
+				migrations: testMigrations,
+			}
+			err = m.Check(context.Background())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantMsg != "" {
+				assert.EqualError(t, err, tt.wantMsg)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE Users
+    DROP INDEX idx_Users_location,
+    DROP COLUMN latitude,
+    DROP COLUMN longitude;
@@ -0,0 +1,4 @@
+ALTER TABLE Users
+    ADD COLUMN latitude DOUBLE NULL,
+    ADD COLUMN longitude DOUBLE NULL,
+    ADD INDEX idx_Users_location (latitude, longitude);
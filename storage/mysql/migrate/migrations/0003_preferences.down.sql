@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS Preferences;
+ALTER TABLE Users
+    DROP COLUMN birthdate,
+    DROP COLUMN gender;
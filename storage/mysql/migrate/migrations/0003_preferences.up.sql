@@ -0,0 +1,12 @@
+ALTER TABLE Users
+    ADD COLUMN birthdate DATE NULL AFTER last_name,
+    ADD COLUMN gender TINYINT NULL AFTER birthdate;
+
+CREATE TABLE IF NOT EXISTS Preferences (
+    user_id INT PRIMARY KEY,
+    min_age INT NOT NULL DEFAULT 0,
+    max_age INT NOT NULL DEFAULT 0,
+    genders INT UNSIGNED NOT NULL DEFAULT 0,
+    intent TINYINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS DailyLikeQuotas;
+DROP TABLE IF EXISTS Entitlements;
+ALTER TABLE Users
+    DROP COLUMN timezone;
@@ -0,0 +1,17 @@
+ALTER TABLE Users
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '' AFTER gender;
+
+CREATE TABLE IF NOT EXISTS Entitlements (
+    user_id INT PRIMARY KEY,
+    daily_like_limit INT NULL,
+    expires_at TIMESTAMP NULL,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS DailyLikeQuotas (
+    user_id INT NOT NULL,
+    day DATE NOT NULL,
+    likes_used INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day),
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS JobCursors;
+DROP TABLE IF EXISTS DesirabilityScores;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS DesirabilityScores (
+    user_id INT PRIMARY KEY,
+    score DOUBLE NOT NULL,
+    likes_given INT NOT NULL DEFAULT 0,
+    decisions_given INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS JobCursors (
+    name VARCHAR(64) PRIMARY KEY,
+    last_id BIGINT NOT NULL
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS Recommendations (
+    user_id INT NOT NULL,
+    candidate_id INT NOT NULL,
+    ranking INT NOT NULL,
+    score DOUBLE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, candidate_id),
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE,
+    FOREIGN KEY (candidate_id) REFERENCES Users(id) ON DELETE CASCADE
+);
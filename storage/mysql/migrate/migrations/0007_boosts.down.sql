@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Boosts;
+DROP TABLE IF EXISTS BoostEntitlements;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS BoostEntitlements (
+    user_id INT PRIMARY KEY,
+    boosts_remaining INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NULL,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Boosts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    INDEX idx_Boosts_user_ends (user_id, ends_at),
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
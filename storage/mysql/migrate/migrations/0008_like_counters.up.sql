@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS LikeCounters (
+    user_id INT PRIMARY KEY,
+    likes_received INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+INSERT INTO LikeCounters (user_id, likes_received)
+SELECT recipient_id, COUNT(*) FROM Decisions WHERE liked = TRUE GROUP BY recipient_id
+ON DUPLICATE KEY UPDATE likes_received = VALUES(likes_received);
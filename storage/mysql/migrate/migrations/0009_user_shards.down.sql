@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS DecisionSequence;
+DROP TABLE IF EXISTS UserShards;
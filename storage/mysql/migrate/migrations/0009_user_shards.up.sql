@@ -0,0 +1,16 @@
+-- Each user's home shard when Decisions are sharded. Unused by unsharded deployments.
+CREATE TABLE IF NOT EXISTS UserShards (
+    user_id INT PRIMARY KEY,
+    shard INT NOT NULL,
+    -- moving_to is set while the user is being copied to another shard, their writes go to both
+    moving_to INT NULL,
+    INDEX idx_UserShards_shard (shard),
+    FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+-- Hands out decision IDs when Decisions are sharded, as they have to be unique across shards
+CREATE TABLE IF NOT EXISTS DecisionSequence (
+    id BIGINT NOT NULL
+);
+
+INSERT INTO DecisionSequence (id) SELECT COALESCE(MAX(id), 0) FROM Decisions;
@@ -0,0 +1,2 @@
+DROP INDEX idx_Decisions_created_at ON Decisions;
+DROP TABLE IF EXISTS DecisionsArchive;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS DecisionsArchive (
+    id INT PRIMARY KEY,
+    actor_id INT NOT NULL,
+    recipient_id INT NOT NULL,
+    liked BOOLEAN NOT NULL,
+    created_at TIMESTAMP NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_DecisionsArchive_actor (actor_id, recipient_id),
+    FOREIGN KEY (actor_id) REFERENCES Users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipient_id) REFERENCES Users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_Decisions_created_at ON Decisions (created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ActorDecisions;
+DROP TABLE IF EXISTS Decisions;
@@ -0,0 +1,20 @@
+-- A user's home shard holds the decisions made on them, and a copy of the decisions they've made in ActorDecisions,
+-- so every query about one user only needs their home shard. Shards set up before there were shard migrations
+-- already have these tables.
+CREATE TABLE IF NOT EXISTS Decisions (
+    id BIGINT PRIMARY KEY,
+    actor_id INT NOT NULL,
+    recipient_id INT NOT NULL,
+    liked BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE KEY unique_Decisions (recipient_id, actor_id),
+    INDEX idx_Decisions_likes (recipient_id, liked, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS ActorDecisions (
+    actor_id INT NOT NULL,
+    recipient_id INT NOT NULL,
+    decision_id BIGINT NOT NULL,
+    liked BOOLEAN NOT NULL,
+    PRIMARY KEY (actor_id, recipient_id)
+);
@@ -3,35 +3,108 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"muzz-project/geo"
+	"muzz-project/score"
 	"muzz-project/storage"
+	"muzz-project/storage/mysql/internal/sqlutil"
+	"strings"
 	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// likerDistance is the distance in km between the actor (a) and recipient (r) of a decision. ST_Distance_Sphere is
+// NULL if either user has no location.
+const likerDistance = "ST_Distance_Sphere(POINT(a.longitude, a.latitude), POINT(r.longitude, r.latitude)) / 1000"
+
+// distanceBandKm groups candidates by distance so more desirable users can come first among those similarly close
+const distanceBandKm = 10
+
+const desirabilityCursor = "desirability"
+
+// MySQL error numbers for constraint violations
+const (
+	errDuplicateEntry  = 1062
+	errNoReferencedRow = 1452
 )
 
 type MysqlStorage struct {
-	db          *sql.DB
+	db          *DB
+	retrier     *Retrier
+	tracer      trace.Tracer
 	maxPageSize int
 }
 
 var _ storage.Storage = (*MysqlStorage)(nil)
 
-func NewMysqlStorage(db *sql.DB, maxPageSize int) *MysqlStorage {
-	return &MysqlStorage{
-		db:          db,
+type Option func(*MysqlStorage)
+
+// WithRetrier sets how operations failing with deadlocks, lock wait timeouts and lost connections are retried
+func WithRetrier(retrier *Retrier) Option {
+	return func(m *MysqlStorage) {
+		m.retrier = retrier
+	}
+}
+
+func NewMysqlStorage(db *sql.DB, maxPageSize int, opts ...Option) *MysqlStorage {
+	m := &MysqlStorage{
+		retrier:     NewRetrier(DefaultRetryPolicy),
+		tracer:      noopTracer,
 		maxPageSize: maxPageSize,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.db = &DB{DB: db, retrier: m.retrier, tracer: m.tracer}
+	return m
 }
 
-func (m *MysqlStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*storage.Decision, error) {
-	query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE recipient_id = ? AND liked = TRUE ORDER BY created_at DESC LIMIT %d OFFSET %d", m.maxPageSize, paginationToken)
+// RetryStats returns how many operations have been retried
+func (m *MysqlStorage) RetryStats() RetryStats {
+	return m.retrier.Stats()
+}
+
+func (m *MysqlStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	query := m.likesQuery("", filter, paginationToken)
 	return m.getLikesHandler(ctx, userId, query)
 }
 
-func (m *MysqlStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*storage.Decision, error) {
-	query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions d1 WHERE d1.recipient_id = ? AND d1.liked = TRUE AND NOT EXISTS (SELECT 1 FROM Decisions d2 WHERE d2.actor_id = d1.recipient_id  AND d2.recipient_id = d1.actor_id) ORDER BY created_at DESC LIMIT %d OFFSET %d;", m.maxPageSize, paginationToken)
+func (m *MysqlStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	query := m.likesQuery(" AND NOT EXISTS (SELECT 1 FROM Decisions d2 WHERE d2.actor_id = d.recipient_id  AND d2.recipient_id = d.actor_id)", filter, paginationToken)
 	return m.getLikesHandler(ctx, userId, query)
 }
 
+func (m *MysqlStorage) likesQuery(condition string, filter storage.LikesFilter, paginationToken int) string {
+	var query strings.Builder
+
+	query.WriteString("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at, " + likerDistance + " AS distance_km FROM Decisions d JOIN Users a ON a.id = d.actor_id JOIN Users r ON r.id = d.recipient_id")
+	if filter.MatchPreferences {
+		query.WriteString(" LEFT JOIN Preferences ap ON ap.user_id = a.id LEFT JOIN Preferences rp ON rp.user_id = r.id")
+	}
+	query.WriteString(" WHERE d.recipient_id = ? AND d.liked = TRUE")
+	query.WriteString(condition)
+	if filter.MatchPreferences {
+		query.WriteString(" AND " + matchesPreferences("r", "rp", "a", "ap"))
+	}
+	fmt.Fprintf(&query, " ORDER BY d.created_at DESC LIMIT %d OFFSET %d", m.maxPageSize, paginationToken)
+
+	return query.String()
+}
+
+// matchesPreferences is a condition that is true when other fits the preferences of user. userPrefs and otherPrefs
+// are LEFT JOINed Preferences rows so a missing row, like a zero value, means no restriction. Users who haven't
+// set a birthdate or gender never match a restriction on it.
+func matchesPreferences(user, userPrefs, other, otherPrefs string) string {
+	return strings.NewReplacer("{user}", user, "{userPrefs}", userPrefs, "{other}", other, "{otherPrefs}", otherPrefs).Replace(
+		"(COALESCE({userPrefs}.min_age, 0) = 0 OR TIMESTAMPDIFF(YEAR, {other}.birthdate, CURDATE()) >= {userPrefs}.min_age)" +
+			" AND (COALESCE({userPrefs}.max_age, 0) = 0 OR TIMESTAMPDIFF(YEAR, {other}.birthdate, CURDATE()) <= {userPrefs}.max_age)" +
+			" AND (COALESCE({userPrefs}.genders, 0) = 0 OR ({userPrefs}.genders & (1 << {other}.gender)) <> 0)" +
+			" AND (COALESCE({userPrefs}.intent, 0) = 0 OR COALESCE({otherPrefs}.intent, 0) = 0 OR {userPrefs}.intent = {otherPrefs}.intent)")
+}
+
 func (m *MysqlStorage) getLikesHandler(ctx context.Context, userId string, query string) ([]*storage.Decision, error) {
 	var decisions []*storage.Decision
 
@@ -43,9 +116,11 @@ func (m *MysqlStorage) getLikesHandler(ctx context.Context, userId string, query
 
 	for rows.Next() {
 		var decision storage.Decision
-		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt); err != nil {
+		var distance sql.NullFloat64
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt, &distance); err != nil {
 			return nil, err
 		}
+		decision.DistanceKm = nullFloatPtr(distance)
 		decisions = append(decisions, &decision)
 	}
 
@@ -58,9 +133,12 @@ func (m *MysqlStorage) getLikesHandler(ctx context.Context, userId string, query
 
 func (m *MysqlStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
 	var count int64
-	query := `SELECT COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE`
+	query := `SELECT likes_received FROM LikeCounters WHERE user_id = ?`
 
 	err := m.db.QueryRowContext(ctx, query, userId).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -69,24 +147,460 @@ func (m *MysqlStorage) GetLikesCountForUser(ctx context.Context, userId string)
 
 func (m *MysqlStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
 	//Check to see if recipient has already liked actor - if so it's a match!
-	reciprocal := false
+	var reciprocal bool
+
+	err := m.db.InTx(ctx, func(tx *Tx) error {
+		reciprocal = false
+
+		if liked {
+			var count int64
+
+			matchQuery := `SELECT COUNT(*) FROM Decisions WHERE recipient_id = ? AND actor_id = ? AND liked = TRUE`
+
+			err := tx.QueryRowContext(ctx, matchQuery, actorId, recipientId).Scan(&count)
+			if err != nil {
+				return err
+			}
+
+			reciprocal = count > 0
+
+		}
+
+		query := `INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query, actorId, recipientId, liked, time.Now())
+		if isMysqlError(err, errDuplicateEntry) {
+			return storage.ErrDuplicateDecision
+		}
+		if isMysqlError(err, errNoReferencedRow) {
+			return storage.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if liked {
+			counterQuery := `INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1`
+			if _, err := tx.ExecContext(ctx, counterQuery, recipientId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return reciprocal, nil
+}
+
+func (m *MysqlStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	query := `UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?`
+	return m.updateUser(ctx, userId, query, latitude, longitude, userId)
+}
+
+// updateUser runs query, an UPDATE of userId's row in Users, and returns ErrUserNotFound if there's no such user.
+// MySQL only counts the rows an UPDATE changes, so when none were the user is looked up to tell an update to the
+// values they already had apart from a missing user.
+func (m *MysqlStorage) updateUser(ctx context.Context, userId string, query string, args ...any) error {
+	result, err := m.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM Users WHERE id = ?)`, userId).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (m *MysqlStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	return m.getCandidates(ctx, userId, maxDistanceKm, true, m.maxPageSize, paginationToken)
+}
+
+// getCandidates returns limit candidates from offset. Users userId has decided on are only left out if
+// excludeDecided is set, when Decisions are on this database.
+func (m *MysqlStorage) getCandidates(ctx context.Context, userId string, maxDistanceKm float64, excludeDecided bool, limit int, offset int) ([]*storage.Candidate, error) {
+	var latitude, longitude sql.NullFloat64
+
+	err := m.db.QueryRowContext(ctx, `SELECT latitude, longitude FROM Users WHERE id = ?`, userId).Scan(&latitude, &longitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var query strings.Builder
+	now := time.Now()
+	args := []any{userId, now, now}
+
+	query.WriteString("SELECT u.id, ST_Distance_Sphere(POINT(u.longitude, u.latitude), POINT(me.longitude, me.latitude)) / 1000 AS distance_km FROM Users u JOIN Users me ON me.id = ? LEFT JOIN Preferences up ON up.user_id = u.id LEFT JOIN Preferences mp ON mp.user_id = me.id LEFT JOIN DesirabilityScores ds ON ds.user_id = u.id LEFT JOIN Recommendations rec ON rec.user_id = me.id AND rec.candidate_id = u.id LEFT JOIN Boosts b ON b.user_id = u.id AND b.starts_at <= ? AND b.ends_at > ? WHERE u.id <> me.id")
+	if excludeDecided {
+		query.WriteString(" AND NOT EXISTS (SELECT 1 FROM Decisions d WHERE d.actor_id = me.id AND d.recipient_id = u.id)")
+	}
+	query.WriteString(" AND " + matchesPreferences("me", "mp", "u", "up"))
+	query.WriteString(" AND " + matchesPreferences("u", "up", "me", "mp"))
+
+	if maxDistanceKm > 0 {
+		if !latitude.Valid || !longitude.Valid {
+			return nil, storage.ErrLocationUnknown
+		}
+
+		//Bounding box lets the location index discard most users before the exact distance is calculated
+		box := geo.NewBoundingBox(latitude.Float64, longitude.Float64, maxDistanceKm)
+		query.WriteString(" AND u.latitude BETWEEN ? AND ?")
+		args = append(args, box.MinLatitude, box.MaxLatitude)
+		if box.CrossesAntimeridian() {
+			query.WriteString(" AND (u.longitude >= ? OR u.longitude <= ?)")
+		} else {
+			query.WriteString(" AND u.longitude BETWEEN ? AND ?")
+		}
+		args = append(args, box.MinLongitude, box.MaxLongitude)
+		query.WriteString(" HAVING distance_km <= ?")
+		args = append(args, maxDistanceKm)
+	}
+
+	//Boosted users come first, then recommended users, then nearby users ranked by desirability within each distance band
+	fmt.Fprintf(&query, " ORDER BY b.user_id IS NULL, rec.ranking IS NULL, rec.ranking, distance_km IS NULL, FLOOR(distance_km / %d), COALESCE(ds.score, %g) DESC, u.id LIMIT %d OFFSET %d", distanceBandKm, score.InitialScore, limit, offset)
+
+	rows, err := m.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*storage.Candidate
+	for rows.Next() {
+		var candidate storage.Candidate
+		var distance sql.NullFloat64
+		if err := rows.Scan(&candidate.UserID, &distance); err != nil {
+			return nil, err
+		}
+		candidate.DistanceKm = nullFloatPtr(distance)
+		candidates = append(candidates, &candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+func (m *MysqlStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	query := `UPDATE Users SET birthdate = ?, gender = ?, timezone = ? WHERE id = ?`
+	return m.updateUser(ctx, userId, query, profile.Birthdate.Format(time.DateOnly), int32(profile.Gender), profile.TimeZone, userId)
+}
+
+func (m *MysqlStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	var preferences storage.Preferences
+	query := `SELECT min_age, max_age, genders, intent FROM Preferences WHERE user_id = ?`
+
+	err := m.db.QueryRowContext(ctx, query, userId).Scan(&preferences.MinAge, &preferences.MaxAge, &preferences.Genders, &preferences.Intent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &storage.Preferences{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+func (m *MysqlStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	query := `INSERT INTO Preferences (user_id, min_age, max_age, genders, intent) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE min_age = VALUES(min_age), max_age = VALUES(max_age), genders = VALUES(genders), intent = VALUES(intent)`
+	_, err := m.db.ExecContext(ctx, query, userId, preferences.MinAge, preferences.MaxAge, uint32(preferences.Genders), int32(preferences.Intent))
+	if isMysqlError(err, errNoReferencedRow) {
+		return storage.ErrUserNotFound
+	}
+	return err
+}
+
+func (m *MysqlStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	var settings storage.QuotaSettings
+	var hasEntitlement bool
+	var entitlement storage.Entitlement
+	var dailyLikeLimit sql.NullInt64
+	var expiresAt sql.NullTime
+
+	query := `SELECT u.timezone, e.user_id IS NOT NULL, e.daily_like_limit, e.expires_at FROM Users u LEFT JOIN Entitlements e ON e.user_id = u.id AND (e.expires_at IS NULL OR e.expires_at > ?) WHERE u.id = ?`
+
+	err := m.db.QueryRowContext(ctx, query, time.Now(), userId).Scan(&settings.TimeZone, &hasEntitlement, &dailyLikeLimit, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hasEntitlement {
+		if dailyLikeLimit.Valid {
+			limit := int(dailyLikeLimit.Int64)
+			entitlement.DailyLikeLimit = &limit
+		}
+		if expiresAt.Valid {
+			entitlement.ExpiresAt = &expiresAt.Time
+		}
+		settings.Entitlement = &entitlement
+	}
+	return &settings, nil
+}
+
+func (m *MysqlStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	var used int
+	query := `SELECT likes_used FROM DailyLikeQuotas WHERE user_id = ? AND day = ?`
+
+	err := m.db.QueryRowContext(ctx, query, userId, day).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return used, nil
+}
+
+func (m *MysqlStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	//Affects 1 row on insert, 2 on update and 0 when likes_used is left unchanged because the limit's been reached
+	query := `INSERT INTO DailyLikeQuotas (user_id, day, likes_used) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE likes_used = IF(likes_used < ?, likes_used + 1, likes_used)`
+
+	result, err := m.db.ExecContext(ctx, query, userId, day, limit)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (m *MysqlStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	query := `UPDATE DailyLikeQuotas SET likes_used = likes_used - 1 WHERE user_id = ? AND day = ? AND likes_used > 0`
+	_, err := m.db.ExecContext(ctx, query, userId, day)
+	return err
+}
+
+func (m *MysqlStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT %d", limit)
+
+	rows, err := m.db.QueryContext(ctx, query, afterId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*storage.Decision
+	for rows.Next() {
+		var decision storage.Decision
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, &decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+func (m *MysqlStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	scores := make(map[int64]*storage.Score)
+	if len(userIds) == 0 {
+		return scores, nil
+	}
+
+	args := make([]any, len(userIds))
+	for i, id := range userIds {
+		args[i] = id
+	}
+	query := fmt.Sprintf("SELECT user_id, score, likes_given, decisions_given, updated_at FROM DesirabilityScores WHERE user_id IN (%s)", sqlutil.Placeholders(len(userIds)))
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s storage.Score
+		if err := rows.Scan(&s.UserID, &s.Score, &s.LikesGiven, &s.DecisionsGiven, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scores[s.UserID] = &s
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	if liked {
-		var count int64
+	return scores, nil
+}
 
-		matchQuery := `SELECT COUNT(*) FROM Decisions WHERE recipient_id = ? AND actor_id = ? AND liked = TRUE`
+func (m *MysqlStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	return m.db.InTx(ctx, func(tx *Tx) error {
+		if len(scores) > 0 {
+			var args []any
+			for _, s := range scores {
+				args = append(args, s.UserID, s.Score, s.LikesGiven, s.DecisionsGiven, s.UpdatedAt)
+			}
+			query := "INSERT INTO DesirabilityScores (user_id, score, likes_given, decisions_given, updated_at) VALUES " +
+				strings.Repeat("(?, ?, ?, ?, ?), ", len(scores)-1) + "(?, ?, ?, ?, ?)" +
+				" ON DUPLICATE KEY UPDATE score = VALUES(score), likes_given = VALUES(likes_given), decisions_given = VALUES(decisions_given), updated_at = VALUES(updated_at)"
 
-		err := m.db.QueryRowContext(ctx, matchQuery, actorId, recipientId).Scan(&count)
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+
+		cursorQuery := `INSERT INTO JobCursors (name, last_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE last_id = VALUES(last_id)`
+		_, err := tx.ExecContext(ctx, cursorQuery, desirabilityCursor, lastDecisionId)
+		return err
+	})
+}
+
+func (m *MysqlStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	var lastId int64
+	query := `SELECT last_id FROM JobCursors WHERE name = ?`
+
+	err := m.db.QueryRowContext(ctx, query, desirabilityCursor).Scan(&lastId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastId, nil
+}
+
+func (m *MysqlStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	return m.db.InTx(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM Recommendations WHERE user_id = ?`, userId); err != nil {
+			return err
+		}
+
+		if len(recommendations) > 0 {
+			var args []any
+			for i, r := range recommendations {
+				args = append(args, userId, r.CandidateID, i+1, r.Score)
+			}
+			query := "INSERT INTO Recommendations (user_id, candidate_id, ranking, score) VALUES " +
+				strings.Repeat("(?, ?, ?, ?), ", len(recommendations)-1) + "(?, ?, ?, ?)"
+
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MysqlStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	var boost *storage.Boost
+
+	err := m.db.InTx(ctx, func(tx *Tx) error {
+		//Boosts are stored to the second
+		now := time.Now().Truncate(time.Second)
+
+		//Taking the boost first locks the entitlement, so concurrent activations can't both see no active boost
+		spendQuery := `UPDATE BoostEntitlements SET boosts_remaining = boosts_remaining - 1 WHERE user_id = ? AND boosts_remaining > 0 AND (expires_at IS NULL OR expires_at > ?)`
+		result, err := tx.ExecContext(ctx, spendQuery, userId, now)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
 		if err != nil {
-			return false, err
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrNoBoosts
 		}
 
-		reciprocal = count > 0
+		var active int64
+		activeQuery := `SELECT COUNT(*) FROM Boosts WHERE user_id = ? AND ends_at > ?`
+		if err := tx.QueryRowContext(ctx, activeQuery, userId, now).Scan(&active); err != nil {
+			return err
+		}
+		if active > 0 {
+			return storage.ErrBoostActive
+		}
 
+		boost = &storage.Boost{
+			StartsAt: now,
+			EndsAt:   now.Add(duration),
+		}
+		insertQuery := `INSERT INTO Boosts (user_id, starts_at, ends_at) VALUES (?, ?, ?)`
+		_, err = tx.ExecContext(ctx, insertQuery, userId, boost.StartsAt, boost.EndsAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return boost, nil
+}
 
-	query := `INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)`
-	_, err := m.db.ExecContext(ctx, query, actorId, recipientId, liked, time.Now())
+func (m *MysqlStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	var boost storage.Boost
+	query := `SELECT b.starts_at, b.ends_at, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = b.user_id AND d.liked = TRUE AND d.created_at >= b.starts_at AND d.created_at < b.ends_at) FROM Boosts b WHERE b.user_id = ? ORDER BY b.starts_at DESC LIMIT 1`
+
+	err := m.db.QueryRowContext(ctx, query, userId).Scan(&boost.StartsAt, &boost.EndsAt, &boost.LikesReceived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrBoostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &boost, nil
+}
+
+func isMysqlError(err error, number uint16) bool {
+	var mysqlErr *driver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
+
+func nullFloatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+func (m *MysqlStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	driftQuery := `SELECT u.id, COALESCE(lc.likes_received, 0) AS stored, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) AS actual FROM Users u LEFT JOIN LikeCounters lc ON lc.user_id = u.id HAVING stored <> actual ORDER BY u.id`
+
+	rows, err := m.db.QueryContext(ctx, driftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drifts []*storage.LikeCounterDrift
+	for rows.Next() {
+		var drift storage.LikeCounterDrift
+		if err := rows.Scan(&drift.UserID, &drift.Stored, &drift.Actual); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, &drift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	//Recounted as each counter is written so likes made since the drift was found aren't lost
+	repairQuery := `INSERT INTO LikeCounters (user_id, likes_received) SELECT ?, COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE ON DUPLICATE KEY UPDATE likes_received = VALUES(likes_received)`
+	for _, drift := range drifts {
+		if _, err := m.db.ExecContext(ctx, repairQuery, drift.UserID, drift.UserID); err != nil {
+			return nil, err
+		}
+	}
 
-	return reciprocal, err
+	return drifts, nil
 }
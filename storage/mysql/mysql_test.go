@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"github.com/stretchr/testify/assert"
+	"muzz-project/service/protos"
 	"muzz-project/storage"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
 )
 
 func TestMysqlStorage_GetLikesCountForUser(t *testing.T) {
@@ -23,8 +25,8 @@ func TestMysqlStorage_GetLikesCountForUser(t *testing.T) {
 	}{
 		"user with likes": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND liked = TRUE`).
-					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(5))
+				mock.ExpectQuery(`SELECT likes_received FROM LikeCounters WHERE user_id = \?`).
+					WillReturnRows(sqlmock.NewRows([]string{"likes_received"}).AddRow(5))
 			},
 			userId:  "1",
 			want:    5,
@@ -32,8 +34,8 @@ func TestMysqlStorage_GetLikesCountForUser(t *testing.T) {
 		},
 		"user without likes": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND liked = TRUE`).
-					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectQuery(`SELECT likes_received FROM LikeCounters WHERE user_id = \?`).
+					WillReturnRows(sqlmock.NewRows([]string{"likes_received"}))
 			},
 			userId:  "1",
 			want:    0,
@@ -41,7 +43,7 @@ func TestMysqlStorage_GetLikesCountForUser(t *testing.T) {
 		},
 		"database error": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND liked = TRUE`).
+				mock.ExpectQuery(`SELECT likes_received FROM LikeCounters WHERE user_id = \?`).
 					WithArgs("2").
 					WillReturnError(sql.ErrConnDone)
 			},
@@ -58,9 +60,7 @@ func TestMysqlStorage_GetLikesCountForUser(t *testing.T) {
 
 			tt.dbOutcomes(mock)
 
-			m := &MysqlStorage{
-				db: mockDB,
-			}
+			m := NewMysqlStorage(mockDB, 0)
 			got, err := m.GetLikesCountForUser(ctx, tt.userId)
 			assert.Equal(t, tt.want, got)
 			assert.Equal(t, tt.wantErr, err)
@@ -83,9 +83,9 @@ func TestMysqlStorage_GetLikesForUser(t *testing.T) {
 		"user with likes": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
 				query := `SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE recipient_id = \? AND liked = TRUE ORDER BY created_at DESC LIMIT 10 OFFSET 0`
-				rows := sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at"}).
-					AddRow("1", "2", "1", true, arbitraryTime).
-					AddRow("2", "3", "1", true, arbitraryTime)
+				rows := sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at", "distance_km"}).
+					AddRow("1", "2", "1", true, arbitraryTime, nil).
+					AddRow("2", "3", "1", true, arbitraryTime, 12.5)
 				mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows)
 
 			},
@@ -94,14 +94,14 @@ func TestMysqlStorage_GetLikesForUser(t *testing.T) {
 			maxPageSize: 10,
 			want: []*storage.Decision{
 				{ID: 1, ActorID: 2, RecipientID: 1, Liked: true, CreatedAt: arbitraryTime},
-				{ID: 2, ActorID: 3, RecipientID: 1, Liked: true, CreatedAt: arbitraryTime},
+				{ID: 2, ActorID: 3, RecipientID: 1, Liked: true, CreatedAt: arbitraryTime, DistanceKm: floatPtr(12.5)},
 			},
 			wantErr: nil,
 		},
 		"user without likes": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
 				query := `SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE recipient_id = \? AND liked = TRUE ORDER BY created_at DESC LIMIT 10 OFFSET 0`
-				rows := sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at"})
+				rows := sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at", "distance_km"})
 				mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows)
 
 			},
@@ -133,10 +133,7 @@ func TestMysqlStorage_GetLikesForUser(t *testing.T) {
 
 			tt.dbOutcomes(mock)
 
-			m := &MysqlStorage{
-				db:          mockDB,
-				maxPageSize: tt.maxPageSize,
-			}
+			m := NewMysqlStorage(mockDB, tt.maxPageSize)
 
 			got, err := m.getLikesHandler(ctx, tt.userId, tt.query)
 			assert.Equal(t, tt.want, got)
@@ -158,11 +155,16 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 	}{
 		"users match": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`).
 					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
 					WithArgs("1", "2", true, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1")).
+					WithArgs("2").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 
 			},
 			actorId:     "1",
@@ -173,11 +175,16 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 		},
 		"users don't match": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`).
 					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
 				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
 					WithArgs("1", "2", true, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1")).
+					WithArgs("2").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 
 			},
 			actorId:     "1",
@@ -188,9 +195,11 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 		},
 		"actor doesn't like recipient": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
 					WithArgs("1", "2", false, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 
 			},
 			actorId:     "1",
@@ -201,8 +210,10 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 		},
 		"database error 1": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`).
 					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
 			},
 			actorId:     "1",
 			recipientId: "2",
@@ -212,19 +223,68 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 		},
 		"database error 2": {
 			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
 				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`).
 					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
 				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
 					WithArgs("1", "2", true, sqlmock.AnyArg()).
 					WillReturnError(sql.ErrConnDone)
-
+				mock.ExpectRollback()
 			},
 			actorId:     "1",
 			recipientId: "2",
 			liked:       true,
-			want:        true,
+			want:        false,
 			wantErr:     sql.ErrConnDone,
 		},
+		"counter update fails": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`).
+					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
+					WithArgs("1", "2", true, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO LikeCounters")).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			actorId:     "1",
+			recipientId: "2",
+			liked:       true,
+			want:        false,
+			wantErr:     sql.ErrConnDone,
+		},
+		"decision already made": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
+					WithArgs("1", "2", false, sqlmock.AnyArg()).
+					WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry '1-2' for key 'Decisions.unique_Decisions'"})
+				mock.ExpectRollback()
+
+			},
+			actorId:     "1",
+			recipientId: "2",
+			liked:       false,
+			want:        false,
+			wantErr:     storage.ErrDuplicateDecision,
+		},
+		"unknown user": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")).
+					WithArgs("1", "99", false, sqlmock.AnyArg()).
+					WillReturnError(&driver.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails"})
+				mock.ExpectRollback()
+
+			},
+			actorId:     "1",
+			recipientId: "99",
+			liked:       false,
+			want:        false,
+			wantErr:     storage.ErrUserNotFound,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -234,13 +294,793 @@ func TestMysqlStorage_AddDecision(t *testing.T) {
 
 			tt.dbOutcomes(mock)
 
-			m := &MysqlStorage{
-				db: mockDB,
-			}
+			m := NewMysqlStorage(mockDB, 0)
 
 			got, err := m.AddDecision(ctx, tt.actorId, tt.recipientId, tt.liked)
 			assert.Equal(t, tt.want, got)
 			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_UpdateLocation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		wantErr    error
+	}{
+		"location updated": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?")).
+					WithArgs(51.51, -0.13, "1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: nil,
+		},
+		"location unchanged": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?")).
+					WithArgs(51.51, -0.13, "1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM Users WHERE id = ?)")).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			wantErr: nil,
+		},
+		"unknown user": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?")).
+					WithArgs(51.51, -0.13, "1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM Users WHERE id = ?)")).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			wantErr: storage.ErrUserNotFound,
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?")).
+					WithArgs(51.51, -0.13, "1").
+					WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			err = m.UpdateLocation(ctx, "1", 51.51, -0.13)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_GetCandidatesForUser(t *testing.T) {
+	ctx := context.Background()
+
+	locationQuery := regexp.QuoteMeta("SELECT latitude, longitude FROM Users WHERE id = ?")
+	candidatesQuery := regexp.QuoteMeta("SELECT u.id, ST_Distance_Sphere(POINT(u.longitude, u.latitude), POINT(me.longitude, me.latitude)) / 1000 AS distance_km FROM Users u JOIN Users me ON me.id = ? LEFT JOIN Preferences up ON up.user_id = u.id LEFT JOIN Preferences mp ON mp.user_id = me.id LEFT JOIN DesirabilityScores ds ON ds.user_id = u.id LEFT JOIN Recommendations rec ON rec.user_id = me.id AND rec.candidate_id = u.id LEFT JOIN Boosts b ON b.user_id = u.id AND b.starts_at <= ? AND b.ends_at > ? WHERE u.id <> me.id AND NOT EXISTS (SELECT 1 FROM Decisions d WHERE d.actor_id = me.id AND d.recipient_id = u.id)" +
+		" AND " + matchesPreferences("me", "mp", "u", "up") + " AND " + matchesPreferences("u", "up", "me", "mp"))
+
+	tests := map[string]struct {
+		dbOutcomes    func(mock sqlmock.Sqlmock)
+		maxDistanceKm float64
+		want          []*storage.Candidate
+		wantErr       error
+	}{
+		"no distance limit": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(51.51, -0.13))
+				mock.ExpectQuery(candidatesQuery+regexp.QuoteMeta(" ORDER BY b.user_id IS NULL, rec.ranking IS NULL, rec.ranking, distance_km IS NULL, FLOOR(distance_km / 10), COALESCE(ds.score, 1500) DESC, u.id LIMIT 10 OFFSET 0")).
+					WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "distance_km"}).AddRow(2, 3.2).AddRow(3, nil))
+			},
+			maxDistanceKm: 0,
+			want: []*storage.Candidate{
+				{UserID: 2, DistanceKm: floatPtr(3.2)},
+				{UserID: 3, DistanceKm: nil},
+			},
+			wantErr: nil,
+		},
+		"distance limit uses bounding box": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(51.51, -0.13))
+				mock.ExpectQuery(candidatesQuery+regexp.QuoteMeta(" AND u.latitude BETWEEN ? AND ? AND u.longitude BETWEEN ? AND ? HAVING distance_km <= ? ORDER BY b.user_id IS NULL, rec.ranking IS NULL, rec.ranking, distance_km IS NULL, FLOOR(distance_km / 10), COALESCE(ds.score, 1500) DESC, u.id LIMIT 10 OFFSET 0")).
+					WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 10.0).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "distance_km"}).AddRow(2, 3.2))
+			},
+			maxDistanceKm: 10,
+			want: []*storage.Candidate{
+				{UserID: 2, DistanceKm: floatPtr(3.2)},
+			},
+			wantErr: nil,
+		},
+		"distance limit crossing the antimeridian": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(0, 179.99))
+				mock.ExpectQuery(candidatesQuery+regexp.QuoteMeta(" AND u.latitude BETWEEN ? AND ? AND (u.longitude >= ? OR u.longitude <= ?) HAVING distance_km <= ?")).
+					WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 10.0).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "distance_km"}))
+			},
+			maxDistanceKm: 10,
+			want:          nil,
+			wantErr:       nil,
+		},
+		"distance limit without location": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(nil, nil))
+			},
+			maxDistanceKm: 10,
+			want:          nil,
+			wantErr:       storage.ErrLocationUnknown,
+		},
+		"user not found": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}))
+			},
+			maxDistanceKm: 0,
+			want:          nil,
+			wantErr:       storage.ErrUserNotFound,
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(locationQuery).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(51.51, -0.13))
+				mock.ExpectQuery(candidatesQuery).
+					WillReturnError(sql.ErrConnDone)
+			},
+			maxDistanceKm: 0,
+			want:          nil,
+			wantErr:       sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 10)
+
+			got, err := m.GetCandidatesForUser(ctx, "1", tt.maxDistanceKm, 0)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestMysqlStorage_likesQuery(t *testing.T) {
+	m := &MysqlStorage{
+		maxPageSize: 10,
+	}
+
+	got := m.likesQuery("", storage.LikesFilter{}, 20)
+	assert.Equal(t, "SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at, "+likerDistance+" AS distance_km FROM Decisions d JOIN Users a ON a.id = d.actor_id JOIN Users r ON r.id = d.recipient_id WHERE d.recipient_id = ? AND d.liked = TRUE ORDER BY d.created_at DESC LIMIT 10 OFFSET 20", got)
+
+	got = m.likesQuery("", storage.LikesFilter{MatchPreferences: true}, 0)
+	assert.Contains(t, got, "LEFT JOIN Preferences ap ON ap.user_id = a.id LEFT JOIN Preferences rp ON rp.user_id = r.id")
+	assert.Contains(t, got, "AND (COALESCE(rp.min_age, 0) = 0 OR TIMESTAMPDIFF(YEAR, a.birthdate, CURDATE()) >= rp.min_age)")
+	assert.Contains(t, got, "(rp.genders & (1 << a.gender)) <> 0")
+}
+
+func TestMysqlStorage_UpdateProfile(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET birthdate = ?, gender = ?, timezone = ? WHERE id = ?")).
+		WithArgs("1990-05-17", int32(protos.Gender_GENDER_FEMALE), "Europe/London", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	err = m.UpdateProfile(ctx, "1", &storage.Profile{
+		Birthdate: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Gender:    protos.Gender_GENDER_FEMALE,
+		TimeZone:  "Europe/London",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlStorage_UpdateProfile_UnknownUser(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET birthdate = ?, gender = ?, timezone = ? WHERE id = ?")).
+		WithArgs("1990-05-17", int32(protos.Gender_GENDER_FEMALE), "", "99").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM Users WHERE id = ?)")).WithArgs("99").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	err = m.UpdateProfile(ctx, "99", &storage.Profile{
+		Birthdate: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Gender:    protos.Gender_GENDER_FEMALE,
+	})
+	assert.Equal(t, storage.ErrUserNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlStorage_GetPreferences(t *testing.T) {
+	ctx := context.Background()
+
+	query := regexp.QuoteMeta("SELECT min_age, max_age, genders, intent FROM Preferences WHERE user_id = ?")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       *storage.Preferences
+		wantErr    error
+	}{
+		"preferences set": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"min_age", "max_age", "genders", "intent"}).AddRow(21, 0, 4, 1))
+			},
+			want: &storage.Preferences{
+				MinAge:  21,
+				Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE),
+				Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_LONG_TERM,
+			},
+			wantErr: nil,
+		},
+		"no preferences": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"min_age", "max_age", "genders", "intent"}))
+			},
+			want:    &storage.Preferences{},
+			wantErr: nil,
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("1").
+					WillReturnError(sql.ErrConnDone)
+			},
+			want:    nil,
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			got, err := m.GetPreferences(ctx, "1")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestMysqlStorage_UpdatePreferences(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Preferences (user_id, min_age, max_age, genders, intent) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE")).
+		WithArgs("1", 21, 35, uint32(6), int32(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	err = m.UpdatePreferences(ctx, "1", &storage.Preferences{
+		MinAge:  21,
+		MaxAge:  35,
+		Genders: storage.NewGenderSet(protos.Gender_GENDER_MALE, protos.Gender_GENDER_FEMALE),
+		Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_FRIENDSHIP,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlStorage_GetQuotaSettings(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+
+	query := regexp.QuoteMeta("SELECT u.timezone, e.user_id IS NOT NULL, e.daily_like_limit, e.expires_at FROM Users u LEFT JOIN Entitlements e ON e.user_id = u.id AND (e.expires_at IS NULL OR e.expires_at > ?) WHERE u.id = ?")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       *storage.QuotaSettings
+		wantErr    error
+	}{
+		"no entitlement": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "1").
+					WillReturnRows(sqlmock.NewRows([]string{"timezone", "has_entitlement", "daily_like_limit", "expires_at"}).AddRow("Europe/London", false, nil, nil))
+			},
+			want:    &storage.QuotaSettings{TimeZone: "Europe/London"},
+			wantErr: nil,
+		},
+		"entitlement with a limit": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "1").
+					WillReturnRows(sqlmock.NewRows([]string{"timezone", "has_entitlement", "daily_like_limit", "expires_at"}).AddRow("", true, 50, arbitraryTime))
+			},
+			want: &storage.QuotaSettings{
+				Entitlement: &storage.Entitlement{DailyLikeLimit: intPtr(50), ExpiresAt: &arbitraryTime},
+			},
+			wantErr: nil,
+		},
+		"unlimited entitlement": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "1").
+					WillReturnRows(sqlmock.NewRows([]string{"timezone", "has_entitlement", "daily_like_limit", "expires_at"}).AddRow("", true, nil, nil))
+			},
+			want: &storage.QuotaSettings{
+				Entitlement: &storage.Entitlement{},
+			},
+			wantErr: nil,
+		},
+		"user not found": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "1").
+					WillReturnRows(sqlmock.NewRows([]string{"timezone", "has_entitlement", "daily_like_limit", "expires_at"}))
+			},
+			want:    nil,
+			wantErr: storage.ErrUserNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			got, err := m.GetQuotaSettings(ctx, "1")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestMysqlStorage_ConsumeLike(t *testing.T) {
+	ctx := context.Background()
+
+	query := regexp.QuoteMeta("INSERT INTO DailyLikeQuotas (user_id, day, likes_used) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE likes_used = IF(likes_used < ?, likes_used + 1, likes_used)")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       bool
+		wantErr    error
+	}{
+		"first like of the day": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs("1", "2024-06-01", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			want:    true,
+			wantErr: nil,
+		},
+		"like within the limit": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs("1", "2024-06-01", 5).WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			want:    true,
+			wantErr: nil,
+		},
+		"limit reached": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs("1", "2024-06-01", 5).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want:    false,
+			wantErr: nil,
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs("1", "2024-06-01", 5).WillReturnError(sql.ErrConnDone)
+			},
+			want:    false,
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			got, err := m.ConsumeLike(ctx, "1", "2024-06-01", 5)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestMysqlStorage_GetLikesUsed(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	query := regexp.QuoteMeta("SELECT likes_used FROM DailyLikeQuotas WHERE user_id = ? AND day = ?")
+	mock.ExpectQuery(query).WithArgs("1", "2024-06-01").WillReturnRows(sqlmock.NewRows([]string{"likes_used"}).AddRow(3))
+	mock.ExpectQuery(query).WithArgs("1", "2024-06-02").WillReturnRows(sqlmock.NewRows([]string{"likes_used"}))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	got, err := m.GetLikesUsed(ctx, "1", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got)
+
+	got, err = m.GetLikesUsed(ctx, "1", "2024-06-02")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got)
+}
+
+func TestMysqlStorage_ListDecisionsAfter(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 2")).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at"}).
+			AddRow(11, 1, 2, true, arbitraryTime).
+			AddRow(12, 3, 2, false, arbitraryTime))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	got, err := m.ListDecisionsAfter(ctx, 10, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []*storage.Decision{
+		{ID: 11, ActorID: 1, RecipientID: 2, Liked: true, CreatedAt: arbitraryTime},
+		{ID: 12, ActorID: 3, RecipientID: 2, Liked: false, CreatedAt: arbitraryTime},
+	}, got)
+}
+
+func TestMysqlStorage_GetScores(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id, score, likes_given, decisions_given, updated_at FROM DesirabilityScores WHERE user_id IN (?, ?)")).
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "score", "likes_given", "decisions_given", "updated_at"}).
+			AddRow(1, 1520.5, 2, 5, arbitraryTime))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	got, err := m.GetScores(ctx, []int64{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*storage.Score{
+		1: {UserID: 1, Score: 1520.5, LikesGiven: 2, DecisionsGiven: 5, UpdatedAt: arbitraryTime},
+	}, got)
+
+	got, err = m.GetScores(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestMysqlStorage_SaveScores(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+
+	scoresQuery := regexp.QuoteMeta("INSERT INTO DesirabilityScores (user_id, score, likes_given, decisions_given, updated_at) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE")
+	cursorQuery := regexp.QuoteMeta("INSERT INTO JobCursors (name, last_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE last_id = VALUES(last_id)")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		wantErr    error
+	}{
+		"scores and cursor saved together": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(scoresQuery).
+					WithArgs(int64(1), 1500.0, int64(1), int64(1), arbitraryTime, int64(2), 1510.0, int64(0), int64(0), arbitraryTime).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(cursorQuery).WithArgs("desirability", int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantErr: nil,
+		},
+		"rolled back on error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(scoresQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(cursorQuery).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			err = m.SaveScores(ctx, []*storage.Score{
+				{UserID: 1, Score: 1500, LikesGiven: 1, DecisionsGiven: 1, UpdatedAt: arbitraryTime},
+				{UserID: 2, Score: 1510, UpdatedAt: arbitraryTime},
+			}, 7)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_GetScoreCursor(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	query := regexp.QuoteMeta("SELECT last_id FROM JobCursors WHERE name = ?")
+	mock.ExpectQuery(query).WithArgs("desirability").WillReturnRows(sqlmock.NewRows([]string{"last_id"}))
+	mock.ExpectQuery(query).WithArgs("desirability").WillReturnRows(sqlmock.NewRows([]string{"last_id"}).AddRow(42))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	got, err := m.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), got)
+
+	got, err = m.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), got)
+}
+
+func TestMysqlStorage_SaveRecommendations(t *testing.T) {
+	ctx := context.Background()
+
+	deleteQuery := regexp.QuoteMeta("DELETE FROM Recommendations WHERE user_id = ?")
+	insertQuery := regexp.QuoteMeta("INSERT INTO Recommendations (user_id, candidate_id, ranking, score) VALUES (?, ?, ?, ?), (?, ?, ?, ?)")
+
+	tests := map[string]struct {
+		recommendations []*storage.Recommendation
+		dbOutcomes      func(mock sqlmock.Sqlmock)
+		wantErr         error
+	}{
+		"replaced in order": {
+			recommendations: []*storage.Recommendation{
+				{CandidateID: 5, Score: 0.9},
+				{CandidateID: 3, Score: 0.4},
+			},
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(insertQuery).
+					WithArgs(int64(1), int64(5), 1, 0.9, int64(1), int64(3), 2, 0.4).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			wantErr: nil,
+		},
+		"no recommendations": {
+			recommendations: nil,
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+			wantErr: nil,
+		},
+		"rolled back on error": {
+			recommendations: []*storage.Recommendation{
+				{CandidateID: 5, Score: 0.9},
+				{CandidateID: 3, Score: 0.4},
+			},
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(insertQuery).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			err = m.SaveRecommendations(ctx, 1, tt.recommendations)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_ActivateBoost(t *testing.T) {
+	ctx := context.Background()
+
+	spendQuery := regexp.QuoteMeta("UPDATE BoostEntitlements SET boosts_remaining = boosts_remaining - 1 WHERE user_id = ? AND boosts_remaining > 0 AND (expires_at IS NULL OR expires_at > ?)")
+	activeQuery := regexp.QuoteMeta("SELECT COUNT(*) FROM Boosts WHERE user_id = ? AND ends_at > ?")
+	insertQuery := regexp.QuoteMeta("INSERT INTO Boosts (user_id, starts_at, ends_at) VALUES (?, ?, ?)")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		wantErr    error
+	}{
+		"boost activated": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(spendQuery).WithArgs("1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(activeQuery).WithArgs("1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WithArgs("1", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantErr: nil,
+		},
+		"no boosts remaining": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(spendQuery).WithArgs("1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: storage.ErrNoBoosts,
+		},
+		"boost already active": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(spendQuery).WithArgs("1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(activeQuery).WithArgs("1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectRollback()
+			},
+			wantErr: storage.ErrBoostActive,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+
+			got, err := m.ActivateBoost(ctx, "1", time.Hour)
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, time.Hour, got.EndsAt.Sub(got.StartsAt))
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_GetLatestBoost(t *testing.T) {
+	ctx := context.Background()
+	startsAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(time.Hour)
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	query := regexp.QuoteMeta("SELECT b.starts_at, b.ends_at, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = b.user_id AND d.liked = TRUE AND d.created_at >= b.starts_at AND d.created_at < b.ends_at) FROM Boosts b WHERE b.user_id = ? ORDER BY b.starts_at DESC LIMIT 1")
+	mock.ExpectQuery(query).WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"starts_at", "ends_at", "likes_received"}).AddRow(startsAt, endsAt, 7))
+	mock.ExpectQuery(query).WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"starts_at", "ends_at", "likes_received"}))
+
+	m := NewMysqlStorage(mockDB, 0)
+
+	got, err := m.GetLatestBoost(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.Boost{StartsAt: startsAt, EndsAt: endsAt, LikesReceived: 7}, got)
+
+	_, err = m.GetLatestBoost(ctx, "2")
+	assert.Equal(t, storage.ErrBoostNotFound, err)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestMysqlStorage_RepairLikeCounters(t *testing.T) {
+	ctx := context.Background()
+	driftQuery := regexp.QuoteMeta("SELECT u.id, COALESCE(lc.likes_received, 0) AS stored, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) AS actual FROM Users u LEFT JOIN LikeCounters lc ON lc.user_id = u.id HAVING stored <> actual ORDER BY u.id")
+	repairQuery := regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) SELECT ?, COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE ON DUPLICATE KEY UPDATE likes_received = VALUES(likes_received)")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       []*storage.LikeCounterDrift
+		wantErr    error
+	}{
+		"counters drifted": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(driftQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "stored", "actual"}).AddRow(2, 3, 4).AddRow(5, 1, 0))
+				mock.ExpectExec(repairQuery).WithArgs(int64(2), int64(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(repairQuery).WithArgs(int64(5), int64(5)).WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			want: []*storage.LikeCounterDrift{
+				{UserID: 2, Stored: 3, Actual: 4},
+				{UserID: 5, Stored: 1, Actual: 0},
+			},
+			wantErr: nil,
+		},
+		"counters correct": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(driftQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "stored", "actual"}))
+			},
+			want:    nil,
+			wantErr: nil,
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(driftQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "stored", "actual"}).AddRow(2, 3, 4))
+				mock.ExpectExec(repairQuery).WillReturnError(sql.ErrConnDone)
+			},
+			want:    nil,
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0)
+			got, err := m.RepairLikeCounters(ctx)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
@@ -0,0 +1,279 @@
+// Package retention archives old decisions that no longer affect anything users see. Passes older than a cutoff are
+// archived unless the user passed on has decided on the passer, as the pass is what keeps their like out of new likes,
+// and optionally so are likes that weren't liked back. Likes that are part of a match are never archived, so matches,
+// and the likes and new likes made of the decisions kept, are unchanged. Users come back into the candidates of those
+// whose passes on them are archived.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/internal/sqlutil"
+	"muzz-project/storage/mysql/transfer"
+	"os"
+	"time"
+)
+
+// Archive stores decisions before they're deleted
+type Archive interface {
+	// Store is called in the transaction deleting decisions, which is rolled back if it fails and may be run again
+	Store(ctx context.Context, tx *mysql.Tx, decisions []*transfer.Decision) error
+}
+
+// TableArchive moves decisions to the DecisionsArchive table, in the same transaction that deletes them
+type TableArchive struct{}
+
+func (TableArchive) Store(ctx context.Context, tx *mysql.Tx, decisions []*transfer.Decision) error {
+	var args []any
+	for _, d := range decisions {
+		args = append(args, d.ID, d.ActorID, d.RecipientID, d.Liked, d.CreatedAt)
+	}
+	query := "INSERT INTO DecisionsArchive (id, actor_id, recipient_id, liked, created_at) VALUES " + sqlutil.ValuesList(len(decisions), 5)
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// FileArchive appends decisions to a JSONL file, in the format explorectl data imports. Decisions are written before
+// they're deleted, so if deleting them fails they're archived again by a retry or the next run and appear twice.
+type FileArchive struct {
+	file   *os.File
+	writer *transfer.Writer[transfer.Decision]
+}
+
+// OpenFileArchive opens path to append to, creating it if it doesn't exist
+func OpenFileArchive(path string) (*FileArchive, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileArchive{file: file, writer: transfer.NewDecisionWriter(file, transfer.JSONL)}, nil
+}
+
+func (a *FileArchive) Store(ctx context.Context, tx *mysql.Tx, decisions []*transfer.Decision) error {
+	for _, d := range decisions {
+		if err := a.writer.Write(d); err != nil {
+			return err
+		}
+	}
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *FileArchive) Close() error {
+	return a.file.Close()
+}
+
+type Result struct {
+	Passes int
+	Likes  int
+}
+
+// Job archives decisions older than a number of months, a batch at a time. Each batch is archived and deleted in a
+// transaction that locks the decisions and the gaps where their reverse decisions would go, so a reply made while
+// it runs either stops the decision being archived or waits for the batch to finish.
+type Job struct {
+	db           *mysql.DB
+	archive      Archive
+	months       int
+	batchSize    int
+	includeLikes bool
+	pause        time.Duration
+	now          func() time.Time
+}
+
+type Option func(*Job)
+
+// WithUnreciprocatedLikes archives old likes that the recipient hasn't liked back too, taking them out of the
+// recipient's likes. If the recipient likes the liker afterwards it won't be a match.
+func WithUnreciprocatedLikes() Option {
+	return func(j *Job) {
+		j.includeLikes = true
+	}
+}
+
+// WithPause waits between batches, leaving time for other writes to the table
+func WithPause(pause time.Duration) Option {
+	return func(j *Job) {
+		j.pause = pause
+	}
+}
+
+func NewJob(db *mysql.DB, archive Archive, months int, batchSize int, opts ...Option) *Job {
+	j := &Job{
+		db:        db,
+		archive:   archive,
+		months:    months,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// condition matches the decisions d that can be archived, with r the reverse decision LEFT JOINed
+func (j *Job) condition() string {
+	passes := "(d.liked = FALSE AND r.id IS NULL)"
+	if !j.includeLikes {
+		return passes
+	}
+	return "(" + passes + " OR (d.liked = TRUE AND (r.id IS NULL OR r.liked = FALSE)))"
+}
+
+// DryRun returns how many decisions Run would archive now, without archiving them
+func (j *Job) DryRun(ctx context.Context) (Result, error) {
+	var result Result
+	if err := mysql.CheckUnsharded(ctx, j.db); err != nil {
+		return result, err
+	}
+	query := "SELECT d.liked, COUNT(*) FROM Decisions d LEFT JOIN Decisions r ON r.actor_id = d.recipient_id AND r.recipient_id = d.actor_id WHERE d.created_at < ? AND " + j.condition() + " GROUP BY d.liked"
+
+	rows, err := j.db.QueryContext(ctx, query, j.cutoff())
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var liked bool
+		var count int
+		if err := rows.Scan(&liked, &count); err != nil {
+			return result, err
+		}
+		if liked {
+			result.Likes = count
+		} else {
+			result.Passes = count
+		}
+	}
+
+	return result, rows.Err()
+}
+
+// Run archives batches, oldest first, until none are left, and returns how many decisions it archived. Passes made
+// in reply to likes it archives are left for the next run. It returns mysql.ErrSharded without archiving anything once
+// decisions are sharded, as only the main database's are archived.
+func (j *Job) Run(ctx context.Context) (Result, error) {
+	var result Result
+	if err := mysql.CheckUnsharded(ctx, j.db); err != nil {
+		return result, err
+	}
+	cutoff := j.cutoff()
+	var last *transfer.Decision
+	for {
+		batch, err := j.archiveBatch(ctx, cutoff, last)
+		if err != nil {
+			return result, err
+		}
+		for _, d := range batch {
+			last = d
+			if d.Liked {
+				result.Likes++
+			} else {
+				result.Passes++
+			}
+		}
+		if len(batch) < j.batchSize {
+			return result, nil
+		}
+
+		if j.pause > 0 {
+			timer := time.NewTimer(j.pause)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return result, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func (j *Job) cutoff() time.Time {
+	return j.now().AddDate(0, -j.months, 0)
+}
+
+// archiveBatch archives and deletes up to batchSize decisions made before cutoff and after the last one archived,
+// returning them
+func (j *Job) archiveBatch(ctx context.Context, cutoff time.Time, last *transfer.Decision) ([]*transfer.Decision, error) {
+	var batch []*transfer.Decision
+	err := j.db.InTx(ctx, func(tx *mysql.Tx) error {
+		var err error
+		batch, err = j.lockBatch(ctx, tx, cutoff, last)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		return j.deleteBatch(ctx, tx, batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// lockBatch locks and returns the next batchSize decisions made before cutoff and after last that can be archived
+func (j *Job) lockBatch(ctx context.Context, tx *mysql.Tx, cutoff time.Time, last *transfer.Decision) ([]*transfer.Decision, error) {
+	//Starting after the last decision archived skips the old decisions that are kept, such as matches
+	after := ""
+	args := []any{cutoff}
+	if last != nil {
+		after = " AND (d.created_at > ? OR (d.created_at = ? AND d.id > ?))"
+		args = append(args, last.CreatedAt, last.CreatedAt, last.ID)
+	}
+
+	//FOR UPDATE locks where a missing reverse decision would be inserted, so it can't appear before the decision is
+	//deleted
+	query := fmt.Sprintf("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at FROM Decisions d LEFT JOIN Decisions r ON r.actor_id = d.recipient_id AND r.recipient_id = d.actor_id WHERE d.created_at < ?%s AND %s ORDER BY d.created_at, d.id LIMIT %d FOR UPDATE", after, j.condition(), j.batchSize)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var batch []*transfer.Decision
+	for rows.Next() {
+		var d transfer.Decision
+		if err := rows.Scan(&d.ID, &d.ActorID, &d.RecipientID, &d.Liked, &d.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		batch = append(batch, &d)
+	}
+	err = rows.Err()
+	rows.Close()
+	return batch, err
+}
+
+// deleteBatch archives batch, deletes it and takes its likes off the like counters
+func (j *Job) deleteBatch(ctx context.Context, tx *mysql.Tx, batch []*transfer.Decision) error {
+	if err := j.archive.Store(ctx, tx, batch); err != nil {
+		return err
+	}
+
+	ids := make([]any, len(batch))
+	likes := make(map[int64]int)
+	var recipients []int64
+	for i, d := range batch {
+		ids[i] = d.ID
+		if d.Liked {
+			if likes[d.RecipientID] == 0 {
+				recipients = append(recipients, d.RecipientID)
+			}
+			likes[d.RecipientID]++
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM Decisions WHERE id IN (%s)", sqlutil.Placeholders(len(ids))), ids...); err != nil {
+		return err
+	}
+
+	//Like counters count live likes, so archived likes come off them
+	counterQuery := `UPDATE LikeCounters SET likes_received = GREATEST(likes_received - ?, 0) WHERE user_id = ?`
+	for _, recipientId := range recipients {
+		if _, err := tx.ExecContext(ctx, counterQuery, likes[recipientId], recipientId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
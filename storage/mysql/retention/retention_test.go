@@ -0,0 +1,190 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"muzz-project/storage/mysql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	now       = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutoff    = time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	createdAt = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	columns   = []string{"id", "actor_id", "recipient_id", "liked", "created_at"}
+)
+
+const (
+	passesCondition = "(d.liked = FALSE AND r.id IS NULL)"
+	likesCondition  = "((d.liked = FALSE AND r.id IS NULL) OR (d.liked = TRUE AND (r.id IS NULL OR r.liked = FALSE)))"
+	counterQuery    = "UPDATE LikeCounters SET likes_received = GREATEST(likes_received - ?, 0) WHERE user_id = ?"
+)
+
+func expectSharded(mock sqlmock.Sqlmock, sharded bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM UserShards)")).WillReturnRows(sqlmock.NewRows([]string{"sharded"}).AddRow(sharded))
+}
+
+func selectQuery(condition string, after bool) string {
+	afterCondition := ""
+	if after {
+		afterCondition = " AND (d.created_at > ? OR (d.created_at = ? AND d.id > ?))"
+	}
+	return regexp.QuoteMeta("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at FROM Decisions d LEFT JOIN Decisions r ON r.actor_id = d.recipient_id AND r.recipient_id = d.actor_id WHERE d.created_at < ?" + afterCondition + " AND " + condition + " ORDER BY d.created_at, d.id LIMIT 2 FOR UPDATE")
+}
+
+func TestJob_Run(t *testing.T) {
+	archiveQuery := regexp.QuoteMeta("INSERT INTO DecisionsArchive (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)")
+
+	tests := map[string]struct {
+		sharded    bool
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		opts       []Option
+		want       Result
+		wantErr    error
+	}{
+		"archives passes in batches": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(passesCondition, false)).WithArgs(cutoff).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, false, createdAt).AddRow(3, 1, 4, false, createdAt))
+				mock.ExpectExec(archiveQuery).WithArgs(1, 1, 2, false, createdAt, 3, 1, 4, false, createdAt).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Decisions WHERE id IN (?, ?)")).WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(passesCondition, true)).WithArgs(cutoff, createdAt, createdAt, 3).
+					WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectCommit()
+			},
+			want: Result{Passes: 2},
+		},
+		"retries a deadlocked batch": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(passesCondition, false)).WithArgs(cutoff).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, false, createdAt))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO DecisionsArchive (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)")).
+					WillReturnError(&driver.MySQLError{Number: 1213})
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(passesCondition, false)).WithArgs(cutoff).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, false, createdAt))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO DecisionsArchive (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)")).
+					WithArgs(1, 1, 2, false, createdAt).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Decisions WHERE id IN (?)")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: Result{Passes: 1},
+		},
+		"archives unreciprocated likes": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(likesCondition, false)).WithArgs(cutoff).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, true, createdAt).AddRow(3, 4, 2, true, createdAt))
+				mock.ExpectExec(archiveQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Decisions WHERE id IN (?, ?)")).WithArgs(1, 3).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(regexp.QuoteMeta(counterQuery)).WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(likesCondition, true)).WithArgs(cutoff, createdAt, createdAt, 3).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(5, 6, 1, false, createdAt))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO DecisionsArchive (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)")).
+					WithArgs(5, 6, 1, false, createdAt).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Decisions WHERE id IN (?)")).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			opts: []Option{WithUnreciprocatedLikes()},
+			want: Result{Passes: 1, Likes: 2},
+		},
+		"failed archive deletes nothing": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectQuery(passesCondition, false)).WithArgs(cutoff).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, false, createdAt).AddRow(3, 1, 4, false, createdAt))
+				mock.ExpectExec(archiveQuery).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+		"refuses sharded databases": {
+			sharded:    true,
+			dbOutcomes: func(mock sqlmock.Sqlmock) {},
+			wantErr:    mysql.ErrSharded,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			expectSharded(mock, tt.sharded)
+			tt.dbOutcomes(mock)
+
+			j := NewJob(mysql.NewDB(mockDB), TableArchive{}, 6, 2, tt.opts...)
+			j.now = func() time.Time { return now }
+			got, err := j.Run(context.Background())
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestJob_DryRun(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	expectSharded(mock, false)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT d.liked, COUNT(*) FROM Decisions d LEFT JOIN Decisions r ON r.actor_id = d.recipient_id AND r.recipient_id = d.actor_id WHERE d.created_at < ? AND " + likesCondition + " GROUP BY d.liked")).
+		WithArgs(cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"liked", "COUNT(*)"}).AddRow(false, 10).AddRow(true, 4))
+
+	j := NewJob(mysql.NewDB(mockDB), TableArchive{}, 6, 2, WithUnreciprocatedLikes())
+	j.now = func() time.Time { return now }
+	got, err := j.DryRun(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Passes: 10, Likes: 4}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFileArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	expectSharded(mock, false)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery(passesCondition, false)).WithArgs(cutoff).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, false, createdAt))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Decisions WHERE id IN (?)")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	//Runs append to the archive
+	assert.NoError(t, os.WriteFile(path, []byte(`{"id":0}`+"\n"), 0o644))
+	archive, err := OpenFileArchive(path)
+	assert.NoError(t, err)
+	j := NewJob(mysql.NewDB(mockDB), archive, 6, 2)
+	j.now = func() time.Time { return now }
+	got, err := j.Run(context.Background())
+	assert.NoError(t, archive.Close())
+
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Passes: 1}, got)
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":0}`+"\n"+`{"id":1,"actor_id":1,"recipient_id":2,"liked":false,"created_at":"2023-01-01T00:00:00Z"}`+"\n", string(contents))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,290 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"muzz-project/logging"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MySQL error numbers for transient failures, reported once the failed statement has been rolled back
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// DefaultRetryPolicy retries each operation up to 3 times, waiting 70-140ms in total
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   20 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// RetryPolicy is how operations failing with a transient error are retried
+type RetryPolicy struct {
+	// MaxAttempts is how many times an operation is tried, including the first, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubling for each retry after it up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// backoff returns the wait before retry number retry, counting from 1. Half of it is random so clients that failed
+// together, as both sides of a deadlock do, don't retry together.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+type RetryStats struct {
+	// Deadlocks, LockWaitTimeouts and ConnectionErrors count retries made after each kind of failure
+	Deadlocks        uint64
+	LockWaitTimeouts uint64
+	ConnectionErrors uint64
+	// GaveUp counts operations that failed with a transient error but weren't retried again, because they were out
+	// of attempts or the context deadline would pass first
+	GaveUp uint64
+}
+
+// Retrier retries operations under a policy and counts the retries, it can be shared by storages so their retries
+// are counted together
+type Retrier struct {
+	policy RetryPolicy
+
+	deadlocks        atomic.Uint64
+	lockWaitTimeouts atomic.Uint64
+	connectionErrors atomic.Uint64
+	gaveUp           atomic.Uint64
+}
+
+func NewRetrier(policy RetryPolicy) *Retrier {
+	return &Retrier{policy: policy}
+}
+
+func (r *Retrier) Stats() RetryStats {
+	return RetryStats{
+		Deadlocks:        r.deadlocks.Load(),
+		LockWaitTimeouts: r.lockWaitTimeouts.Load(),
+		ConnectionErrors: r.connectionErrors.Load(),
+		GaveUp:           r.gaveUp.Load(),
+	}
+}
+
+type failure int
+
+const (
+	permanent failure = iota
+	deadlock
+	lockWaitTimeout
+	connectionError
+)
+
+func (f failure) String() string {
+	switch f {
+	case deadlock:
+		return "deadlock"
+	case lockWaitTimeout:
+		return "lock wait timeout"
+	case connectionError:
+		return "connection error"
+	}
+	return "permanent"
+}
+
+// classify returns the kind of transient failure err is, or permanent if retrying won't help. The server rolls back
+// the statement it reports a deadlock or lock wait timeout for, so any statement can be retried after one. A lost
+// connection may have lost the reply to a write that was applied, so it's only retried for reads, unless the driver
+// reports the statement was never sent.
+func classify(err error, idempotent bool) failure {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return permanent
+	case isMysqlError(err, errDeadlock):
+		return deadlock
+	case isMysqlError(err, errLockWaitTimeout):
+		return lockWaitTimeout
+	case errors.Is(err, sqldriver.ErrBadConn):
+		return connectionError
+	case idempotent && (errors.Is(err, driver.ErrInvalidConn) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)):
+		return connectionError
+	}
+	return permanent
+}
+
+// do runs op until it succeeds, fails permanently or is out of attempts, returning its last error. It doesn't wait
+// for a retry that couldn't start before ctx's deadline.
+func (r *Retrier) do(ctx context.Context, idempotent bool, op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		kind := classify(err, idempotent)
+		if kind == permanent {
+			return err
+		}
+
+		logger := logging.FromContext(ctx).With("failure", kind.String(), "attempt", attempt, "error", err)
+		delay := r.policy.backoff(attempt)
+		if attempt >= r.policy.MaxAttempts {
+			r.gaveUp.Add(1)
+			logger.Warn("mysql operation out of attempts")
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			r.gaveUp.Add(1)
+			logger.Warn("mysql operation out of time to retry")
+			return err
+		}
+		logger.Info("retrying mysql operation", "delay", delay.String())
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.String("failure", kind.String()),
+			attribute.Int("attempt", attempt),
+			attribute.String("error", err.Error()),
+		))
+
+		switch kind {
+		case deadlock:
+			r.deadlocks.Add(1)
+		case lockWaitTimeout:
+			r.lockWaitTimeouts.Add(1)
+		case connectionError:
+			r.connectionErrors.Add(1)
+		}
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// DB retries statements run outside a transaction, and transactions run with InTx, that fail transiently.
+// Statements run on a transaction from BeginTx aren't retried on their own as the whole transaction has to be. Each
+// statement and transaction is traced, with its retries.
+type DB struct {
+	*sql.DB
+	retrier *Retrier
+	tracer  trace.Tracer
+}
+
+// NewDB wraps db to retry and trace like MysqlStorage, for tools running their own statements. Only the WithRetrier
+// and WithTracerProvider options apply.
+func NewDB(db *sql.DB, opts ...Option) *DB {
+	return NewMysqlStorage(db, 0, opts...).db
+}
+
+// QueryContext is like sql.DB's, with the query retried. Its span ends once the first rows are returned.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, d.tracer, query)
+	var rows *sql.Rows
+	err := d.retrier.do(ctx, true, func() error {
+		var err error
+		rows, err = d.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	endSpan(span, err)
+	return rows, err
+}
+
+// QueryRowContext is like sql.DB's, with the query retried. Errors reading the row once it's been returned aren't.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	rows, err := d.QueryContext(ctx, query, args...)
+	return &Row{rows: rows, err: err}
+}
+
+// ExecContext is like sql.DB's, with the statement retried as long as it can't have been applied
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, d.tracer, query)
+	var result sql.Result
+	err := d.retrier.do(ctx, false, func() error {
+		var err error
+		result, err = d.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	endSpan(span, err)
+	return result, err
+}
+
+// InTx runs op in a transaction and commits it, running it again in a new transaction if it fails transiently. op
+// must set any results afresh each time it runs. A failed commit isn't retried as it may have been applied.
+func (d *DB) InTx(ctx context.Context, op func(tx *Tx) error) error {
+	ctx, span := d.tracer.Start(ctx, "TRANSACTION", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemMySQL))
+	var commitErr error
+	err := d.retrier.do(ctx, true, func() error {
+		tx, err := d.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := op(&Tx{Tx: tx, tracer: d.tracer, span: span}); err != nil {
+			return err
+		}
+		commitErr = tx.Commit()
+		return nil
+	})
+	if err == nil {
+		err = commitErr
+	}
+	endSpan(span, err)
+	return err
+}
+
+// InSnapshot runs op in a read only transaction reading everything from one snapshot, traced like InTx. It isn't run
+// again if it fails, so op can write out what it reads as it goes.
+func (d *DB) InSnapshot(ctx context.Context, op func(tx *Tx) error) error {
+	ctx, span := d.tracer.Start(ctx, "TRANSACTION", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemMySQL))
+	var tx *sql.Tx
+	err := d.retrier.do(ctx, true, func() error {
+		var err error
+		tx, err = d.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		return err
+	})
+	if err == nil {
+		//Nothing was written, so there's nothing to commit
+		err = op(&Tx{Tx: tx, tracer: d.tracer, span: span})
+		tx.Rollback()
+	}
+	endSpan(span, err)
+	return err
+}
+
+// Row is a single row result, like sql.Row
+type Row struct {
+	rows *sql.Rows
+	err  error
+}
+
+// Scan copies the row's columns into dest, returning sql.ErrNoRows if there's no row
+func (r *Row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
@@ -0,0 +1,206 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	deadlockErr        = &driver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+	lockWaitTimeoutErr = &driver.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}
+)
+
+func TestMysqlStorage_AddDecisionRetries(t *testing.T) {
+	matchQuery := `SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`
+	insertQuery := regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")
+	counterQuery := regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       bool
+		wantErr    error
+		wantStats  RetryStats
+	}{
+		"deadlock reruns the transaction": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnError(deadlockErr)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want:      true,
+			wantStats: RetryStats{Deadlocks: 1},
+		},
+		"lost connection reruns the transaction": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnError(driver.ErrInvalidConn)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WillReturnError(lockWaitTimeoutErr)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want:      false,
+			wantStats: RetryStats{LockWaitTimeouts: 1, ConnectionErrors: 1},
+		},
+		"gives up after the last attempt": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+					mock.ExpectExec(insertQuery).WillReturnError(deadlockErr)
+					mock.ExpectRollback()
+				}
+			},
+			wantErr:   deadlockErr,
+			wantStats: RetryStats{Deadlocks: 2, GaveUp: 1},
+		},
+		"failed commit isn't retried": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(driver.ErrInvalidConn)
+			},
+			wantErr: driver.ErrInvalidConn,
+		},
+		"other errors aren't retried": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0, WithRetrier(NewRetrier(RetryPolicy{MaxAttempts: 3})))
+			got, err := m.AddDecision(context.Background(), "1", "2", true)
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantStats, m.RetryStats())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMysqlStorage_StatementRetries(t *testing.T) {
+	countQuery := `SELECT likes_received FROM LikeCounters WHERE user_id = \?`
+	consumeQuery := regexp.QuoteMeta("INSERT INTO DailyLikeQuotas (user_id, day, likes_used) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE likes_used = IF(likes_used < ?, likes_used + 1, likes_used)")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		run        func(m *MysqlStorage) error
+		wantErr    error
+		wantStats  RetryStats
+	}{
+		"read retried after lost connection": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(countQuery).WillReturnError(driver.ErrInvalidConn)
+				mock.ExpectQuery(countQuery).WillReturnRows(sqlmock.NewRows([]string{"likes_received"}).AddRow(5))
+			},
+			run: func(m *MysqlStorage) error {
+				count, err := m.GetLikesCountForUser(context.Background(), "1")
+				assert.Equal(t, int64(5), count)
+				return err
+			},
+			wantStats: RetryStats{ConnectionErrors: 1},
+		},
+		"write retried after deadlock": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(consumeQuery).WillReturnError(deadlockErr)
+				mock.ExpectExec(consumeQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(m *MysqlStorage) error {
+				consumed, err := m.ConsumeLike(context.Background(), "1", "2024-06-01", 5)
+				assert.True(t, consumed)
+				return err
+			},
+			wantStats: RetryStats{Deadlocks: 1},
+		},
+		"write not retried after lost connection": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(consumeQuery).WillReturnError(driver.ErrInvalidConn)
+			},
+			run: func(m *MysqlStorage) error {
+				_, err := m.ConsumeLike(context.Background(), "1", "2024-06-01", 5)
+				return err
+			},
+			wantErr: driver.ErrInvalidConn,
+		},
+		"not retried past the deadline": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(countQuery).WillReturnError(lockWaitTimeoutErr)
+			},
+			run: func(m *MysqlStorage) error {
+				m.retrier.policy.BaseDelay = time.Minute
+				m.retrier.policy.MaxDelay = time.Minute
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				_, err := m.GetLikesCountForUser(ctx, "1")
+				return err
+			},
+			wantErr:   lockWaitTimeoutErr,
+			wantStats: RetryStats{GaveUp: 1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			m := NewMysqlStorage(mockDB, 0, WithRetrier(NewRetrier(RetryPolicy{MaxAttempts: 3})))
+			err = tt.run(m)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantStats, m.RetryStats())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 9: time.Second} {
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(retry)
+			assert.GreaterOrEqual(t, delay, want/2)
+			assert.LessOrEqual(t, delay, want)
+		}
+	}
+	assert.Zero(t, RetryPolicy{MaxAttempts: 3}.backoff(1))
+}
@@ -0,0 +1,944 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"muzz-project/logging"
+	"muzz-project/storage"
+	"muzz-project/storage/mysql/internal/sqlutil"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moveBatchSize is how many rows are copied or deleted at once when moving users between shards
+const moveBatchSize = 1000
+
+var (
+	ErrUnknownShard   = fmt.Errorf("shard isn't in the shard map")
+	ErrMoveInProgress = fmt.Errorf("user is already moving to another shard")
+	ErrSharded        = fmt.Errorf("decisions are sharded, so the main database's are out of date")
+)
+
+// CheckUnsharded returns ErrSharded if any user has a home shard, for tools that only read and write Decisions on the
+// main database. Once sharded, decisions are made on the shards and the main database's stop being kept up to date.
+func CheckUnsharded(ctx context.Context, db *DB) error {
+	var sharded bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM UserShards)`).Scan(&sharded); err != nil {
+		return err
+	}
+	if sharded {
+		return ErrSharded
+	}
+	return nil
+}
+
+// home is where a user's decisions are stored
+type home struct {
+	shard int
+	// movingTo is set while the user is being moved, their writes go to both shards until it's done
+	movingTo sql.NullInt64
+}
+
+// writeShards returns every shard the user's writes have to go to, their home shard first
+func (h home) writeShards() []int {
+	if h.movingTo.Valid {
+		return []int{h.shard, int(h.movingTo.Int64)}
+	}
+	return []int{h.shard}
+}
+
+// ShardedStorage keeps Decisions on shards, each user's on their home shard recorded in UserShards, and everything
+// else on the main database. Decisions made on a user are stored on their shard, and the decisions they've made are
+// copied to ActorDecisions on it, so a user's likes, new likes and candidates only need their own shard.
+type ShardedStorage struct {
+	*MysqlStorage
+	shards   map[int]*DB
+	newUsers []int
+}
+
+var _ storage.Storage = (*ShardedStorage)(nil)
+
+// NewShardedStorage returns a storage using db as the main database and shards, keyed by shard ID, for Decisions.
+// Users without a shard are placed on one of newUsers.
+func NewShardedStorage(db *sql.DB, shards map[int]*sql.DB, newUsers []int, maxPageSize int, opts ...Option) *ShardedStorage {
+	s := &ShardedStorage{
+		MysqlStorage: NewMysqlStorage(db, maxPageSize, opts...),
+		shards:       make(map[int]*DB),
+		newUsers:     newUsers,
+	}
+	for id, shard := range shards {
+		s.shards[id] = &DB{DB: shard, retrier: s.retrier, tracer: s.tracer}
+	}
+	return s
+}
+
+// homes returns the home shards of userIds. With assign, users who don't have one yet are given one, otherwise they
+// are left out as they have no decisions. Users that don't exist are always left out.
+func (s *ShardedStorage) homes(ctx context.Context, assign bool, userIds ...int64) (map[int64]home, error) {
+	homes, err := s.readHomes(ctx, userIds)
+	if err != nil || !assign {
+		return homes, err
+	}
+
+	assigned := false
+	for _, userId := range userIds {
+		if _, ok := homes[userId]; ok {
+			continue
+		}
+		//IGNORE also skips users that don't exist, and users another server has just placed
+		query := `INSERT IGNORE INTO UserShards (user_id, shard) VALUES (?, ?)`
+		if _, err := s.db.ExecContext(ctx, query, userId, s.newUsers[userId%int64(len(s.newUsers))]); err != nil {
+			return nil, err
+		}
+		assigned = true
+	}
+
+	if !assigned {
+		return homes, nil
+	}
+	return s.readHomes(ctx, userIds)
+}
+
+func (s *ShardedStorage) readHomes(ctx context.Context, userIds []int64) (map[int64]home, error) {
+	args := make([]any, len(userIds))
+	for i, userId := range userIds {
+		args[i] = userId
+	}
+
+	query := `SELECT user_id, shard, moving_to FROM UserShards WHERE user_id IN (` + sqlutil.Placeholders(len(userIds)) + `)`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	homes := make(map[int64]home)
+	for rows.Next() {
+		var userId int64
+		var h home
+		if err := rows.Scan(&userId, &h.shard, &h.movingTo); err != nil {
+			return nil, err
+		}
+		homes[userId] = h
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return homes, nil
+}
+
+// nextDecisionId returns a decision ID that's unique across every shard
+func (s *ShardedStorage) nextDecisionId(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE DecisionSequence SET id = LAST_INSERT_ID(id + 1)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *ShardedStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return s.getLikes(ctx, userId, false, paginationToken, filter)
+}
+
+func (s *ShardedStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return s.getLikes(ctx, userId, true, paginationToken, filter)
+}
+
+func (s *ShardedStorage) getLikes(ctx context.Context, userId string, onlyNew bool, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	recipientId, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	homes, err := s.homes(ctx, false, recipientId)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := homes[recipientId]
+	if !ok {
+		return nil, nil
+	}
+
+	if !filter.MatchPreferences {
+		likes, err := s.shardLikes(ctx, h.shard, recipientId, onlyNew, paginationToken)
+		if err != nil {
+			return nil, err
+		}
+		return s.addDistances(ctx, recipientId, likes, false)
+	}
+
+	//Likers are matched against preferences on the main database, so likes are read in batches until a page of them
+	//match. Pages further in have to skip over every earlier match.
+	var page []*storage.Decision
+	skip := paginationToken
+	for offset := 0; ; offset += s.maxPageSize {
+		batch, err := s.shardLikes(ctx, h.shard, recipientId, onlyNew, offset)
+		if err != nil {
+			return nil, err
+		}
+		matching, err := s.addDistances(ctx, recipientId, batch, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, like := range matching {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			page = append(page, like)
+			if len(page) == s.maxPageSize {
+				return page, nil
+			}
+		}
+		if len(batch) < s.maxPageSize {
+			return page, nil
+		}
+	}
+}
+
+// shardLikes returns a page of the likes recipientId has received, newest first
+func (s *ShardedStorage) shardLikes(ctx context.Context, shard int, recipientId int64, onlyNew bool, offset int) ([]*storage.Decision, error) {
+	var query strings.Builder
+	query.WriteString("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at FROM Decisions d WHERE d.recipient_id = ? AND d.liked = TRUE")
+	if onlyNew {
+		query.WriteString(" AND NOT EXISTS (SELECT 1 FROM ActorDecisions ad WHERE ad.actor_id = d.recipient_id AND ad.recipient_id = d.actor_id)")
+	}
+	fmt.Fprintf(&query, " ORDER BY d.created_at DESC, d.id DESC LIMIT %d OFFSET %d", s.maxPageSize, offset)
+
+	rows, err := s.shards[shard].QueryContext(ctx, query.String(), recipientId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var likes []*storage.Decision
+	for rows.Next() {
+		var like storage.Decision
+		if err := rows.Scan(&like.ID, &like.ActorID, &like.RecipientID, &like.Liked, &like.CreatedAt); err != nil {
+			return nil, err
+		}
+		likes = append(likes, &like)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return likes, nil
+}
+
+// addDistances fills in how far each liker is from recipientId. With matchPreferences, likers who don't fit
+// recipientId's preferences are left out.
+func (s *ShardedStorage) addDistances(ctx context.Context, recipientId int64, likes []*storage.Decision, matchPreferences bool) ([]*storage.Decision, error) {
+	if len(likes) == 0 {
+		return likes, nil
+	}
+
+	var query strings.Builder
+	args := []any{recipientId}
+	query.WriteString("SELECT a.id, " + likerDistance + " AS distance_km FROM Users a JOIN Users r ON r.id = ?")
+	if matchPreferences {
+		query.WriteString(" LEFT JOIN Preferences ap ON ap.user_id = a.id LEFT JOIN Preferences rp ON rp.user_id = r.id")
+	}
+	query.WriteString(" WHERE a.id IN (" + sqlutil.Placeholders(len(likes)) + ")")
+	for _, like := range likes {
+		args = append(args, like.ActorID)
+	}
+	if matchPreferences {
+		query.WriteString(" AND " + matchesPreferences("r", "rp", "a", "ap"))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	distances := make(map[int64]*float64)
+	for rows.Next() {
+		var actorId int64
+		var distance sql.NullFloat64
+		if err := rows.Scan(&actorId, &distance); err != nil {
+			return nil, err
+		}
+		distances[actorId] = nullFloatPtr(distance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var kept []*storage.Decision
+	for _, like := range likes {
+		distance, ok := distances[like.ActorID]
+		if !ok && matchPreferences {
+			continue
+		}
+		like.DistanceKm = distance
+		kept = append(kept, like)
+	}
+	return kept, nil
+}
+
+func (s *ShardedStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	actor, actorErr := strconv.ParseInt(actorId, 10, 64)
+	recipient, recipientErr := strconv.ParseInt(recipientId, 10, 64)
+	if actorErr != nil || recipientErr != nil {
+		return false, storage.ErrUserNotFound
+	}
+
+	homes, err := s.homes(ctx, true, actor, recipient)
+	if err != nil {
+		return false, err
+	}
+	actorHome, actorOk := homes[actor]
+	recipientHome, recipientOk := homes[recipient]
+	if !actorOk || !recipientOk {
+		return false, storage.ErrUserNotFound
+	}
+
+	id, err := s.nextDecisionId(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+
+	//The recipient's home shard decides whether this is a duplicate, copies made during a move can already exist
+	for i, shard := range recipientHome.writeShards() {
+		query := `INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`
+		if i > 0 {
+			query = `INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`
+		}
+		_, err := s.shards[shard].ExecContext(ctx, query, id, actor, recipient, liked, now)
+		if i == 0 && isMysqlError(err, errDuplicateEntry) {
+			return false, storage.ErrDuplicateDecision
+		}
+		if err != nil {
+			return false, s.abandonDecision(ctx, err, id, actor, recipient, actorHome, recipientHome)
+		}
+	}
+
+	for _, shard := range actorHome.writeShards() {
+		query := `INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES (?, ?, ?, ?)`
+		if _, err := s.shards[shard].ExecContext(ctx, query, actor, recipient, id, liked); err != nil {
+			return false, s.abandonDecision(ctx, err, id, actor, recipient, actorHome, recipientHome)
+		}
+	}
+
+	if !liked {
+		return false, nil
+	}
+
+	counterQuery := `INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1`
+	if _, err := s.db.ExecContext(ctx, counterQuery, recipient); err != nil {
+		return false, s.abandonDecision(ctx, err, id, actor, recipient, actorHome, recipientHome)
+	}
+
+	//Checked after saving the like, so when two users like each other at once at least one of them sees the match
+	var reciprocal bool
+	matchQuery := `SELECT EXISTS (SELECT 1 FROM Decisions WHERE recipient_id = ? AND actor_id = ? AND liked = TRUE)`
+	if err := s.shards[actorHome.shard].QueryRowContext(ctx, matchQuery, actor, recipient).Scan(&reciprocal); err != nil {
+		//The like is saved so it mustn't be reported as failed, a match still shows up in both users' likes
+		logging.FromContext(ctx).Warn("failed to check for a match", "decision_id", id, "error", err)
+		return false, nil
+	}
+	return reciprocal, nil
+}
+
+// abandonDecision undoes what AddDecision saved before failing with err, and returns err if it could. Otherwise the
+// decision may be partly saved, so it's logged to be repaired and ErrDecisionPartlySaved is returned.
+func (s *ShardedStorage) abandonDecision(ctx context.Context, err error, id int64, actor int64, recipient int64, actorHome home, recipientHome home) error {
+	undoErr := s.undoDecision(ctx, id, actor, actorHome, recipientHome)
+	if undoErr == nil {
+		return err
+	}
+	logging.FromContext(ctx).Error("decision partly saved, run repair-counters and check the shards for it",
+		"decision_id", id, "actor_id", actor, "recipient_id", recipient, "error", err, "undo_error", undoErr)
+	return fmt.Errorf("%w: %w", storage.ErrDecisionPartlySaved, errors.Join(err, undoErr))
+}
+
+// undoDecision removes whatever AddDecision managed to save before failing. The shards can't share a transaction,
+// so this is done even if ctx has been cancelled.
+func (s *ShardedStorage) undoDecision(ctx context.Context, id int64, actor int64, actorHome home, recipientHome home) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, shard := range recipientHome.writeShards() {
+		_, err := s.shards[shard].ExecContext(ctx, `DELETE FROM Decisions WHERE id = ?`, id)
+		errs = append(errs, err)
+	}
+	for _, shard := range actorHome.writeShards() {
+		_, err := s.shards[shard].ExecContext(ctx, `DELETE FROM ActorDecisions WHERE actor_id = ? AND decision_id = ?`, actor, id)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ShardedStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return nil, storage.ErrUserNotFound
+	}
+	homes, err := s.homes(ctx, false, id)
+	if err != nil {
+		return nil, err
+	}
+	h, hasDecided := homes[id]
+
+	//Users who have already been decided on are on the user's shard, so candidates are read from the main database
+	//in batches until a page of them haven't been
+	var page []*storage.Candidate
+	skip := paginationToken
+	for offset := 0; ; offset += s.maxPageSize {
+		batch, err := s.getCandidates(ctx, userId, maxDistanceKm, false, s.maxPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		undecided := batch
+		if hasDecided {
+			undecided, err = s.undecided(ctx, h.shard, id, batch)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, candidate := range undecided {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			page = append(page, candidate)
+			if len(page) == s.maxPageSize {
+				return page, nil
+			}
+		}
+		if len(batch) < s.maxPageSize {
+			return page, nil
+		}
+	}
+}
+
+// undecided returns the candidates actorId hasn't made a decision on
+func (s *ShardedStorage) undecided(ctx context.Context, shard int, actorId int64, candidates []*storage.Candidate) ([]*storage.Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	args := []any{actorId}
+	for _, candidate := range candidates {
+		args = append(args, candidate.UserID)
+	}
+	query := `SELECT recipient_id FROM ActorDecisions WHERE actor_id = ? AND recipient_id IN (` + sqlutil.Placeholders(len(candidates)) + `)`
+
+	rows, err := s.shards[shard].QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decided := make(map[int64]bool)
+	for rows.Next() {
+		var recipientId int64
+		if err := rows.Scan(&recipientId); err != nil {
+			return nil, err
+		}
+		decided[recipientId] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var undecided []*storage.Candidate
+	for _, candidate := range candidates {
+		if !decided[candidate.UserID] {
+			undecided = append(undecided, candidate)
+		}
+	}
+	return undecided, nil
+}
+
+// ListDecisionsAfter merges the decisions from every shard. IDs come from one sequence so they're still in the order
+// decisions were made, although one made on another shard can commit after a later one has been read. created_at is
+// set before a decision is written, so a cursor that waits for decisions to be as old as the longest write, as the
+// score job does with score.CommitLag, never passes one that hasn't committed.
+func (s *ShardedStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	var decisions []*storage.Decision
+	for _, db := range s.shards {
+		query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT %d", limit)
+		rows, err := db.QueryContext(ctx, query, afterId)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var decision storage.Decision
+			if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			decisions = append(decisions, &decision)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].ID < decisions[j].ID })
+
+	//Users being moved have their decisions on two shards
+	var merged []*storage.Decision
+	for _, decision := range decisions {
+		if len(merged) > 0 && merged[len(merged)-1].ID == decision.ID {
+			continue
+		}
+		if len(merged) == limit {
+			break
+		}
+		merged = append(merged, decision)
+	}
+	return merged, nil
+}
+
+func (s *ShardedStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	var boost storage.Boost
+	query := `SELECT starts_at, ends_at FROM Boosts WHERE user_id = ? ORDER BY starts_at DESC LIMIT 1`
+
+	err := s.db.QueryRowContext(ctx, query, userId).Scan(&boost.StartsAt, &boost.EndsAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrBoostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := strconv.ParseInt(userId, 10, 64)
+	homes, err := s.homes(ctx, false, id)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := homes[id]
+	if !ok {
+		return &boost, nil
+	}
+
+	likesQuery := `SELECT COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE AND created_at >= ? AND created_at < ?`
+	if err := s.shards[h.shard].QueryRowContext(ctx, likesQuery, id, boost.StartsAt, boost.EndsAt).Scan(&boost.LikesReceived); err != nil {
+		return nil, err
+	}
+	return &boost, nil
+}
+
+// RepairLikeCounters compares the counters with the likes on each user's home shard. Counters are recounted just
+// before they're repaired, but a like made in between is still lost, so it's best run when few likes are being made.
+func (s *ShardedStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	homes, err := s.allHomes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := make(map[int64]int64)
+	for shard, db := range s.shards {
+		counts, err := queryCounts(ctx, db, `SELECT recipient_id, COUNT(*) FROM Decisions WHERE liked = TRUE GROUP BY recipient_id`)
+		if err != nil {
+			return nil, err
+		}
+		//Users being moved also have their likes on the shard they're moving to
+		for userId, count := range counts {
+			if homes[userId] == shard {
+				actual[userId] = count
+			}
+		}
+	}
+
+	stored, err := queryCounts(ctx, s.db, `SELECT user_id, likes_received FROM LikeCounters`)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []*storage.LikeCounterDrift
+	for userId, count := range stored {
+		if actual[userId] != count {
+			drifts = append(drifts, &storage.LikeCounterDrift{UserID: userId, Stored: count, Actual: actual[userId]})
+		}
+	}
+	for userId, count := range actual {
+		if _, ok := stored[userId]; !ok {
+			drifts = append(drifts, &storage.LikeCounterDrift{UserID: userId, Stored: 0, Actual: count})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].UserID < drifts[j].UserID })
+
+	for _, drift := range drifts {
+		if shard, ok := homes[drift.UserID]; ok {
+			countQuery := `SELECT COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE`
+			if err := s.shards[shard].QueryRowContext(ctx, countQuery, drift.UserID).Scan(&drift.Actual); err != nil {
+				return nil, err
+			}
+		}
+		repairQuery := `INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, ?) ON DUPLICATE KEY UPDATE likes_received = VALUES(likes_received)`
+		if _, err := s.db.ExecContext(ctx, repairQuery, drift.UserID, drift.Actual); err != nil {
+			return nil, err
+		}
+	}
+
+	return drifts, nil
+}
+
+// allHomes returns the home shard of every user who has one
+func (s *ShardedStorage) allHomes(ctx context.Context) (map[int64]int, error) {
+	counts, err := queryCounts(ctx, s.db, `SELECT user_id, shard FROM UserShards`)
+	if err != nil {
+		return nil, err
+	}
+	homes := make(map[int64]int)
+	for userId, shard := range counts {
+		homes[userId] = int(shard)
+	}
+	return homes, nil
+}
+
+// queryCounts runs a query returning pairs of user IDs and numbers
+func queryCounts(ctx context.Context, db *DB, query string) (map[int64]int64, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		var userId, count int64
+		if err := rows.Scan(&userId, &count); err != nil {
+			return nil, err
+		}
+		counts[userId] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// UsersToMove returns up to limit users whose home is shard from, to be moved to shard to. Users an interrupted move
+// was already moving there come first, and users moving anywhere else are left out.
+func (s *ShardedStorage) UsersToMove(ctx context.Context, from int, to int, limit int) ([]int64, error) {
+	query := fmt.Sprintf("SELECT user_id FROM UserShards WHERE shard = ? AND (moving_to IS NULL OR moving_to = ?) ORDER BY moving_to IS NULL, user_id LIMIT %d", limit)
+	rows, err := s.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIds []int64
+	for rows.Next() {
+		var userId int64
+		if err := rows.Scan(&userId); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, userId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIds, nil
+}
+
+// MoveUsers moves userIds to shard to while they keep using the service, and returns the users that were moved.
+// Their writes go to both shards while their decisions are copied, then their home changes and the old copies are
+// deleted. grace should be longer than any request takes, so requests that looked up a user's home before it
+// changed finish first. An interrupted move is finished by moving the users again.
+func (s *ShardedStorage) MoveUsers(ctx context.Context, userIds []int64, to int, grace time.Duration) ([]int64, error) {
+	if _, ok := s.shards[to]; !ok {
+		return nil, ErrUnknownShard
+	}
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+
+	homes, err := s.homes(ctx, true, userIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	moving := make(map[int64]home)
+	for _, userId := range userIds {
+		h, ok := homes[userId]
+		if !ok {
+			return nil, storage.ErrUserNotFound
+		}
+		if h.shard == to {
+			continue
+		}
+		if h.movingTo.Valid && int(h.movingTo.Int64) != to {
+			return nil, ErrMoveInProgress
+		}
+		if !h.movingTo.Valid {
+			query := `UPDATE UserShards SET moving_to = ? WHERE user_id = ? AND shard = ? AND moving_to IS NULL`
+			if _, err := s.db.ExecContext(ctx, query, to, userId, h.shard); err != nil {
+				return nil, err
+			}
+		}
+		moving[userId] = h
+	}
+	if len(moving) == 0 {
+		return nil, nil
+	}
+
+	if err := sleep(ctx, grace); err != nil {
+		return nil, err
+	}
+
+	var moved []int64
+	for userId, h := range moving {
+		if err := s.copyUser(ctx, userId, s.shards[h.shard], s.shards[to]); err != nil {
+			return nil, err
+		}
+		query := `UPDATE UserShards SET shard = ?, moving_to = NULL WHERE user_id = ?`
+		if _, err := s.db.ExecContext(ctx, query, to, userId); err != nil {
+			return nil, err
+		}
+		moved = append(moved, userId)
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i] < moved[j] })
+
+	if err := sleep(ctx, grace); err != nil {
+		return moved, err
+	}
+
+	for userId, h := range moving {
+		if err := deleteUser(ctx, userId, s.shards[h.shard]); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// Backfill copies the decisions made before sharding, which stay in the main database's Decisions, onto the shards
+// and returns how many it copied. The decision sequence is first moved past them so new decisions can't take their
+// IDs. Pairs of users who've already made a decision on a shard keep it, so an interrupted backfill is finished by
+// running it again. It shouldn't be run while users are being moved.
+func (s *ShardedStorage) Backfill(ctx context.Context) (int, error) {
+	var maxId int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM Decisions`).Scan(&maxId); err != nil {
+		return 0, err
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE DecisionSequence SET id = GREATEST(id, ?)`, maxId); err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	for afterId := int64(0); ; {
+		query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT %d", moveBatchSize)
+		rows, err := s.db.QueryContext(ctx, query, afterId)
+		if err != nil {
+			return copied, err
+		}
+
+		var decisions []*storage.Decision
+		for rows.Next() {
+			var d storage.Decision
+			if err := rows.Scan(&d.ID, &d.ActorID, &d.RecipientID, &d.Liked, &d.CreatedAt); err != nil {
+				rows.Close()
+				return copied, err
+			}
+			decisions = append(decisions, &d)
+			afterId = d.ID
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return copied, err
+		}
+
+		batchCopied, err := s.backfillBatch(ctx, decisions)
+		copied += batchCopied
+		if err != nil {
+			return copied, err
+		}
+		if len(decisions) < moveBatchSize {
+			return copied, nil
+		}
+	}
+}
+
+// backfillBatch copies decisions to the recipient's home shard, and to the actor's as their ActorDecisions, and
+// returns how many it copied
+func (s *ShardedStorage) backfillBatch(ctx context.Context, decisions []*storage.Decision) (int, error) {
+	if len(decisions) == 0 {
+		return 0, nil
+	}
+
+	seen := make(map[int64]bool)
+	var userIds []int64
+	for _, d := range decisions {
+		for _, userId := range []int64{d.ActorID, d.RecipientID} {
+			if !seen[userId] {
+				seen[userId] = true
+				userIds = append(userIds, userId)
+			}
+		}
+	}
+	homes, err := s.homes(ctx, true, userIds...)
+	if err != nil {
+		return 0, err
+	}
+
+	decisionValues := make(map[int][]any)
+	actorValues := make(map[int][]any)
+	copied := 0
+	for _, d := range decisions {
+		//Decisions on users who have since been deleted are deleted with them
+		actorHome, actorOk := homes[d.ActorID]
+		recipientHome, recipientOk := homes[d.RecipientID]
+		if !actorOk || !recipientOk {
+			continue
+		}
+		copied++
+		for _, shard := range recipientHome.writeShards() {
+			decisionValues[shard] = append(decisionValues[shard], d.ID, d.ActorID, d.RecipientID, d.Liked, d.CreatedAt)
+		}
+		for _, shard := range actorHome.writeShards() {
+			actorValues[shard] = append(actorValues[shard], d.ActorID, d.RecipientID, d.ID, d.Liked)
+		}
+	}
+
+	for _, shard := range slices.Sorted(maps.Keys(decisionValues)) {
+		values := decisionValues[shard]
+		insert := `INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES ` + sqlutil.ValuesList(len(values)/5, 5)
+		if _, err := s.shards[shard].ExecContext(ctx, insert, values...); err != nil {
+			return 0, err
+		}
+	}
+	for _, shard := range slices.Sorted(maps.Keys(actorValues)) {
+		values := actorValues[shard]
+		insert := `INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES ` + sqlutil.ValuesList(len(values)/4, 4)
+		if _, err := s.shards[shard].ExecContext(ctx, insert, values...); err != nil {
+			return 0, err
+		}
+	}
+	return copied, nil
+}
+
+// copyUser copies userId's decisions from one shard to another, skipping any already there
+func (s *ShardedStorage) copyUser(ctx context.Context, userId int64, from *DB, to *DB) error {
+	for afterId := int64(0); ; {
+		query := fmt.Sprintf("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE recipient_id = ? AND id > ? ORDER BY id LIMIT %d", moveBatchSize)
+		rows, err := from.QueryContext(ctx, query, userId, afterId)
+		if err != nil {
+			return err
+		}
+
+		var values []any
+		for rows.Next() {
+			var d storage.Decision
+			if err := rows.Scan(&d.ID, &d.ActorID, &d.RecipientID, &d.Liked, &d.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			values = append(values, d.ID, d.ActorID, d.RecipientID, d.Liked, d.CreatedAt)
+			afterId = d.ID
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		copied := len(values) / 5
+		if copied > 0 {
+			insert := `INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES ` + sqlutil.ValuesList(copied, 5)
+			if _, err := to.ExecContext(ctx, insert, values...); err != nil {
+				return err
+			}
+		}
+		if copied < moveBatchSize {
+			break
+		}
+	}
+
+	for afterId := int64(0); ; {
+		query := fmt.Sprintf("SELECT recipient_id, decision_id, liked FROM ActorDecisions WHERE actor_id = ? AND recipient_id > ? ORDER BY recipient_id LIMIT %d", moveBatchSize)
+		rows, err := from.QueryContext(ctx, query, userId, afterId)
+		if err != nil {
+			return err
+		}
+
+		var values []any
+		for rows.Next() {
+			var recipientId, decisionId int64
+			var liked bool
+			if err := rows.Scan(&recipientId, &decisionId, &liked); err != nil {
+				rows.Close()
+				return err
+			}
+			values = append(values, userId, recipientId, decisionId, liked)
+			afterId = recipientId
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		copied := len(values) / 4
+		if copied > 0 {
+			insert := `INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES ` + sqlutil.ValuesList(copied, 4)
+			if _, err := to.ExecContext(ctx, insert, values...); err != nil {
+				return err
+			}
+		}
+		if copied < moveBatchSize {
+			return nil
+		}
+	}
+}
+
+// deleteUser deletes userId's decisions from a shard they've moved off, in batches to keep locks short
+func deleteUser(ctx context.Context, userId int64, db *DB) error {
+	for _, query := range []string{
+		fmt.Sprintf("DELETE FROM Decisions WHERE recipient_id = ? LIMIT %d", moveBatchSize),
+		fmt.Sprintf("DELETE FROM ActorDecisions WHERE actor_id = ? LIMIT %d", moveBatchSize),
+	} {
+		for {
+			result, err := db.ExecContext(ctx, query, userId)
+			if err != nil {
+				return err
+			}
+			deleted, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if deleted < moveBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
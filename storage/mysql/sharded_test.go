@@ -0,0 +1,401 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"muzz-project/score"
+	"muzz-project/storage"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+// newShardedMocks returns a ShardedStorage with a main database and two shards, placing new users on both
+func newShardedMocks(t *testing.T, maxPageSize int) (*ShardedStorage, sqlmock.Sqlmock, []sqlmock.Sqlmock) {
+	mainDB, mainMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { mainDB.Close() })
+
+	shards := make(map[int]*sql.DB)
+	var shardMocks []sqlmock.Sqlmock
+	for i := 0; i < 2; i++ {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		shards[i] = db
+		shardMocks = append(shardMocks, mock)
+	}
+
+	return NewShardedStorage(mainDB, shards, []int{0, 1}, maxPageSize), mainMock, shardMocks
+}
+
+func expectHomes(mock sqlmock.Sqlmock, homes ...[]sqldriver.Value) {
+	rows := sqlmock.NewRows([]string{"user_id", "shard", "moving_to"})
+	for _, h := range homes {
+		rows.AddRow(h...)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, shard, moving_to FROM UserShards WHERE user_id IN (`)).WillReturnRows(rows)
+}
+
+func TestShardedStorage_AddDecision(t *testing.T) {
+	ctx := context.Background()
+
+	insertDecision := regexp.QuoteMeta(`INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`)
+	copyDecision := regexp.QuoteMeta(`INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`)
+	insertActorDecision := regexp.QuoteMeta(`INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES (?, ?, ?, ?)`)
+	nextId := regexp.QuoteMeta(`UPDATE DecisionSequence SET id = LAST_INSERT_ID(id + 1)`)
+
+	tests := map[string]struct {
+		dbOutcomes func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock)
+		liked      bool
+		want       bool
+		wantErr    error
+	}{
+		"like on another shard makes a match": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(7, 1))
+				shards[1].ExpectExec(insertDecision).WithArgs(7, 1, 2, true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(insertActorDecision).WithArgs(1, 2, 7, true).WillReturnResult(sqlmock.NewResult(0, 1))
+				main.ExpectExec(regexp.QuoteMeta(`INSERT INTO LikeCounters`)).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM Decisions WHERE recipient_id = ? AND actor_id = ? AND liked = TRUE)`)).
+					WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			liked:   true,
+			want:    true,
+			wantErr: nil,
+		},
+		"new users are placed on a shard": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main)
+				main.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO UserShards (user_id, shard) VALUES (?, ?)`)).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				main.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO UserShards (user_id, shard) VALUES (?, ?)`)).WithArgs(2, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectHomes(main, []sqldriver.Value{1, 1, nil}, []sqldriver.Value{2, 0, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(8, 1))
+				shards[0].ExpectExec(insertDecision).WithArgs(8, 1, 2, false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[1].ExpectExec(insertActorDecision).WithArgs(1, 2, 8, false).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			liked:   false,
+			want:    false,
+			wantErr: nil,
+		},
+		"recipient being moved": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, 0})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(9, 1))
+				shards[1].ExpectExec(insertDecision).WithArgs(9, 1, 2, false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(copyDecision).WithArgs(9, 1, 2, false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(insertActorDecision).WithArgs(1, 2, 9, false).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			liked:   false,
+			want:    false,
+			wantErr: nil,
+		},
+		"decision already made": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(10, 1))
+				shards[1].ExpectExec(insertDecision).
+					WillReturnError(&driver.MySQLError{Number: 1062, Message: "Duplicate entry '2-1' for key 'Decisions.unique_Decisions'"})
+			},
+			liked:   true,
+			want:    false,
+			wantErr: storage.ErrDuplicateDecision,
+		},
+		"unknown user": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil})
+				main.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO UserShards (user_id, shard) VALUES (?, ?)`)).WithArgs(2, 0).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectHomes(main, []sqldriver.Value{1, 0, nil})
+			},
+			liked:   true,
+			want:    false,
+			wantErr: storage.ErrUserNotFound,
+		},
+		"actor's copy fails": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(11, 1))
+				shards[1].ExpectExec(insertDecision).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(insertActorDecision).WillReturnError(sql.ErrConnDone)
+				shards[1].ExpectExec(regexp.QuoteMeta(`DELETE FROM Decisions WHERE id = ?`)).WithArgs(11).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(regexp.QuoteMeta(`DELETE FROM ActorDecisions WHERE actor_id = ? AND decision_id = ?`)).WithArgs(1, 11).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			liked:   true,
+			want:    false,
+			wantErr: sql.ErrConnDone,
+		},
+		"undo fails": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(12, 1))
+				shards[1].ExpectExec(insertDecision).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(insertActorDecision).WillReturnResult(sqlmock.NewResult(0, 1))
+				main.ExpectExec(regexp.QuoteMeta(`INSERT INTO LikeCounters`)).WillReturnError(sql.ErrConnDone)
+				shards[1].ExpectExec(regexp.QuoteMeta(`DELETE FROM Decisions WHERE id = ?`)).WithArgs(12).WillReturnError(sql.ErrConnDone)
+				shards[0].ExpectExec(regexp.QuoteMeta(`DELETE FROM ActorDecisions WHERE actor_id = ? AND decision_id = ?`)).WithArgs(1, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			liked:   true,
+			want:    false,
+			wantErr: storage.ErrDecisionPartlySaved,
+		},
+		"match check fails": {
+			dbOutcomes: func(main sqlmock.Sqlmock, shards []sqlmock.Sqlmock) {
+				expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+				main.ExpectExec(nextId).WillReturnResult(sqlmock.NewResult(13, 1))
+				shards[1].ExpectExec(insertDecision).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectExec(insertActorDecision).WillReturnResult(sqlmock.NewResult(0, 1))
+				main.ExpectExec(regexp.QuoteMeta(`INSERT INTO LikeCounters`)).WillReturnResult(sqlmock.NewResult(0, 1))
+				shards[0].ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).WillReturnError(sql.ErrConnDone)
+			},
+			liked:   true,
+			want:    false,
+			wantErr: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, main, shards := newShardedMocks(t, 10)
+			tt.dbOutcomes(main, shards)
+
+			got, err := s.AddDecision(ctx, "1", "2", tt.liked)
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.NoError(t, main.ExpectationsWereMet())
+			for _, shard := range shards {
+				assert.NoError(t, shard.ExpectationsWereMet())
+			}
+		})
+	}
+}
+
+func TestShardedStorage_GetCandidatesForUser(t *testing.T) {
+	s, main, shards := newShardedMocks(t, 2)
+
+	//The first batch has a user who's been decided on, so a second is needed to fill the page
+	expectHomes(main, []sqldriver.Value{1, 1, nil})
+	for _, batch := range [][]int64{{3, 4}, {5}} {
+		main.ExpectQuery(regexp.QuoteMeta(`SELECT latitude, longitude FROM Users WHERE id = ?`)).WillReturnRows(sqlmock.NewRows([]string{"latitude", "longitude"}).AddRow(nil, nil))
+		rows := sqlmock.NewRows([]string{"id", "distance_km"})
+		for _, id := range batch {
+			rows.AddRow(id, nil)
+		}
+		main.ExpectQuery(`SELECT u.id, .* WHERE u.id <> me.id AND \(COALESCE`).WillReturnRows(rows)
+
+		decided := sqlmock.NewRows([]string{"recipient_id"})
+		if batch[0] == 3 {
+			decided.AddRow(3)
+		}
+		shards[1].ExpectQuery(regexp.QuoteMeta(`SELECT recipient_id FROM ActorDecisions WHERE actor_id = ? AND recipient_id IN (`)).WillReturnRows(decided)
+	}
+
+	candidates, err := s.GetCandidatesForUser(context.Background(), "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*storage.Candidate{{UserID: 4}, {UserID: 5}}, candidates)
+	assert.NoError(t, main.ExpectationsWereMet())
+	assert.NoError(t, shards[1].ExpectationsWereMet())
+}
+
+func TestShardedStorage_ListDecisionsAfter(t *testing.T) {
+	s, _, shards := newShardedMocks(t, 10)
+	arbitraryTime := time.Now()
+
+	columns := []string{"id", "actor_id", "recipient_id", "liked", "created_at"}
+	shards[0].ExpectQuery(regexp.QuoteMeta(`SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 3`)).WithArgs(0).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, 1, true, arbitraryTime).AddRow(4, 1, 4, true, arbitraryTime))
+	//Decision 4 is on both shards as user 4 is being moved
+	shards[1].ExpectQuery(regexp.QuoteMeta(`SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 3`)).WithArgs(0).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 1, 3, false, arbitraryTime).AddRow(4, 1, 4, true, arbitraryTime).AddRow(5, 3, 5, true, arbitraryTime))
+
+	decisions, err := s.ListDecisionsAfter(context.Background(), 0, 3)
+	assert.NoError(t, err)
+	var ids []int64
+	for _, d := range decisions {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 4}, ids)
+}
+
+func TestShardedStorage_ListDecisionsAfter_OutOfOrderCommits(t *testing.T) {
+	ctx := context.Background()
+	s, main, shards := newShardedMocks(t, 10)
+	job := score.NewJob(s, 10)
+
+	columns := []string{"id", "actor_id", "recipient_id", "liked", "created_at"}
+	listQuery := regexp.QuoteMeta(`SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 10`)
+	cursorQuery := regexp.QuoteMeta(`SELECT last_id FROM JobCursors WHERE name = ?`)
+
+	//Decision 2 has committed on shard 1 while decision 1 is still being written to shard 0, so the cursor mustn't pass it
+	main.ExpectQuery(cursorQuery).WithArgs("desirability").WillReturnRows(sqlmock.NewRows([]string{"last_id"}))
+	shards[0].ExpectQuery(listQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(columns))
+	shards[1].ExpectQuery(listQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 3, 2, true, time.Now()))
+
+	processed, err := job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, processed)
+
+	//Once both are older than the commit lag they're scored together
+	settled := time.Now().Add(-2 * score.CommitLag)
+	main.ExpectQuery(cursorQuery).WithArgs("desirability").WillReturnRows(sqlmock.NewRows([]string{"last_id"}))
+	shards[0].ExpectQuery(listQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 1, 2, true, settled))
+	shards[1].ExpectQuery(listQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 3, 2, true, settled))
+	main.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, score, likes_given, decisions_given, updated_at FROM DesirabilityScores WHERE user_id IN (`)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "score", "likes_given", "decisions_given", "updated_at"}))
+	main.ExpectBegin()
+	main.ExpectExec(regexp.QuoteMeta(`INSERT INTO DesirabilityScores`)).WillReturnResult(sqlmock.NewResult(0, 3))
+	main.ExpectExec(regexp.QuoteMeta(`INSERT INTO JobCursors`)).WithArgs("desirability", int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	main.ExpectCommit()
+
+	processed, err = job.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.NoError(t, main.ExpectationsWereMet())
+	assert.NoError(t, shards[0].ExpectationsWereMet())
+	assert.NoError(t, shards[1].ExpectationsWereMet())
+}
+
+func TestShardedStorage_MoveUsers(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+
+	t.Run("moves decisions then deletes the originals", func(t *testing.T) {
+		s, main, shards := newShardedMocks(t, 10)
+
+		expectHomes(main, []sqldriver.Value{5, 0, nil})
+		main.ExpectExec(regexp.QuoteMeta(`UPDATE UserShards SET moving_to = ? WHERE user_id = ? AND shard = ? AND moving_to IS NULL`)).WithArgs(1, 5, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+		shards[0].ExpectQuery(regexp.QuoteMeta(`SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE recipient_id = ? AND id > ?`)).WithArgs(5, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at"}).AddRow(3, 2, 5, true, arbitraryTime))
+		shards[1].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`)).
+			WithArgs(3, 2, 5, true, arbitraryTime).WillReturnResult(sqlmock.NewResult(0, 1))
+		shards[0].ExpectQuery(regexp.QuoteMeta(`SELECT recipient_id, decision_id, liked FROM ActorDecisions WHERE actor_id = ? AND recipient_id > ?`)).WithArgs(5, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"recipient_id", "decision_id", "liked"}).AddRow(2, 4, false).AddRow(6, 9, true))
+		shards[1].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES (?, ?, ?, ?), (?, ?, ?, ?)`)).
+			WithArgs(5, 2, 4, false, 5, 6, 9, true).WillReturnResult(sqlmock.NewResult(0, 2))
+		main.ExpectExec(regexp.QuoteMeta(`UPDATE UserShards SET shard = ?, moving_to = NULL WHERE user_id = ?`)).WithArgs(1, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+		shards[0].ExpectExec(regexp.QuoteMeta(`DELETE FROM Decisions WHERE recipient_id = ? LIMIT 1000`)).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		shards[0].ExpectExec(regexp.QuoteMeta(`DELETE FROM ActorDecisions WHERE actor_id = ? LIMIT 1000`)).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 2))
+
+		moved, err := s.MoveUsers(ctx, []int64{5}, 1, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{5}, moved)
+		assert.NoError(t, main.ExpectationsWereMet())
+		for _, shard := range shards {
+			assert.NoError(t, shard.ExpectationsWereMet())
+		}
+	})
+
+	t.Run("user already there", func(t *testing.T) {
+		s, main, _ := newShardedMocks(t, 10)
+		expectHomes(main, []sqldriver.Value{5, 1, nil})
+
+		moved, err := s.MoveUsers(ctx, []int64{5}, 1, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, moved)
+	})
+
+	t.Run("user moving elsewhere", func(t *testing.T) {
+		s, main, _ := newShardedMocks(t, 10)
+		expectHomes(main, []sqldriver.Value{5, 0, 2})
+
+		_, err := s.MoveUsers(ctx, []int64{5}, 1, 0)
+		assert.Equal(t, ErrMoveInProgress, err)
+	})
+
+	t.Run("unknown shard", func(t *testing.T) {
+		s, _, _ := newShardedMocks(t, 10)
+
+		_, err := s.MoveUsers(ctx, []int64{5}, 7, 0)
+		assert.Equal(t, ErrUnknownShard, err)
+	})
+
+	t.Run("waits for requests using the old home", func(t *testing.T) {
+		s, main, _ := newShardedMocks(t, 10)
+		expectHomes(main, []sqldriver.Value{5, 0, nil})
+		main.ExpectExec(regexp.QuoteMeta(`UPDATE UserShards SET moving_to = ?`)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		_, err := s.MoveUsers(ctx, []int64{5}, 1, time.Minute)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestShardedStorage_Backfill(t *testing.T) {
+	ctx := context.Background()
+	arbitraryTime := time.Now()
+	s, main, shards := newShardedMocks(t, 10)
+
+	main.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(id), 0) FROM Decisions`)).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+	main.ExpectExec(regexp.QuoteMeta(`UPDATE DecisionSequence SET id = GREATEST(id, ?)`)).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	main.ExpectQuery(regexp.QuoteMeta(`SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 1000`)).WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at"}).
+			AddRow(1, 1, 2, true, arbitraryTime).AddRow(2, 2, 1, false, arbitraryTime).AddRow(3, 1, 3, true, arbitraryTime))
+	//User 3 has been deleted since
+	expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+	main.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO UserShards (user_id, shard) VALUES (?, ?)`)).WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectHomes(main, []sqldriver.Value{1, 0, nil}, []sqldriver.Value{2, 1, nil})
+	shards[0].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`)).
+		WithArgs(2, 2, 1, false, arbitraryTime).WillReturnResult(sqlmock.NewResult(0, 1))
+	shards[1].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)`)).
+		WithArgs(1, 1, 2, true, arbitraryTime).WillReturnResult(sqlmock.NewResult(0, 1))
+	shards[0].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES (?, ?, ?, ?)`)).
+		WithArgs(1, 2, 1, true).WillReturnResult(sqlmock.NewResult(0, 1))
+	shards[1].ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO ActorDecisions (actor_id, recipient_id, decision_id, liked) VALUES (?, ?, ?, ?)`)).
+		WithArgs(2, 1, 2, false).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	copied, err := s.Backfill(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, copied)
+	assert.NoError(t, main.ExpectationsWereMet())
+	for _, shard := range shards {
+		assert.NoError(t, shard.ExpectationsWereMet())
+	}
+}
+
+func TestShardMap_validate(t *testing.T) {
+	tests := map[string]struct {
+		shardMap     ShardMap
+		wantNewUsers []int
+		wantErr      bool
+	}{
+		"new users default to every shard": {
+			shardMap:     ShardMap{Shards: []Shard{{ID: 0, DSN: "a"}, {ID: 3, DSN: "b"}}},
+			wantNewUsers: []int{0, 3},
+		},
+		"new users on some shards": {
+			shardMap:     ShardMap{Shards: []Shard{{ID: 0, DSN: "a"}, {ID: 1, DSN: "b"}}, NewUsers: []int{1}},
+			wantNewUsers: []int{1},
+		},
+		"no shards": {
+			shardMap: ShardMap{},
+			wantErr:  true,
+		},
+		"repeated shard": {
+			shardMap: ShardMap{Shards: []Shard{{ID: 0, DSN: "a"}, {ID: 0, DSN: "b"}}},
+			wantErr:  true,
+		},
+		"new users on an unknown shard": {
+			shardMap: ShardMap{Shards: []Shard{{ID: 0, DSN: "a"}}, NewUsers: []int{1}},
+			wantErr:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.shardMap.validate()
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantNewUsers, tt.shardMap.NewUsers)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ShardMap lists the databases Decisions are sharded over. Users keep the shard they're first given, so shards can
+// be added without moving anyone, and NewUsers controls where users are placed from then on.
+type ShardMap struct {
+	Shards []Shard `json:"shards"`
+	// NewUsers are the IDs of the shards users without one are placed on, by user ID. Defaults to every shard.
+	NewUsers []int `json:"newUsers"`
+}
+
+type Shard struct {
+	// ID identifies the shard in UserShards so it must never change, unlike DSN
+	ID  int    `json:"id"`
+	DSN string `json:"dsn"`
+}
+
+// LoadShardMap reads a JSON shard map from path
+func LoadShardMap(path string) (*ShardMap, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var shardMap ShardMap
+	if err := json.Unmarshal(contents, &shardMap); err != nil {
+		return nil, fmt.Errorf("shard map %s: %w", path, err)
+	}
+	if err := shardMap.validate(); err != nil {
+		return nil, fmt.Errorf("shard map %s: %w", path, err)
+	}
+	return &shardMap, nil
+}
+
+func (s *ShardMap) validate() error {
+	if len(s.Shards) == 0 {
+		return fmt.Errorf("no shards")
+	}
+
+	ids := make(map[int]bool)
+	for _, shard := range s.Shards {
+		if ids[shard.ID] {
+			return fmt.Errorf("shard %d is listed twice", shard.ID)
+		}
+		if shard.DSN == "" {
+			return fmt.Errorf("shard %d has no dsn", shard.ID)
+		}
+		ids[shard.ID] = true
+	}
+
+	if len(s.NewUsers) == 0 {
+		for _, shard := range s.Shards {
+			s.NewUsers = append(s.NewUsers, shard.ID)
+		}
+	}
+	for _, id := range s.NewUsers {
+		if !ids[id] {
+			return fmt.Errorf("new users are placed on shard %d, which isn't listed", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentation names the tracer statement spans are started with
+const instrumentation = "muzz-project/storage/mysql"
+
+// WithTracerProvider traces every SQL statement and transaction with a tracer from tp. Nothing is traced by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(m *MysqlStorage) {
+		m.tracer = tp.Tracer(instrumentation)
+	}
+}
+
+var noopTracer = noop.NewTracerProvider().Tracer(instrumentation)
+
+// startStatement starts a span for running query, named for the statement and table as OpenTelemetry names database
+// spans, e.g. SELECT Decisions. The query is recorded without its arguments, which may identify users.
+func startStatement(ctx context.Context, tracer trace.Tracer, query string) (context.Context, trace.Span) {
+	operation, table := statementName(query)
+	name := operation
+	attrs := []attribute.KeyValue{semconv.DBSystemMySQL, semconv.DBOperationName(operation), semconv.DBQueryText(query)}
+	if table != "" {
+		name += " " + table
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it failed if err isn't nil. sql.ErrNoRows isn't a failure, only an empty result.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// statementName returns the statement query starts with and the table it's run against, the table after the first
+// FROM, INTO or UPDATE outside of any brackets. The table is empty for statements that don't read one.
+func statementName(query string) (string, string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	operation := strings.ToUpper(fields[0])
+	if operation == "UPDATE" && len(fields) > 1 {
+		return operation, tableName(fields[1])
+	}
+
+	depth := 0
+	for i, field := range fields[:len(fields)-1] {
+		if depth == 0 && (strings.EqualFold(field, "FROM") || strings.EqualFold(field, "INTO")) {
+			return operation, tableName(fields[i+1])
+		}
+		depth += strings.Count(field, "(") - strings.Count(field, ")")
+	}
+	return operation, ""
+}
+
+// tableName returns the table name a field starts with, without quotes or the column list that can follow it
+func tableName(field string) string {
+	field, _, _ = strings.Cut(field, "(")
+	return strings.Trim(field, "`,;")
+}
+
+// Tx is a transaction tracing each statement run on it as a child of span, the transaction's, whichever
+// context the statement is run with
+type Tx struct {
+	*sql.Tx
+	tracer trace.Tracer
+	span   trace.Span
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(trace.ContextWithSpan(ctx, t.span), t.tracer, query)
+	rows, err := t.Tx.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+// QueryRowContext is like sql.Tx's. The query runs before it returns, so the span covers it, but errors scanning
+// the row aren't recorded.
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(trace.ContextWithSpan(ctx, t.span), t.tracer, query)
+	row := t.Tx.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(trace.ContextWithSpan(ctx, t.span), t.tracer, query)
+	result, err := t.Tx.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
@@ -0,0 +1,173 @@
+package mysql
+
+import (
+	"context"
+	"muzz-project/storage"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := map[string]struct {
+		query         string
+		wantOperation string
+		wantTable     string
+	}{
+		"select":                  {query: "SELECT likes_received FROM LikeCounters WHERE user_id = ?", wantOperation: "SELECT", wantTable: "LikeCounters"},
+		"subquery in the columns": {query: "SELECT b.starts_at, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = b.user_id) FROM Boosts b", wantOperation: "SELECT", wantTable: "Boosts"},
+		"function in the columns": {query: "SELECT d.id, " + likerDistance + " AS distance_km FROM Decisions d JOIN Users a ON a.id = d.actor_id", wantOperation: "SELECT", wantTable: "Decisions"},
+		"no table":                {query: "SELECT @@GLOBAL.gtid_executed", wantOperation: "SELECT"},
+		"insert with columns":     {query: "INSERT INTO LikeCounters(user_id, likes_received) VALUES (?, 1)", wantOperation: "INSERT", wantTable: "LikeCounters"},
+		"insert ignore":           {query: "INSERT IGNORE INTO `UserShards` (user_id, shard) VALUES (?, ?)", wantOperation: "INSERT", wantTable: "UserShards"},
+		"update":                  {query: "UPDATE Users SET latitude = ? WHERE id = ?", wantOperation: "UPDATE", wantTable: "Users"},
+		"delete":                  {query: "\n\tdelete from Recommendations where user_id = ?", wantOperation: "DELETE", wantTable: "Recommendations"},
+		"empty":                   {},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			operation, table := statementName(tt.query)
+			assert.Equal(t, tt.wantOperation, operation)
+			assert.Equal(t, tt.wantTable, table)
+		})
+	}
+}
+
+// span is the part of a span the tracing tests check, with its children
+type span struct {
+	name     string
+	failed   bool
+	events   []string
+	children []span
+}
+
+// spanTree returns the spans recorded by exporter under the span named root
+func spanTree(exporter *tracetest.InMemoryExporter, root string) span {
+	stubs := exporter.GetSpans()
+	var build func(stub tracetest.SpanStub) span
+	build = func(stub tracetest.SpanStub) span {
+		s := span{name: stub.Name, failed: stub.Status.Code == codes.Error}
+		for _, event := range stub.Events {
+			s.events = append(s.events, event.Name)
+		}
+		for _, child := range stubs {
+			if child.Parent.SpanID() == stub.SpanContext.SpanID() {
+				s.children = append(s.children, build(child))
+			}
+		}
+		return s
+	}
+	for _, stub := range stubs {
+		if stub.Name == root {
+			return build(stub)
+		}
+	}
+	return span{}
+}
+
+func TestMysqlStorage_Tracing(t *testing.T) {
+	matchQuery := `SELECT COUNT\(\*\) FROM Decisions WHERE recipient_id = \? AND actor_id = \? AND liked = TRUE`
+	insertQuery := regexp.QuoteMeta("INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)")
+	counterQuery := regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON DUPLICATE KEY UPDATE likes_received = likes_received + 1")
+
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		call       func(ctx context.Context, m *MysqlStorage) error
+		attempts   int
+		want       span
+	}{
+		"statement": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT d\.id, .* NOT EXISTS`).WithArgs("1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at", "distance_km"}))
+			},
+			call: func(ctx context.Context, m *MysqlStorage) error {
+				_, err := m.GetNewLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+				return err
+			},
+			attempts: 1,
+			want:     span{name: "rpc", children: []span{{name: "SELECT Decisions"}}},
+		},
+		"transaction retried after a deadlock": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnError(deadlockErr)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(matchQuery).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			call: func(ctx context.Context, m *MysqlStorage) error {
+				_, err := m.AddDecision(ctx, "1", "2", true)
+				return err
+			},
+			attempts: 2,
+			want: span{name: "rpc", children: []span{{name: "TRANSACTION", events: []string{"retry"}, children: []span{
+				{name: "SELECT Decisions"},
+				{name: "INSERT Decisions", failed: true, events: []string{"exception"}},
+				{name: "SELECT Decisions"},
+				{name: "INSERT Decisions"},
+				{name: "INSERT LikeCounters"},
+			}}}},
+		},
+		"failed statement": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?")).WillReturnError(deadlockErr)
+			},
+			call: func(ctx context.Context, m *MysqlStorage) error {
+				return m.UpdateLocation(ctx, "1", 51.5, -0.1)
+			},
+			attempts: 1,
+			want:     span{name: "rpc", children: []span{{name: "UPDATE Users", failed: true, events: []string{"exception"}}}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+			tt.dbOutcomes(mock)
+
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			m := NewMysqlStorage(mockDB, 10, WithRetrier(NewRetrier(RetryPolicy{MaxAttempts: tt.attempts})), WithTracerProvider(tp))
+
+			ctx, root := tp.Tracer("test").Start(context.Background(), "rpc")
+			_ = tt.call(ctx, m)
+			root.End()
+
+			assert.Equal(t, tt.want, spanTree(exporter, "rpc"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStartStatement(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	query := "SELECT last_id FROM JobCursors WHERE name = ?"
+
+	_, s := startStatement(context.Background(), tp.Tracer("test"), query)
+	s.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "SELECT JobCursors", spans[0].Name)
+	assert.ElementsMatch(t, spans[0].Attributes, []any{
+		semconv.DBSystemMySQL,
+		semconv.DBOperationName("SELECT"),
+		semconv.DBCollectionName("JobCursors"),
+		semconv.DBQueryText(query),
+	})
+}
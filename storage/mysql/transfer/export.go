@@ -0,0 +1,114 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"muzz-project/storage/mysql"
+	"time"
+)
+
+// Counts is how many records of each table were transferred
+type Counts struct {
+	Users     int
+	Decisions int
+}
+
+// Export writes every user to users and every decision to decisions, reading batchSize rows at a time in ID order.
+// Both tables are read from one snapshot, so every exported decision is on exported users however busy the database
+// is. The writers are flushed once everything has been written. It returns mysql.ErrSharded without exporting anything
+// once decisions are sharded, as only the main database's are read.
+func Export(ctx context.Context, db *mysql.DB, users *Writer[User], decisions *Writer[Decision], batchSize int) (Counts, error) {
+	var counts Counts
+	if err := mysql.CheckUnsharded(ctx, db); err != nil {
+		return counts, err
+	}
+
+	err := db.InSnapshot(ctx, func(tx *mysql.Tx) error {
+		usersQuery := "SELECT id, username, first_name, last_name, birthdate, gender, timezone, latitude, longitude, created_at FROM Users WHERE id > ? ORDER BY id LIMIT %d"
+		var err error
+		counts.Users, err = exportTable(ctx, tx, usersQuery, batchSize, users, scanUser)
+		if err != nil {
+			return err
+		}
+
+		decisionsQuery := "SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT %d"
+		counts.Decisions, err = exportTable(ctx, tx, decisionsQuery, batchSize, decisions, scanDecision)
+		return err
+	})
+	if err != nil {
+		return counts, err
+	}
+
+	if err := users.Flush(); err != nil {
+		return counts, err
+	}
+	return counts, decisions.Flush()
+}
+
+// exportTable writes the rows query returns, paging through them by ID, and returns how many there were
+func exportTable[T Record](ctx context.Context, tx *mysql.Tx, query string, batchSize int, w *Writer[T], scan func(*sql.Rows) (*T, int64, error)) (int, error) {
+	exported := 0
+	for afterId := int64(0); ; {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(query, batchSize), afterId)
+		if err != nil {
+			return exported, err
+		}
+
+		read := 0
+		for rows.Next() {
+			record, id, err := scan(rows)
+			if err == nil {
+				err = w.Write(record)
+			}
+			if err != nil {
+				rows.Close()
+				return exported, err
+			}
+			afterId = id
+			read++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return exported, err
+		}
+
+		exported += read
+		if read < batchSize {
+			return exported, nil
+		}
+	}
+}
+
+func scanUser(rows *sql.Rows) (*User, int64, error) {
+	var u User
+	var birthdate sql.NullTime
+	var gender sql.NullInt64
+	var latitude, longitude sql.NullFloat64
+	if err := rows.Scan(&u.ID, &u.Username, &u.FirstName, &u.LastName, &birthdate, &gender, &u.TimeZone, &latitude, &longitude, &u.CreatedAt); err != nil {
+		return nil, 0, err
+	}
+	if birthdate.Valid {
+		date := birthdate.Time.Format(time.DateOnly)
+		u.Birthdate = &date
+	}
+	if gender.Valid {
+		u.Gender = &gender.Int64
+	}
+	if latitude.Valid {
+		u.Latitude = &latitude.Float64
+	}
+	if longitude.Valid {
+		u.Longitude = &longitude.Float64
+	}
+	return &u, u.ID, nil
+}
+
+func scanDecision(rows *sql.Rows) (*Decision, int64, error) {
+	var d Decision
+	if err := rows.Scan(&d.ID, &d.ActorID, &d.RecipientID, &d.Liked, &d.CreatedAt); err != nil {
+		return nil, 0, err
+	}
+	return &d, d.ID, nil
+}
@@ -0,0 +1,93 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"muzz-project/storage/mysql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	usersQuery := regexp.QuoteMeta("SELECT id, username, first_name, last_name, birthdate, gender, timezone, latitude, longitude, created_at FROM Users WHERE id > ? ORDER BY id LIMIT 2")
+	decisionsQuery := regexp.QuoteMeta("SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT 2")
+	userColumns := []string{"id", "username", "first_name", "last_name", "birthdate", "gender", "timezone", "latitude", "longitude", "created_at"}
+	decisionColumns := []string{"id", "actor_id", "recipient_id", "liked", "created_at"}
+	createdAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		sharded       bool
+		dbOutcomes    func(mock sqlmock.Sqlmock)
+		want          Counts
+		wantUsers     string
+		wantDecisions string
+		wantErr       error
+	}{
+		"pages through both tables": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(usersQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(1, "user1", "John", "Doe", time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC), 1, "Europe/London", 51.5, -0.12, createdAt).
+					AddRow(2, "user2", "Jane", "Smith", nil, nil, "", nil, nil, createdAt))
+				mock.ExpectQuery(usersQuery).WithArgs(2).WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(4, "user4", "Bob", "Brown", nil, nil, "", nil, nil, createdAt))
+				mock.ExpectQuery(decisionsQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(decisionColumns).
+					AddRow(5, 1, 2, true, createdAt).
+					AddRow(7, 2, 1, false, createdAt))
+				mock.ExpectQuery(decisionsQuery).WithArgs(7).WillReturnRows(sqlmock.NewRows(decisionColumns))
+				mock.ExpectRollback()
+			},
+			want: Counts{Users: 3, Decisions: 2},
+			wantUsers: "id,username,first_name,last_name,birthdate,gender,timezone,latitude,longitude,created_at\n" +
+				"1,user1,John,Doe,1990-01-31,1,Europe/London,51.5,-0.12,2024-06-01T00:00:00Z\n" +
+				"2,user2,Jane,Smith,,,,,,2024-06-01T00:00:00Z\n" +
+				"4,user4,Bob,Brown,,,,,,2024-06-01T00:00:00Z\n",
+			wantDecisions: "id,actor_id,recipient_id,liked,created_at\n" +
+				"5,1,2,true,2024-06-01T00:00:00Z\n" +
+				"7,2,1,false,2024-06-01T00:00:00Z\n",
+		},
+		"database error": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(usersQuery).WithArgs(0).WillReturnRows(sqlmock.NewRows(userColumns))
+				mock.ExpectQuery(decisionsQuery).WithArgs(0).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			want:    Counts{},
+			wantErr: sql.ErrConnDone,
+		},
+		"refuses sharded databases": {
+			sharded:    true,
+			dbOutcomes: func(mock sqlmock.Sqlmock) {},
+			want:       Counts{},
+			wantErr:    mysql.ErrSharded,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			expectSharded(mock, tt.sharded)
+			tt.dbOutcomes(mock)
+
+			var users, decisions bytes.Buffer
+			got, err := Export(context.Background(), mysql.NewDB(mockDB), NewUserWriter(&users, CSV), NewDecisionWriter(&decisions, CSV), 2)
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, tt.wantUsers, users.String())
+				assert.Equal(t, tt.wantDecisions, decisions.String())
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
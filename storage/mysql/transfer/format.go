@@ -0,0 +1,290 @@
+// Package transfer copies Users and Decisions between MySQL databases through CSV or JSONL files, keeping their IDs
+// so decisions still refer to the right users. Exports are read from one snapshot, and imports are validated, written
+// in batches and can be resumed.
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case CSV, JSONL:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, must be csv or jsonl", s)
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	// Birthdate is a date, e.g. 1990-01-31
+	Birthdate *string   `json:"birthdate,omitempty"`
+	Gender    *int64    `json:"gender,omitempty"`
+	TimeZone  string    `json:"timezone"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Decision struct {
+	ID          int64     `json:"id"`
+	ActorID     int64     `json:"actor_id"`
+	RecipientID int64     `json:"recipient_id"`
+	Liked       bool      `json:"liked"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Record is a row of one of the tables that can be transferred
+type Record interface {
+	User | Decision
+}
+
+// codec converts records to and from CSV rows, JSONL uses the json tags
+type codec[T Record] struct {
+	columns []string
+	toRow   func(*T) []string
+	fromRow func([]string) (*T, error)
+}
+
+var userCodec = codec[User]{
+	columns: []string{"id", "username", "first_name", "last_name", "birthdate", "gender", "timezone", "latitude", "longitude", "created_at"},
+	toRow: func(u *User) []string {
+		return []string{
+			strconv.FormatInt(u.ID, 10), u.Username, u.FirstName, u.LastName, formatOptional(u.Birthdate, func(s string) string { return s }),
+			formatOptional(u.Gender, formatInt), u.TimeZone, formatOptional(u.Latitude, formatFloat), formatOptional(u.Longitude, formatFloat),
+			u.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+	},
+	fromRow: func(row []string) (*User, error) {
+		var u User
+		var err error
+		u.ID, err = strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("id: %w", err)
+		}
+		u.Username, u.FirstName, u.LastName, u.TimeZone = row[1], row[2], row[3], row[6]
+		if row[4] != "" {
+			u.Birthdate = &row[4]
+		}
+		if u.Gender, err = parseOptional(row[5], parseInt); err != nil {
+			return nil, fmt.Errorf("gender: %w", err)
+		}
+		if u.Latitude, err = parseOptional(row[7], parseFloat); err != nil {
+			return nil, fmt.Errorf("latitude: %w", err)
+		}
+		if u.Longitude, err = parseOptional(row[8], parseFloat); err != nil {
+			return nil, fmt.Errorf("longitude: %w", err)
+		}
+		if u.CreatedAt, err = time.Parse(time.RFC3339Nano, row[9]); err != nil {
+			return nil, fmt.Errorf("created_at: %w", err)
+		}
+		return &u, nil
+	},
+}
+
+var decisionCodec = codec[Decision]{
+	columns: []string{"id", "actor_id", "recipient_id", "liked", "created_at"},
+	toRow: func(d *Decision) []string {
+		return []string{
+			strconv.FormatInt(d.ID, 10), strconv.FormatInt(d.ActorID, 10), strconv.FormatInt(d.RecipientID, 10),
+			strconv.FormatBool(d.Liked), d.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+	},
+	fromRow: func(row []string) (*Decision, error) {
+		var d Decision
+		var err error
+		if d.ID, err = strconv.ParseInt(row[0], 10, 64); err != nil {
+			return nil, fmt.Errorf("id: %w", err)
+		}
+		if d.ActorID, err = strconv.ParseInt(row[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("actor_id: %w", err)
+		}
+		if d.RecipientID, err = strconv.ParseInt(row[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("recipient_id: %w", err)
+		}
+		if d.Liked, err = strconv.ParseBool(row[3]); err != nil {
+			return nil, fmt.Errorf("liked: %w", err)
+		}
+		if d.CreatedAt, err = time.Parse(time.RFC3339Nano, row[4]); err != nil {
+			return nil, fmt.Errorf("created_at: %w", err)
+		}
+		return &d, nil
+	},
+}
+
+// Writer writes records to a file, a CSV file starting with a header row
+type Writer[T Record] struct {
+	codec  codec[T]
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+	header bool
+}
+
+func NewUserWriter(w io.Writer, format Format) *Writer[User] {
+	return newWriter(w, format, userCodec)
+}
+
+func NewDecisionWriter(w io.Writer, format Format) *Writer[Decision] {
+	return newWriter(w, format, decisionCodec)
+}
+
+func newWriter[T Record](w io.Writer, format Format, c codec[T]) *Writer[T] {
+	buf := bufio.NewWriter(w)
+	if format == CSV {
+		return &Writer[T]{codec: c, buf: buf, csv: csv.NewWriter(buf)}
+	}
+	return &Writer[T]{codec: c, buf: buf, json: json.NewEncoder(buf)}
+}
+
+func (w *Writer[T]) Write(record *T) error {
+	if w.json != nil {
+		return w.json.Encode(record)
+	}
+	if !w.header {
+		if err := w.csv.Write(w.codec.columns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	return w.csv.Write(w.codec.toRow(record))
+}
+
+// Flush writes anything buffered, it must be called once everything has been written
+func (w *Writer[T]) Flush() error {
+	if w.csv != nil {
+		//An empty CSV file still has a header so it can be told apart from a truncated one
+		if !w.header {
+			if err := w.csv.Write(w.codec.columns); err != nil {
+				return err
+			}
+			w.header = true
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// Reader reads records written by a Writer of the same format
+type Reader[T Record] struct {
+	codec  codec[T]
+	csv    *csv.Reader
+	json   *json.Decoder
+	header bool
+	// records counts the JSONL records read, CSV errors give the line instead
+	records int
+}
+
+func NewUserReader(r io.Reader, format Format) *Reader[User] {
+	return newReader(r, format, userCodec)
+}
+
+func NewDecisionReader(r io.Reader, format Format) *Reader[Decision] {
+	return newReader(r, format, decisionCodec)
+}
+
+func newReader[T Record](r io.Reader, format Format, c codec[T]) *Reader[T] {
+	buf := bufio.NewReader(r)
+	if format == CSV {
+		reader := csv.NewReader(buf)
+		reader.FieldsPerRecord = len(c.columns)
+		return &Reader[T]{codec: c, csv: reader}
+	}
+	decoder := json.NewDecoder(buf)
+	decoder.DisallowUnknownFields()
+	return &Reader[T]{codec: c, json: decoder}
+}
+
+// Read returns the next record, or io.EOF once they've all been read. Errors say which record is malformed.
+func (r *Reader[T]) Read() (*T, error) {
+	if r.json != nil {
+		r.records++
+		var record T
+		if err := r.json.Decode(&record); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, fmt.Errorf("record %d: %w", r.records, err)
+		}
+		return &record, nil
+	}
+
+	if !r.header {
+		header, err := r.csv.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing header row")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(header, r.codec.columns) {
+			return nil, fmt.Errorf("header row is %v, want %v", header, r.codec.columns)
+		}
+		r.header = true
+	}
+
+	row, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	record, err := r.codec.fromRow(row)
+	if err != nil {
+		line, _ := r.csv.FieldPos(0)
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+	return record, nil
+}
+
+func formatOptional[T any](value *T, format func(T) string) string {
+	if value == nil {
+		return ""
+	}
+	return format(*value)
+}
+
+func parseOptional[T any](s string, parse func(string) (T, error)) (*T, error) {
+	if s == "" {
+		return nil, nil
+	}
+	value, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func formatInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func parseInt(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,109 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterReader_RoundTrip(t *testing.T) {
+	birthdate := "1990-01-31"
+	gender := int64(1)
+	latitude, longitude := 51.5, -0.12
+	createdAt := time.Date(2024, 6, 1, 12, 30, 0, 500, time.UTC)
+
+	users := []*User{
+		{ID: 1, Username: "user1", FirstName: "John", LastName: "Doe, Jr", Birthdate: &birthdate, Gender: &gender, TimeZone: "Europe/London", Latitude: &latitude, Longitude: &longitude, CreatedAt: createdAt},
+		{ID: 2, Username: "user2", FirstName: "Jane", LastName: "Smith", CreatedAt: createdAt},
+	}
+	decisions := []*Decision{
+		{ID: 1, ActorID: 1, RecipientID: 2, Liked: true, CreatedAt: createdAt},
+		{ID: 2, ActorID: 2, RecipientID: 1, Liked: false, CreatedAt: createdAt},
+	}
+
+	for _, format := range []Format{CSV, JSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			var usersFile, decisionsFile bytes.Buffer
+
+			userWriter := NewUserWriter(&usersFile, format)
+			for _, u := range users {
+				assert.NoError(t, userWriter.Write(u))
+			}
+			assert.NoError(t, userWriter.Flush())
+			decisionWriter := NewDecisionWriter(&decisionsFile, format)
+			for _, d := range decisions {
+				assert.NoError(t, decisionWriter.Write(d))
+			}
+			assert.NoError(t, decisionWriter.Flush())
+
+			assert.Equal(t, users, readAll(t, NewUserReader(&usersFile, format)))
+			assert.Equal(t, decisions, readAll(t, NewDecisionReader(&decisionsFile, format)))
+		})
+	}
+}
+
+func TestWriter_EmptyCSV(t *testing.T) {
+	var file bytes.Buffer
+	w := NewDecisionWriter(&file, CSV)
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, "id,actor_id,recipient_id,liked,created_at\n", file.String())
+	_, err := NewDecisionReader(&file, CSV).Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_Errors(t *testing.T) {
+	tests := map[string]struct {
+		format  Format
+		input   string
+		wantErr string
+	}{
+		"csv missing header": {
+			format:  CSV,
+			input:   "",
+			wantErr: "missing header row",
+		},
+		"csv wrong header": {
+			format:  CSV,
+			input:   "id,recipient_id,actor_id,liked,created_at\n",
+			wantErr: "header row is [id recipient_id actor_id liked created_at], want [id actor_id recipient_id liked created_at]",
+		},
+		"csv bad field": {
+			format:  CSV,
+			input:   "id,actor_id,recipient_id,liked,created_at\n1,1,2,true,2024-06-01T00:00:00Z\n2,1,3,maybe,2024-06-01T00:00:00Z\n",
+			wantErr: `line 3: liked: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+		"jsonl unknown field": {
+			format:  JSONL,
+			input:   `{"id":1,"actor_id":1,"recipient_id":2,"liked":true,"created_at":"2024-06-01T00:00:00Z"}` + "\n" + `{"id":2,"actor":1}` + "\n",
+			wantErr: `record 2: json: unknown field "actor"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewDecisionReader(strings.NewReader(tt.input), tt.format)
+			var err error
+			for err == nil {
+				_, err = r.Read()
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func readAll[T Record](t *testing.T, r *Reader[T]) []*T {
+	var records []*T
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		assert.NoError(t, err)
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,437 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"muzz-project/storage/mysql"
+	"muzz-project/storage/mysql/internal/sqlutil"
+	"time"
+)
+
+// Import cursors are kept in JobCursors, holding the ID of the last record imported
+const (
+	usersCursor     = "import-users"
+	decisionsCursor = "import-decisions"
+)
+
+// Problem is a record that can't be imported
+type Problem struct {
+	Table  string
+	ID     int64
+	Reason string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s %d: %s", p.Table, p.ID, p.Reason)
+}
+
+// Importer writes exported users and decisions to a database. Records must be in ID order, as Export writes them, so
+// an interrupted import can resume after the last record it wrote. Users have to be checked before decisions, as the
+// users a decision is on may only be in the import, and both checked before being imported. Decisions are only
+// written to the main database, so they're refused with mysql.ErrSharded once decisions are sharded.
+type Importer struct {
+	db        *mysql.DB
+	batchSize int
+	// users holds the IDs of every user being imported
+	users map[int64]bool
+}
+
+func NewImporter(db *mysql.DB, batchSize int) *Importer {
+	return &Importer{
+		db:        db,
+		batchSize: batchSize,
+		users:     make(map[int64]bool),
+	}
+}
+
+// Restart forgets how far earlier imports got, so the next starts from the first record
+func (i *Importer) Restart(ctx context.Context) error {
+	_, err := i.db.ExecContext(ctx, `DELETE FROM JobCursors WHERE name IN (?, ?)`, usersCursor, decisionsCursor)
+	return err
+}
+
+// CheckUsers reads every user and returns the problems that would stop those not yet imported being imported: being
+// out of ID order, duplicate IDs or usernames, IDs or usernames already in the database, and invalid birthdates
+func (i *Importer) CheckUsers(ctx context.Context, r *Reader[User]) ([]Problem, error) {
+	cursor, err := i.cursor(ctx, usersCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	report := func(id int64, reason string, args ...any) {
+		problems = append(problems, Problem{Table: "user", ID: id, Reason: fmt.Sprintf(reason, args...)})
+	}
+
+	usernames := make(map[string]int64)
+	var batch []*User
+	var lastId int64
+	for {
+		u, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case u.ID == lastId:
+			report(u.ID, "duplicate ID")
+			continue
+		case u.ID < lastId:
+			report(u.ID, "after user %d, users must be in ID order", lastId)
+			continue
+		}
+		lastId = u.ID
+		i.users[u.ID] = true
+		if u.ID <= cursor {
+			continue
+		}
+
+		if u.Username == "" {
+			report(u.ID, "no username")
+		} else if other, ok := usernames[u.Username]; ok {
+			report(u.ID, "username %q is also user %d's", u.Username, other)
+		} else {
+			usernames[u.Username] = u.ID
+		}
+		if u.Birthdate != nil {
+			if _, err := time.Parse(time.DateOnly, *u.Birthdate); err != nil {
+				report(u.ID, "birthdate %q isn't a date", *u.Birthdate)
+			}
+		}
+
+		batch = append(batch, u)
+		if len(batch) == i.batchSize {
+			if err := i.checkExistingUsers(ctx, batch, report); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := i.checkExistingUsers(ctx, batch, report); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// checkExistingUsers reports users whose ID or username is already in the database
+func (i *Importer) checkExistingUsers(ctx context.Context, users []*User, report func(int64, string, ...any)) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	importing := make(map[int64]bool)
+	byUsername := make(map[string]int64)
+	var ids, usernames []any
+	for _, u := range users {
+		importing[u.ID] = true
+		ids = append(ids, u.ID)
+		usernames = append(usernames, u.Username)
+		byUsername[u.Username] = u.ID
+	}
+	query := fmt.Sprintf("SELECT id, username FROM Users WHERE id IN (%s) OR username IN (%s)", sqlutil.Placeholders(len(ids)), sqlutil.Placeholders(len(usernames)))
+
+	rows, err := i.db.QueryContext(ctx, query, append(ids, usernames...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return err
+		}
+		if importing[id] {
+			report(id, "already exists")
+		}
+		if other, ok := byUsername[username]; ok && other != id {
+			report(other, "username %q is already user %d's", username, id)
+		}
+	}
+
+	return rows.Err()
+}
+
+// CheckDecisions reads every decision and returns the problems that would stop those not yet imported being
+// imported: being out of ID order, duplicate IDs, users deciding on themselves, users that neither exist nor are
+// being imported, and decisions on the same users as another or with an ID already in the database
+func (i *Importer) CheckDecisions(ctx context.Context, r *Reader[Decision]) ([]Problem, error) {
+	if err := mysql.CheckUnsharded(ctx, i.db); err != nil {
+		return nil, err
+	}
+	cursor, err := i.cursor(ctx, decisionsCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	report := func(id int64, reason string, args ...any) {
+		problems = append(problems, Problem{Table: "decision", ID: id, Reason: fmt.Sprintf(reason, args...)})
+	}
+
+	pairs := make(map[[2]int64]int64)
+	var batch []*Decision
+	var lastId int64
+	for {
+		d, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case d.ID == lastId:
+			report(d.ID, "duplicate ID")
+			continue
+		case d.ID < lastId:
+			report(d.ID, "after decision %d, decisions must be in ID order", lastId)
+			continue
+		}
+		lastId = d.ID
+		if d.ID <= cursor {
+			continue
+		}
+
+		if d.ActorID == d.RecipientID {
+			report(d.ID, "user %d decided on themselves", d.ActorID)
+		}
+		pair := [2]int64{d.ActorID, d.RecipientID}
+		if other, ok := pairs[pair]; ok {
+			report(d.ID, "duplicate of decision %d", other)
+		} else {
+			pairs[pair] = d.ID
+		}
+
+		batch = append(batch, d)
+		if len(batch) == i.batchSize {
+			if err := i.checkExistingDecisions(ctx, batch, report); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := i.checkExistingDecisions(ctx, batch, report); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// checkExistingDecisions reports decisions on users that don't exist, and decisions whose ID or users are already
+// in the database
+func (i *Importer) checkExistingDecisions(ctx context.Context, decisions []*Decision, report func(int64, string, ...any)) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+
+	//Users being imported are known to be valid, only the others need looking up
+	var unknown []any
+	seen := make(map[int64]bool)
+	for _, d := range decisions {
+		for _, userId := range decisionUsers(d) {
+			if !i.users[userId] && !seen[userId] {
+				unknown = append(unknown, userId)
+				seen[userId] = true
+			}
+		}
+	}
+	existing := make(map[int64]bool)
+	if len(unknown) > 0 {
+		rows, err := i.db.QueryContext(ctx, fmt.Sprintf("SELECT id FROM Users WHERE id IN (%s)", sqlutil.Placeholders(len(unknown))), unknown...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, d := range decisions {
+		for _, userId := range decisionUsers(d) {
+			if !i.users[userId] && !existing[userId] {
+				report(d.ID, "user %d doesn't exist", userId)
+			}
+		}
+	}
+
+	importing := make(map[int64]bool)
+	byPair := make(map[[2]int64]int64)
+	var ids, pairs []any
+	for _, d := range decisions {
+		importing[d.ID] = true
+		ids = append(ids, d.ID)
+		pairs = append(pairs, d.ActorID, d.RecipientID)
+		byPair[[2]int64{d.ActorID, d.RecipientID}] = d.ID
+	}
+	query := fmt.Sprintf("SELECT id, actor_id, recipient_id FROM Decisions WHERE id IN (%s) OR (actor_id, recipient_id) IN (%s)", sqlutil.Placeholders(len(ids)), sqlutil.ValuesList(len(decisions), 2))
+
+	rows, err := i.db.QueryContext(ctx, query, append(ids, pairs...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, actorId, recipientId int64
+		if err := rows.Scan(&id, &actorId, &recipientId); err != nil {
+			return err
+		}
+		if importing[id] {
+			report(id, "already exists")
+		}
+		if other, ok := byPair[[2]int64{actorId, recipientId}]; ok && other != id {
+			report(other, "user %d has already decided on user %d in decision %d", actorId, recipientId, id)
+		}
+	}
+
+	return rows.Err()
+}
+
+// decisionUsers returns the users d is between, once even if they decided on themselves
+func decisionUsers(d *Decision) []int64 {
+	if d.ActorID == d.RecipientID {
+		return []int64{d.ActorID}
+	}
+	return []int64{d.ActorID, d.RecipientID}
+}
+
+// ImportUsers writes the users not yet imported, batchSize at a time. Each batch is committed with the cursor, so an
+// interrupted import picks up after the last batch written.
+func (i *Importer) ImportUsers(ctx context.Context, r *Reader[User]) (int, error) {
+	return importBatches(ctx, i, r, usersCursor, func(u *User) int64 { return u.ID }, func(tx *mysql.Tx, users []*User) error {
+		var args []any
+		for _, u := range users {
+			args = append(args, u.ID, u.Username, u.FirstName, u.LastName, optional(u.Birthdate), optional(u.Gender), u.TimeZone, optional(u.Latitude), optional(u.Longitude), u.CreatedAt)
+		}
+		query := "INSERT INTO Users (id, username, first_name, last_name, birthdate, gender, timezone, latitude, longitude, created_at) VALUES " + sqlutil.ValuesList(len(users), 10)
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// ImportDecisions writes the decisions not yet imported, like ImportUsers, and adds the likes to the recipients'
+// like counters
+func (i *Importer) ImportDecisions(ctx context.Context, r *Reader[Decision]) (int, error) {
+	if err := mysql.CheckUnsharded(ctx, i.db); err != nil {
+		return 0, err
+	}
+	return importBatches(ctx, i, r, decisionsCursor, func(d *Decision) int64 { return d.ID }, func(tx *mysql.Tx, decisions []*Decision) error {
+		var args []any
+		likes := make(map[int64]int64)
+		var recipients []int64
+		for _, d := range decisions {
+			args = append(args, d.ID, d.ActorID, d.RecipientID, d.Liked, d.CreatedAt)
+			if d.Liked {
+				if likes[d.RecipientID] == 0 {
+					recipients = append(recipients, d.RecipientID)
+				}
+				likes[d.RecipientID]++
+			}
+		}
+		query := "INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES " + sqlutil.ValuesList(len(decisions), 5)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		if len(recipients) == 0 {
+			return nil
+		}
+		var counterArgs []any
+		for _, recipientId := range recipients {
+			counterArgs = append(counterArgs, recipientId, likes[recipientId])
+		}
+		counterQuery := "INSERT INTO LikeCounters (user_id, likes_received) VALUES " + sqlutil.ValuesList(len(recipients), 2) +
+			" ON DUPLICATE KEY UPDATE likes_received = likes_received + VALUES(likes_received)"
+		_, err := tx.ExecContext(ctx, counterQuery, counterArgs...)
+		return err
+	})
+}
+
+// importBatches writes the records after cursor's ID in batches, saving the cursor with each
+func importBatches[T Record](ctx context.Context, i *Importer, r *Reader[T], cursorName string, id func(*T) int64, write func(*mysql.Tx, []*T) error) (int, error) {
+	cursor, err := i.cursor(ctx, cursorName)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	var batch []*T
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := i.db.InTx(ctx, func(tx *mysql.Tx) error {
+			if err := write(tx, batch); err != nil {
+				return err
+			}
+			cursorQuery := `INSERT INTO JobCursors (name, last_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE last_id = VALUES(last_id)`
+			_, err := tx.ExecContext(ctx, cursorQuery, cursorName, id(batch[len(batch)-1]))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+		if id(record) <= cursor {
+			continue
+		}
+
+		batch = append(batch, record)
+		if len(batch) == i.batchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+
+	return imported, flush()
+}
+
+// cursor returns the ID of the last record imported with name, or 0 if there isn't one
+func (i *Importer) cursor(ctx context.Context, name string) (int64, error) {
+	var lastId int64
+	err := i.db.QueryRowContext(ctx, `SELECT last_id FROM JobCursors WHERE name = ?`, name).Scan(&lastId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return lastId, err
+}
+
+// optional returns value's value, or nil for NULL
+func optional[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,229 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"muzz-project/storage/mysql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	cursorQuery        = regexp.QuoteMeta("SELECT last_id FROM JobCursors WHERE name = ?")
+	saveCursorQuery    = regexp.QuoteMeta("INSERT INTO JobCursors (name, last_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE last_id = VALUES(last_id)")
+	existingUsersQuery = regexp.QuoteMeta("SELECT id, username FROM Users WHERE id IN (?, ?) OR username IN (?, ?)")
+)
+
+func expectSharded(mock sqlmock.Sqlmock, sharded bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM UserShards)")).WillReturnRows(sqlmock.NewRows([]string{"sharded"}).AddRow(sharded))
+}
+
+const usersCSV = "id,username,first_name,last_name,birthdate,gender,timezone,latitude,longitude,created_at\n" +
+	"1,user1,John,Doe,,,,,,2024-06-01T00:00:00Z\n" +
+	"2,user2,Jane,Smith,,,,,,2024-06-01T00:00:00Z\n" +
+	"3,user3,Alice,Johnson,,,,,,2024-06-01T00:00:00Z\n"
+
+func TestImporter_CheckUsers(t *testing.T) {
+	tests := map[string]struct {
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		input      string
+		want       []Problem
+	}{
+		"valid users": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(usersCursor).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(existingUsersQuery).WithArgs(1, 2, "user1", "user2").WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username FROM Users WHERE id IN (?) OR username IN (?)")).WithArgs(3, "user3").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+			},
+			input: usersCSV,
+		},
+		"users already imported aren't checked": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(usersCursor).WillReturnRows(sqlmock.NewRows([]string{"last_id"}).AddRow(3))
+			},
+			input: usersCSV,
+		},
+		"problems in the file": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(usersCursor).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(existingUsersQuery).WithArgs(1, 3, "user1", "user1").WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+			},
+			input: "id,username,first_name,last_name,birthdate,gender,timezone,latitude,longitude,created_at\n" +
+				"1,user1,John,Doe,1990-02-31,,,,,2024-06-01T00:00:00Z\n" +
+				"1,user2,Jane,Smith,,,,,,2024-06-01T00:00:00Z\n" +
+				"3,user1,Alice,Johnson,,,,,,2024-06-01T00:00:00Z\n" +
+				"2,user4,Bob,Brown,,,,,,2024-06-01T00:00:00Z\n",
+			want: []Problem{
+				{Table: "user", ID: 1, Reason: `birthdate "1990-02-31" isn't a date`},
+				{Table: "user", ID: 1, Reason: "duplicate ID"},
+				{Table: "user", ID: 3, Reason: `username "user1" is also user 1's`},
+				{Table: "user", ID: 2, Reason: "after user 3, users must be in ID order"},
+			},
+		},
+		"users already in the database": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(usersCursor).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(existingUsersQuery).WithArgs(1, 2, "user1", "user2").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "user1").AddRow(7, "user2"))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username FROM Users WHERE id IN (?) OR username IN (?)")).WithArgs(3, "user3").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+			},
+			input: usersCSV,
+			want: []Problem{
+				{Table: "user", ID: 1, Reason: "already exists"},
+				{Table: "user", ID: 2, Reason: `username "user2" is already user 7's`},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			tt.dbOutcomes(mock)
+
+			i := NewImporter(mysql.NewDB(mockDB), 2)
+			got, err := i.CheckUsers(context.Background(), NewUserReader(strings.NewReader(tt.input), CSV))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImporter_CheckDecisions(t *testing.T) {
+	existingDecisionsQuery := regexp.QuoteMeta("SELECT id, actor_id, recipient_id FROM Decisions WHERE id IN (?, ?, ?, ?) OR (actor_id, recipient_id) IN ((?, ?), (?, ?), (?, ?), (?, ?))")
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	expectSharded(mock, false)
+	mock.ExpectQuery(cursorQuery).WithArgs(decisionsCursor).WillReturnRows(sqlmock.NewRows([]string{"last_id"}).AddRow(1))
+	//Users 1 and 2 are being imported, 5 is already in the database and 6 doesn't exist
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM Users WHERE id IN (?, ?, ?)")).WithArgs(5, 9, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(existingDecisionsQuery).WithArgs(2, 3, 4, 5, 1, 5, 9, 9, 2, 6, 1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id"}).AddRow(2, 2, 1).AddRow(1, 1, 5))
+
+	input := "id,actor_id,recipient_id,liked,created_at\n" +
+		"1,1,5,true,2024-06-01T00:00:00Z\n" +
+		"2,1,5,true,2024-06-01T00:00:00Z\n" +
+		"3,9,9,true,2024-06-01T00:00:00Z\n" +
+		"4,2,6,false,2024-06-01T00:00:00Z\n" +
+		"5,1,5,true,2024-06-01T00:00:00Z\n"
+
+	i := NewImporter(mysql.NewDB(mockDB), 10)
+	i.users = map[int64]bool{1: true, 2: true}
+	got, err := i.CheckDecisions(context.Background(), NewDecisionReader(strings.NewReader(input), CSV))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Problem{
+		{Table: "decision", ID: 3, Reason: "user 9 decided on themselves"},
+		{Table: "decision", ID: 5, Reason: "duplicate of decision 2"},
+		{Table: "decision", ID: 3, Reason: "user 9 doesn't exist"},
+		{Table: "decision", ID: 4, Reason: "user 6 doesn't exist"},
+		{Table: "decision", ID: 2, Reason: "already exists"},
+		{Table: "decision", ID: 5, Reason: "user 1 has already decided on user 5 in decision 1"},
+	}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImporter_ImportDecisions(t *testing.T) {
+	insertQuery := regexp.QuoteMeta("INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)")
+	counterQuery := regexp.QuoteMeta("INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, ?) ON DUPLICATE KEY UPDATE likes_received = likes_received + VALUES(likes_received)")
+
+	input := "id,actor_id,recipient_id,liked,created_at\n" +
+		"1,1,2,true,2024-06-01T00:00:00Z\n" +
+		"2,3,2,true,2024-06-01T00:00:00Z\n" +
+		"3,1,3,false,2024-06-01T00:00:00Z\n" +
+		"4,2,1,false,2024-06-01T00:00:00Z\n" +
+		"5,2,3,true,2024-06-01T00:00:00Z\n"
+
+	tests := map[string]struct {
+		sharded    bool
+		dbOutcomes func(mock sqlmock.Sqlmock)
+		want       int
+		wantErr    error
+	}{
+		"imports in batches": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(decisionsCursor).WillReturnError(sql.ErrNoRows)
+				mock.ExpectBegin()
+				mock.ExpectExec(insertQuery).WithArgs(1, 1, 2, true, sqlmock.AnyArg(), 2, 3, 2, true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(counterQuery).WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(saveCursorQuery).WithArgs(decisionsCursor, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(insertQuery).WithArgs(3, 1, 3, false, sqlmock.AnyArg(), 4, 2, 1, false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(saveCursorQuery).WithArgs(decisionsCursor, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)")).
+					WithArgs(5, 2, 3, true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(saveCursorQuery).WithArgs(decisionsCursor, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: 5,
+		},
+		"resumes after the cursor": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(decisionsCursor).WillReturnRows(sqlmock.NewRows([]string{"last_id"}).AddRow(4))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Decisions (id, actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?, ?)")).
+					WithArgs(5, 2, 3, true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(counterQuery).WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(saveCursorQuery).WithArgs(decisionsCursor, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: 1,
+		},
+		"failed batch isn't counted": {
+			dbOutcomes: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(cursorQuery).WithArgs(decisionsCursor).WillReturnError(sql.ErrNoRows)
+				mock.ExpectBegin()
+				mock.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(counterQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(saveCursorQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(insertQuery).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			want:    2,
+			wantErr: sql.ErrConnDone,
+		},
+		"refuses sharded databases": {
+			sharded:    true,
+			dbOutcomes: func(mock sqlmock.Sqlmock) {},
+			wantErr:    mysql.ErrSharded,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer mockDB.Close()
+
+			expectSharded(mock, tt.sharded)
+			tt.dbOutcomes(mock)
+
+			i := NewImporter(mysql.NewDB(mockDB), 2)
+			got, err := i.ImportDecisions(context.Background(), NewDecisionReader(strings.NewReader(input), CSV))
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,603 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"muzz-project/geo"
+	"muzz-project/score"
+	"muzz-project/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Schema creates every table PostgresStorage uses, it's safe to run more than once
+//
+//go:embed schema.sql
+var Schema string
+
+// distanceBandKm groups candidates by distance so more desirable users can come first among those similarly close
+const distanceBandKm = 10
+
+const desirabilityCursor = "desirability"
+
+// SQLSTATE codes for constraint violations
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type PostgresStorage struct {
+	db          *sql.DB
+	maxPageSize int
+}
+
+var _ storage.Storage = (*PostgresStorage)(nil)
+
+func NewPostgresStorage(db *sql.DB, maxPageSize int) *PostgresStorage {
+	return &PostgresStorage{
+		db:          db,
+		maxPageSize: maxPageSize,
+	}
+}
+
+// args numbers placeholders as values are added to a query
+type args []any
+
+func (a *args) add(value any) string {
+	*a = append(*a, value)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+func (p *PostgresStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return p.getLikes(ctx, userId, "", filter, paginationToken)
+}
+
+func (p *PostgresStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return p.getLikes(ctx, userId, " AND NOT EXISTS (SELECT 1 FROM Decisions d2 WHERE d2.actor_id = d.recipient_id AND d2.recipient_id = d.actor_id)", filter, paginationToken)
+}
+
+func (p *PostgresStorage) getLikes(ctx context.Context, userId string, condition string, filter storage.LikesFilter, paginationToken int) ([]*storage.Decision, error) {
+	var query strings.Builder
+	var a args
+
+	query.WriteString("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at, " + distanceKm("a", "r") + " AS distance_km FROM Decisions d JOIN Users a ON a.id = d.actor_id JOIN Users r ON r.id = d.recipient_id")
+	if filter.MatchPreferences {
+		query.WriteString(" LEFT JOIN Preferences ap ON ap.user_id = a.id LEFT JOIN Preferences rp ON rp.user_id = r.id")
+	}
+	query.WriteString(" WHERE d.recipient_id = " + a.add(parseId(userId)) + " AND d.liked = TRUE")
+	query.WriteString(condition)
+	if filter.MatchPreferences {
+		query.WriteString(" AND " + matchesPreferences("rp", "a", "ap"))
+	}
+	query.WriteString(" ORDER BY d.created_at DESC, d.id DESC LIMIT " + a.add(p.maxPageSize) + " OFFSET " + a.add(paginationToken))
+
+	rows, err := p.db.QueryContext(ctx, query.String(), a...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*storage.Decision
+	for rows.Next() {
+		var decision storage.Decision
+		var distance sql.NullFloat64
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt, &distance); err != nil {
+			return nil, err
+		}
+		decision.DistanceKm = nullFloatPtr(distance)
+		decisions = append(decisions, &decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// distanceKm is the haversine distance between users a and b, NULL if either has no location. Postgres has no
+// ST_Distance_Sphere without PostGIS, this uses the same radius so distances agree with MySQL.
+func distanceKm(a, b string) string {
+	return strings.NewReplacer("{a}", a, "{b}", b).Replace(fmt.Sprintf(
+		"%g * 2 * ASIN(LEAST(1, SQRT(POWER(SIN(RADIANS({b}.latitude - {a}.latitude) / 2), 2)"+
+			" + COS(RADIANS({a}.latitude)) * COS(RADIANS({b}.latitude)) * POWER(SIN(RADIANS({b}.longitude - {a}.longitude) / 2), 2))))", geo.EarthRadiusKm))
+}
+
+// matchesPreferences is a condition that is true when other fits userPrefs. userPrefs and otherPrefs are LEFT JOINed
+// Preferences rows so a missing row, like a zero value, means no restriction. Users who haven't set a birthdate or
+// gender never match a restriction on it.
+func matchesPreferences(userPrefs, other, otherPrefs string) string {
+	return strings.NewReplacer("{userPrefs}", userPrefs, "{other}", other, "{otherPrefs}", otherPrefs).Replace(
+		"(COALESCE({userPrefs}.min_age, 0) = 0 OR EXTRACT(YEAR FROM AGE(CURRENT_DATE, {other}.birthdate)) >= {userPrefs}.min_age)" +
+			" AND (COALESCE({userPrefs}.max_age, 0) = 0 OR EXTRACT(YEAR FROM AGE(CURRENT_DATE, {other}.birthdate)) <= {userPrefs}.max_age)" +
+			" AND (COALESCE({userPrefs}.genders, 0) = 0 OR ({userPrefs}.genders & (1::BIGINT << {other}.gender)) <> 0)" +
+			" AND (COALESCE({userPrefs}.intent, 0) = 0 OR COALESCE({otherPrefs}.intent, 0) = 0 OR {userPrefs}.intent = {otherPrefs}.intent)")
+}
+
+func (p *PostgresStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	var count int64
+	query := `SELECT likes_received FROM LikeCounters WHERE user_id = $1`
+
+	err := p.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *PostgresStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	//Check to see if recipient has already liked actor - if so it's a match!
+	reciprocal := false
+
+	if liked {
+		matchQuery := `SELECT EXISTS (SELECT 1 FROM Decisions WHERE recipient_id = $1 AND actor_id = $2 AND liked = TRUE)`
+		if err := tx.QueryRowContext(ctx, matchQuery, parseId(actorId), parseId(recipientId)).Scan(&reciprocal); err != nil {
+			return false, err
+		}
+	}
+
+	query := `INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES ($1, $2, $3, $4)`
+	_, err = tx.ExecContext(ctx, query, parseId(actorId), parseId(recipientId), liked, time.Now())
+	if isPgError(err, uniqueViolation) {
+		return false, storage.ErrDuplicateDecision
+	}
+	if isPgError(err, foreignKeyViolation) {
+		return false, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if liked {
+		counterQuery := `INSERT INTO LikeCounters (user_id, likes_received) VALUES ($1, 1) ON CONFLICT (user_id) DO UPDATE SET likes_received = LikeCounters.likes_received + 1`
+		if _, err := tx.ExecContext(ctx, counterQuery, parseId(recipientId)); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return reciprocal, nil
+}
+
+func (p *PostgresStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	query := `UPDATE Users SET latitude = $1, longitude = $2 WHERE id = $3`
+	result, err := p.db.ExecContext(ctx, query, latitude, longitude, parseId(userId))
+	return userUpdated(result, err)
+}
+
+func (p *PostgresStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	var latitude, longitude sql.NullFloat64
+
+	err := p.db.QueryRowContext(ctx, `SELECT latitude, longitude FROM Users WHERE id = $1`, parseId(userId)).Scan(&latitude, &longitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var query strings.Builder
+	var a args
+	now := a.add(time.Now())
+
+	//Postgres can't use output columns in ORDER BY expressions, so the ranking is worked out in a subquery
+	query.WriteString("SELECT c.id, c.distance_km FROM (SELECT u.id, " + distanceKm("u", "me") + " AS distance_km, b.user_id IS NOT NULL AS boosted, rec.ranking, COALESCE(ds.score, " + fmt.Sprintf("%g", score.InitialScore) + ") AS score")
+	query.WriteString(" FROM Users u JOIN Users me ON me.id = " + a.add(parseId(userId)) + " LEFT JOIN Preferences up ON up.user_id = u.id LEFT JOIN Preferences mp ON mp.user_id = me.id LEFT JOIN DesirabilityScores ds ON ds.user_id = u.id LEFT JOIN Recommendations rec ON rec.user_id = me.id AND rec.candidate_id = u.id LEFT JOIN Boosts b ON b.user_id = u.id AND b.starts_at <= " + now + " AND b.ends_at > " + now)
+	query.WriteString(" WHERE u.id <> me.id AND NOT EXISTS (SELECT 1 FROM Decisions d WHERE d.actor_id = me.id AND d.recipient_id = u.id)")
+	query.WriteString(" AND " + matchesPreferences("mp", "u", "up"))
+	query.WriteString(" AND " + matchesPreferences("up", "me", "mp"))
+
+	if maxDistanceKm > 0 {
+		if !latitude.Valid || !longitude.Valid {
+			return nil, storage.ErrLocationUnknown
+		}
+
+		//Bounding box lets the location index discard most users before the exact distance is calculated
+		box := geo.NewBoundingBox(latitude.Float64, longitude.Float64, maxDistanceKm)
+		query.WriteString(" AND u.latitude BETWEEN " + a.add(box.MinLatitude) + " AND " + a.add(box.MaxLatitude))
+		if box.CrossesAntimeridian() {
+			query.WriteString(" AND (u.longitude >= " + a.add(box.MinLongitude) + " OR u.longitude <= " + a.add(box.MaxLongitude) + ")")
+		} else {
+			query.WriteString(" AND u.longitude BETWEEN " + a.add(box.MinLongitude) + " AND " + a.add(box.MaxLongitude))
+		}
+		query.WriteString(") c WHERE c.distance_km <= " + a.add(maxDistanceKm))
+	} else {
+		query.WriteString(") c")
+	}
+
+	//Boosted users come first, then recommended users, then nearby users ranked by desirability within each distance band
+	fmt.Fprintf(&query, " ORDER BY c.boosted DESC, c.ranking IS NULL, c.ranking, c.distance_km IS NULL, FLOOR(c.distance_km / %d), c.score DESC, c.id", distanceBandKm)
+	query.WriteString(" LIMIT " + a.add(p.maxPageSize) + " OFFSET " + a.add(paginationToken))
+
+	rows, err := p.db.QueryContext(ctx, query.String(), a...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*storage.Candidate
+	for rows.Next() {
+		var candidate storage.Candidate
+		var distance sql.NullFloat64
+		if err := rows.Scan(&candidate.UserID, &distance); err != nil {
+			return nil, err
+		}
+		candidate.DistanceKm = nullFloatPtr(distance)
+		candidates = append(candidates, &candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+func (p *PostgresStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	query := `UPDATE Users SET birthdate = $1, gender = $2, timezone = $3 WHERE id = $4`
+	result, err := p.db.ExecContext(ctx, query, profile.Birthdate.Format(time.DateOnly), int32(profile.Gender), profile.TimeZone, parseId(userId))
+	return userUpdated(result, err)
+}
+
+func (p *PostgresStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	var preferences storage.Preferences
+	query := `SELECT min_age, max_age, genders, intent FROM Preferences WHERE user_id = $1`
+
+	err := p.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&preferences.MinAge, &preferences.MaxAge, &preferences.Genders, &preferences.Intent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &storage.Preferences{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+func (p *PostgresStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	query := `INSERT INTO Preferences (user_id, min_age, max_age, genders, intent) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id) DO UPDATE SET min_age = EXCLUDED.min_age, max_age = EXCLUDED.max_age, genders = EXCLUDED.genders, intent = EXCLUDED.intent`
+	_, err := p.db.ExecContext(ctx, query, parseId(userId), preferences.MinAge, preferences.MaxAge, int64(preferences.Genders), int32(preferences.Intent))
+	if isPgError(err, foreignKeyViolation) {
+		return storage.ErrUserNotFound
+	}
+	return err
+}
+
+func (p *PostgresStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	var settings storage.QuotaSettings
+	var hasEntitlement bool
+	var entitlement storage.Entitlement
+	var dailyLikeLimit sql.NullInt64
+	var expiresAt sql.NullTime
+
+	query := `SELECT u.timezone, e.user_id IS NOT NULL, e.daily_like_limit, e.expires_at FROM Users u LEFT JOIN Entitlements e ON e.user_id = u.id AND (e.expires_at IS NULL OR e.expires_at > $1) WHERE u.id = $2`
+
+	err := p.db.QueryRowContext(ctx, query, time.Now(), parseId(userId)).Scan(&settings.TimeZone, &hasEntitlement, &dailyLikeLimit, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hasEntitlement {
+		if dailyLikeLimit.Valid {
+			limit := int(dailyLikeLimit.Int64)
+			entitlement.DailyLikeLimit = &limit
+		}
+		if expiresAt.Valid {
+			entitlement.ExpiresAt = &expiresAt.Time
+		}
+		settings.Entitlement = &entitlement
+	}
+	return &settings, nil
+}
+
+func (p *PostgresStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	var used int
+	query := `SELECT likes_used FROM DailyLikeQuotas WHERE user_id = $1 AND day = $2::DATE`
+
+	err := p.db.QueryRowContext(ctx, query, parseId(userId), day).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return used, nil
+}
+
+func (p *PostgresStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	//The update is skipped, affecting no rows, when the limit's been reached
+	query := `INSERT INTO DailyLikeQuotas (user_id, day, likes_used) VALUES ($1, $2::DATE, 1) ON CONFLICT (user_id, day) DO UPDATE SET likes_used = DailyLikeQuotas.likes_used + 1 WHERE DailyLikeQuotas.likes_used < $3`
+
+	result, err := p.db.ExecContext(ctx, query, parseId(userId), day, limit)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (p *PostgresStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	query := `UPDATE DailyLikeQuotas SET likes_used = likes_used - 1 WHERE user_id = $1 AND day = $2::DATE AND likes_used > 0`
+	_, err := p.db.ExecContext(ctx, query, parseId(userId), day)
+	return err
+}
+
+func (p *PostgresStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	query := `SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > $1 ORDER BY id LIMIT $2`
+
+	rows, err := p.db.QueryContext(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*storage.Decision
+	for rows.Next() {
+		var decision storage.Decision
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, &decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+func (p *PostgresStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	scores := make(map[int64]*storage.Score)
+	if len(userIds) == 0 {
+		return scores, nil
+	}
+
+	query := `SELECT user_id, score, likes_given, decisions_given, updated_at FROM DesirabilityScores WHERE user_id = ANY($1)`
+
+	rows, err := p.db.QueryContext(ctx, query, userIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s storage.Score
+		if err := rows.Scan(&s.UserID, &s.Score, &s.LikesGiven, &s.DecisionsGiven, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scores[s.UserID] = &s
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+func (p *PostgresStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(scores) > 0 {
+		var a args
+		var values []string
+		for _, s := range scores {
+			values = append(values, "("+a.add(s.UserID)+", "+a.add(s.Score)+", "+a.add(s.LikesGiven)+", "+a.add(s.DecisionsGiven)+", "+a.add(s.UpdatedAt)+")")
+		}
+		query := "INSERT INTO DesirabilityScores (user_id, score, likes_given, decisions_given, updated_at) VALUES " + strings.Join(values, ", ") +
+			" ON CONFLICT (user_id) DO UPDATE SET score = EXCLUDED.score, likes_given = EXCLUDED.likes_given, decisions_given = EXCLUDED.decisions_given, updated_at = EXCLUDED.updated_at"
+
+		if _, err := tx.ExecContext(ctx, query, a...); err != nil {
+			return err
+		}
+	}
+
+	cursorQuery := `INSERT INTO JobCursors (name, last_id) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET last_id = EXCLUDED.last_id`
+	if _, err := tx.ExecContext(ctx, cursorQuery, desirabilityCursor, lastDecisionId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *PostgresStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	var lastId int64
+	query := `SELECT last_id FROM JobCursors WHERE name = $1`
+
+	err := p.db.QueryRowContext(ctx, query, desirabilityCursor).Scan(&lastId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastId, nil
+}
+
+func (p *PostgresStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM Recommendations WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+
+	if len(recommendations) > 0 {
+		var a args
+		var values []string
+		for i, r := range recommendations {
+			values = append(values, "("+a.add(userId)+", "+a.add(r.CandidateID)+", "+a.add(i+1)+", "+a.add(r.Score)+")")
+		}
+		query := "INSERT INTO Recommendations (user_id, candidate_id, ranking, score) VALUES " + strings.Join(values, ", ")
+
+		if _, err := tx.ExecContext(ctx, query, a...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *PostgresStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	//Boosts are stored to the second
+	now := time.Now().Truncate(time.Second)
+	id := parseId(userId)
+
+	//Taking the boost first locks the entitlement, so concurrent activations can't both see no active boost
+	spendQuery := `UPDATE BoostEntitlements SET boosts_remaining = boosts_remaining - 1 WHERE user_id = $1 AND boosts_remaining > 0 AND (expires_at IS NULL OR expires_at > $2)`
+	result, err := tx.ExecContext(ctx, spendQuery, id, now)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, storage.ErrNoBoosts
+	}
+
+	var active bool
+	activeQuery := `SELECT EXISTS (SELECT 1 FROM Boosts WHERE user_id = $1 AND ends_at > $2)`
+	if err := tx.QueryRowContext(ctx, activeQuery, id, now).Scan(&active); err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, storage.ErrBoostActive
+	}
+
+	boost := &storage.Boost{
+		StartsAt: now,
+		EndsAt:   now.Add(duration),
+	}
+	insertQuery := `INSERT INTO Boosts (user_id, starts_at, ends_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, insertQuery, id, boost.StartsAt, boost.EndsAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return boost, nil
+}
+
+func (p *PostgresStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	var boost storage.Boost
+	query := `SELECT b.starts_at, b.ends_at, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = b.user_id AND d.liked = TRUE AND d.created_at >= b.starts_at AND d.created_at < b.ends_at) FROM Boosts b WHERE b.user_id = $1 ORDER BY b.starts_at DESC LIMIT 1`
+
+	err := p.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&boost.StartsAt, &boost.EndsAt, &boost.LikesReceived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrBoostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &boost, nil
+}
+
+func (p *PostgresStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	driftQuery := `SELECT c.id, c.stored, c.actual FROM (SELECT u.id, COALESCE(lc.likes_received, 0) AS stored, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) AS actual FROM Users u LEFT JOIN LikeCounters lc ON lc.user_id = u.id) c WHERE c.stored <> c.actual ORDER BY c.id`
+
+	rows, err := p.db.QueryContext(ctx, driftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drifts []*storage.LikeCounterDrift
+	for rows.Next() {
+		var drift storage.LikeCounterDrift
+		if err := rows.Scan(&drift.UserID, &drift.Stored, &drift.Actual); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, &drift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	//Recounted as each counter is written so likes made since the drift was found aren't lost
+	repairQuery := `INSERT INTO LikeCounters (user_id, likes_received) SELECT $1::BIGINT, COUNT(*) FROM Decisions WHERE recipient_id = $1 AND liked = TRUE ON CONFLICT (user_id) DO UPDATE SET likes_received = EXCLUDED.likes_received`
+	for _, drift := range drifts {
+		if _, err := p.db.ExecContext(ctx, repairQuery, drift.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	return drifts, nil
+}
+
+// parseId converts a user ID to the INT column type. Postgres rejects non-numeric input rather than coercing it like
+// MySQL, so IDs that can't be parsed become 0, which is never assigned, and behave like unknown users.
+func parseId(userId string) int64 {
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// userUpdated returns the error from an UPDATE of a user's row in Users, or ErrUserNotFound if it matched no rows.
+// Postgres counts the rows an UPDATE matches, whether or not their values change.
+func userUpdated(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+func nullFloatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
@@ -0,0 +1,373 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"muzz-project/storage/storagetest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	containerOnce sync.Once
+	container     testcontainers.Container
+	connectionStr string
+	containerErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if container != nil {
+		if err := container.Terminate(context.Background()); err != nil {
+			log.Printf("Failed to terminate Postgres container: %v", err)
+		}
+	}
+	os.Exit(code)
+}
+
+func setupPostgres(ctx context.Context) (testcontainers.Container, string, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:16",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "testuser",
+			"POSTGRES_PASSWORD": "testpassword",
+			"POSTGRES_DB":       "testdb",
+		},
+		//The server restarts once after running the init scripts
+		WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(time.Minute),
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	portObj, err := c.MappedPort(ctx, "5432")
+	if err != nil {
+		return c, "", err
+	}
+
+	host, _ := c.Host(ctx)
+	return c, fmt.Sprintf("postgres://testuser:testpassword@%s:%s/testdb?sslmode=disable", host, portObj.Port()), nil
+}
+
+// skipWithoutDocker skips the test when there's no Docker to run Postgres in. testcontainers panics rather than
+// reporting an unhealthy provider when it can't find a Docker host at all.
+func skipWithoutDocker(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// newPostgresStorage starts the shared container if needed and gives each test an empty schema with users 1 to users
+func newPostgresStorage(t *testing.T, maxPageSize int, users int) (*PostgresStorage, *sql.DB) {
+	skipWithoutDocker(t)
+
+	containerOnce.Do(func() {
+		container, connectionStr, containerErr = setupPostgres(context.Background())
+	})
+	if containerErr != nil {
+		t.Fatalf("Failed to start Postgres container: %v", containerErr)
+	}
+
+	db, err := sql.Open("pgx", connectionStr)
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public;"); err != nil {
+		t.Fatalf("Failed to reset schema: %v", err)
+	}
+	if _, err := db.Exec(Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	for i := 1; i <= users; i++ {
+		if _, err := db.Exec(`INSERT INTO Users (username, first_name, last_name) VALUES ($1, 'First', 'Last')`, fmt.Sprintf("user%d", i)); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+
+	return NewPostgresStorage(db, maxPageSize), db
+}
+
+func TestPostgresStorage_AddDecision(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newPostgresStorage(t, 10, 3)
+
+	tests := []struct {
+		name        string
+		actorId     string
+		recipientId string
+		liked       bool
+		want        bool
+		wantErr     error
+	}{
+		{name: "first like", actorId: "1", recipientId: "2", liked: true, want: false, wantErr: nil},
+		{name: "users match", actorId: "2", recipientId: "1", liked: true, want: true, wantErr: nil},
+		{name: "pass on a user who liked you", actorId: "3", recipientId: "2", liked: false, want: false, wantErr: nil},
+		{name: "like a user who passed", actorId: "2", recipientId: "3", liked: true, want: false, wantErr: nil},
+		{name: "decision already made", actorId: "1", recipientId: "2", liked: false, want: false, wantErr: storage.ErrDuplicateDecision},
+		{name: "unknown recipient", actorId: "1", recipientId: "99", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+		{name: "unknown actor", actorId: "abc", recipientId: "1", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+	}
+	//Decisions build on each other so run in order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.AddDecision(ctx, tt.actorId, tt.recipientId, tt.liked)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+
+	count, err := p.GetLikesCountForUser(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPostgresStorage_GetLikesForUser(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newPostgresStorage(t, 2, 5)
+
+	for _, actorId := range []string{"2", "3", "4", "5"} {
+		_, err := p.AddDecision(ctx, actorId, "1", actorId != "4")
+		assert.NoError(t, err)
+	}
+	_, err := p.AddDecision(ctx, "1", "3", false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.UpdateLocation(ctx, "1", 51.51, -0.13))
+	assert.NoError(t, p.UpdateLocation(ctx, "5", 51.55, -0.10))
+
+	actorIds := func(likes []*storage.Decision) []int64 {
+		var ids []int64
+		for _, l := range likes {
+			ids = append(ids, l.ActorID)
+		}
+		return ids
+	}
+
+	//Newest first, a page of 2 at a time
+	firstPage, err := p.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 3}, actorIds(firstPage))
+	if assert.Len(t, firstPage, 2) && assert.NotNil(t, firstPage[0].DistanceKm) {
+		assert.InDelta(t, 4.9, *firstPage[0].DistanceKm, 0.1)
+		assert.Nil(t, firstPage[1].DistanceKm)
+	}
+
+	secondPage, err := p.GetLikesForUser(ctx, "1", 2, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, actorIds(secondPage))
+
+	newLikes, err := p.GetNewLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 2}, actorIds(newLikes))
+
+	//Only user 2 has the gender user 1 is looking for
+	assert.NoError(t, p.UpdateProfile(ctx, "2", &storage.Profile{Birthdate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: protos.Gender_GENDER_FEMALE}))
+	assert.NoError(t, p.UpdateProfile(ctx, "3", &storage.Profile{Birthdate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Gender: protos.Gender_GENDER_MALE}))
+	assert.NoError(t, p.UpdatePreferences(ctx, "1", &storage.Preferences{Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE)}))
+
+	matching, err := p.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{MatchPreferences: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, actorIds(matching))
+}
+
+func TestPostgresStorage_GetCandidatesForUser(t *testing.T) {
+	ctx := context.Background()
+	p, db := newPostgresStorage(t, 10, 7)
+
+	locations := map[string][2]float64{
+		"1": {51.5074, -0.1278}, // London
+		"2": {51.5500, -0.1000}, // ~5km away
+		"3": {51.5100, -0.1300}, // <1km away
+		"4": {48.8566, 2.3522},  // Paris
+	}
+	for userId, location := range locations {
+		assert.NoError(t, p.UpdateLocation(ctx, userId, location[0], location[1]))
+	}
+	_, err := p.AddDecision(ctx, "1", "7", true)
+	assert.NoError(t, err)
+	assert.NoError(t, p.SaveScores(ctx, []*storage.Score{{UserID: 3, Score: 1400, UpdatedAt: time.Now()}}, 0))
+
+	candidateIds := func(candidates []*storage.Candidate) []int64 {
+		var ids []int64
+		for _, c := range candidates {
+			ids = append(ids, c.UserID)
+		}
+		return ids
+	}
+
+	//2 and 3 are in the same distance band so the more desirable 2 comes first, users without a location come last
+	all, err := p.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5, 6}, candidateIds(all))
+
+	nearby, err := p.GetCandidatesForUser(ctx, "1", 50, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, candidateIds(nearby))
+
+	//Boosted users come first, then recommendations
+	assert.NoError(t, p.SaveRecommendations(ctx, 1, []*storage.Recommendation{{CandidateID: 6}, {CandidateID: 4}}))
+	_, err = db.Exec(`INSERT INTO BoostEntitlements (user_id, boosts_remaining) VALUES (5, 1)`)
+	assert.NoError(t, err)
+	_, err = p.ActivateBoost(ctx, "5", time.Hour)
+	assert.NoError(t, err)
+
+	ranked, err := p.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 6, 4, 2, 3}, candidateIds(ranked))
+
+	secondPage, err := p.GetCandidatesForUser(ctx, "1", 0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, candidateIds(secondPage))
+
+	_, err = p.GetCandidatesForUser(ctx, "5", 50, 0)
+	assert.Equal(t, storage.ErrLocationUnknown, err)
+
+	_, err = p.GetCandidatesForUser(ctx, "99", 0, 0)
+	assert.Equal(t, storage.ErrUserNotFound, err)
+}
+
+func TestPostgresStorage_ConsumeLike(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newPostgresStorage(t, 10, 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := p.ConsumeLike(ctx, "1", "2024-06-01", 5)
+			assert.NoError(t, err)
+			if ok {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, consumed)
+
+	assert.NoError(t, p.RefundLike(ctx, "1", "2024-06-01"))
+	used, err := p.GetLikesUsed(ctx, "1", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, used)
+
+	used, err = p.GetLikesUsed(ctx, "1", "2024-06-02")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, used)
+}
+
+func TestPostgresStorage_GetQuotaSettings(t *testing.T) {
+	ctx := context.Background()
+	p, db := newPostgresStorage(t, 10, 2)
+
+	assert.NoError(t, p.UpdateProfile(ctx, "1", &storage.Profile{TimeZone: "Asia/Tokyo"}))
+	_, err := db.Exec(`INSERT INTO Entitlements (user_id, daily_like_limit) VALUES (1, 50)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO Entitlements (user_id, expires_at) VALUES (2, $1)`, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+
+	limit := 50
+	settings, err := p.GetQuotaSettings(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.QuotaSettings{TimeZone: "Asia/Tokyo", Entitlement: &storage.Entitlement{DailyLikeLimit: &limit}}, settings)
+
+	settings, err = p.GetQuotaSettings(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.QuotaSettings{}, settings)
+
+	_, err = p.GetQuotaSettings(ctx, "3")
+	assert.Equal(t, storage.ErrUserNotFound, err)
+}
+
+func TestPostgresStorage_Boosts(t *testing.T) {
+	ctx := context.Background()
+	p, db := newPostgresStorage(t, 10, 3)
+
+	_, err := p.GetLatestBoost(ctx, "1")
+	assert.Equal(t, storage.ErrBoostNotFound, err)
+
+	_, err = p.ActivateBoost(ctx, "1", time.Hour)
+	assert.Equal(t, storage.ErrNoBoosts, err)
+
+	_, err = db.Exec(`INSERT INTO BoostEntitlements (user_id, boosts_remaining) VALUES (1, 2)`)
+	assert.NoError(t, err)
+	boost, err := p.ActivateBoost(ctx, "1", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, boost.EndsAt.Sub(boost.StartsAt))
+
+	_, err = p.ActivateBoost(ctx, "1", time.Hour)
+	assert.Equal(t, storage.ErrBoostActive, err)
+
+	_, err = p.AddDecision(ctx, "2", "1", true)
+	assert.NoError(t, err)
+	_, err = p.AddDecision(ctx, "3", "1", false)
+	assert.NoError(t, err)
+
+	latest, err := p.GetLatestBoost(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), latest.LikesReceived)
+}
+
+func TestPostgresStorage_Scores(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newPostgresStorage(t, 10, 3)
+
+	for _, recipientId := range []string{"2", "3"} {
+		_, err := p.AddDecision(ctx, "1", recipientId, true)
+		assert.NoError(t, err)
+	}
+	_, err := p.AddDecision(ctx, "2", "3", false)
+	assert.NoError(t, err)
+
+	decisions, err := p.ListDecisionsAfter(ctx, 1, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, int64(2), decisions[0].ID)
+	}
+
+	assert.NoError(t, p.SaveScores(ctx, []*storage.Score{{UserID: 2, Score: 1510, LikesGiven: 0, DecisionsGiven: 1, UpdatedAt: time.Now()}}, 3))
+
+	cursor, err := p.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), cursor)
+
+	scores, err := p.GetScores(ctx, []int64{1, 2})
+	assert.NoError(t, err)
+	if assert.Contains(t, scores, int64(2)) {
+		assert.Equal(t, 1510.0, scores[2].Score)
+		assert.Equal(t, int64(1), scores[2].DecisionsGiven)
+	}
+	assert.NotContains(t, scores, int64(1))
+}
+
+func TestPostgresStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, maxPageSize int, users int) storage.Storage {
+		s, _ := newPostgresStorage(t, maxPageSize, users)
+		return s
+	})
+}
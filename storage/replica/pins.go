@@ -0,0 +1,71 @@
+package replica
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pins records which users have just written. It's an interface so a store shared by every instance, such as Redis,
+// can replace the in-process map, which only pins a user's reads on the instance that served their write.
+type Pins interface {
+	// Set pins userIds for ttl, position is the primary's position after their write, or "" if it isn't known
+	Set(ctx context.Context, userIds []string, position string, ttl time.Duration) error
+	// Get returns userId's position, or false if they aren't pinned or their pin has expired
+	Get(ctx context.Context, userId string) (string, bool, error)
+}
+
+// MemoryPins is an in-process Pins
+type MemoryPins struct {
+	mu        sync.Mutex
+	entries   map[string]pin
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+var _ Pins = (*MemoryPins)(nil)
+
+type pin struct {
+	until    time.Time
+	position string
+}
+
+func NewMemoryPins() *MemoryPins {
+	return &MemoryPins{
+		entries: make(map[string]pin),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryPins) Set(ctx context.Context, userIds []string, position string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for _, userId := range userIds {
+		m.entries[userId] = pin{until: now.Add(ttl), position: position}
+	}
+
+	//Expired pins are only removed by reads, so clear out users who haven't read since writing
+	if now.Sub(m.lastSweep) > ttl {
+		for id, p := range m.entries {
+			if !now.Before(p.until) {
+				delete(m.entries, id)
+			}
+		}
+		m.lastSweep = now
+	}
+	return nil
+}
+
+func (m *MemoryPins) Get(ctx context.Context, userId string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.entries[userId]
+	if ok && !m.now().Before(p.until) {
+		delete(m.entries, userId)
+		return "", false, nil
+	}
+	return p.position, ok, nil
+}
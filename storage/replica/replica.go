@@ -0,0 +1,192 @@
+// Package replica splits reads across read replicas while sending writes to the primary. A user who has just written
+// reads from the primary for a while afterwards, so they see their own writes, for example a match that has just
+// formed, even while the replicas are behind. That only holds across instances if they share their Pins.
+package replica
+
+import (
+	"context"
+	"muzz-project/logging"
+	"muzz-project/storage"
+	"sync/atomic"
+	"time"
+)
+
+const defaultPinWindow = 5 * time.Second
+
+// Positions reads replication positions, letting a user's reads go back to a replica as soon as it has applied their
+// writes instead of waiting for the pin window to pass
+type Positions interface {
+	// Primary returns the primary's current position, or "" if it isn't known
+	Primary(ctx context.Context) (string, error)
+	// Reached reports whether replica, an index into the replicas, has applied everything up to position
+	Reached(ctx context.Context, replica int, position string) (bool, error)
+}
+
+// ReplicatedStorage sends the reads users make while browsing to the replicas, and everything else, including the
+// reads of background jobs that have to see their own writes, to the primary
+type ReplicatedStorage struct {
+	storage.Storage
+	replicas  []storage.Storage
+	positions Positions
+	pins      Pins
+	pinWindow time.Duration
+	next      atomic.Uint64
+}
+
+var _ storage.Storage = (*ReplicatedStorage)(nil)
+
+type Option func(*ReplicatedStorage)
+
+// WithPinWindow sets how long a user's reads go to the primary after they write
+func WithPinWindow(window time.Duration) Option {
+	return func(r *ReplicatedStorage) {
+		r.pinWindow = window
+	}
+}
+
+// WithPins sets where pins are kept, by default it's in process
+func WithPins(pins Pins) Option {
+	return func(r *ReplicatedStorage) {
+		r.pins = pins
+	}
+}
+
+// WithPositions lets pinned reads go to a replica once it has caught up with the user's last write
+func WithPositions(positions Positions) Option {
+	return func(r *ReplicatedStorage) {
+		r.positions = positions
+	}
+}
+
+// NewReplicatedStorage returns a storage reading from replicas, which must be storages of the same kind as primary
+// reading from its replicas. With no replicas everything goes to primary.
+func NewReplicatedStorage(primary storage.Storage, replicas []storage.Storage, opts ...Option) *ReplicatedStorage {
+	r := &ReplicatedStorage{
+		Storage:   primary,
+		replicas:  replicas,
+		pins:      NewMemoryPins(),
+		pinWindow: defaultPinWindow,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *ReplicatedStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return r.reader(ctx, userId).GetLikesForUser(ctx, userId, paginationToken, filter)
+}
+
+func (r *ReplicatedStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return r.reader(ctx, userId).GetNewLikesForUser(ctx, userId, paginationToken, filter)
+}
+
+func (r *ReplicatedStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	return r.reader(ctx, userId).GetLikesCountForUser(ctx, userId)
+}
+
+func (r *ReplicatedStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	return r.reader(ctx, userId).GetCandidatesForUser(ctx, userId, maxDistanceKm, paginationToken)
+}
+
+func (r *ReplicatedStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	return r.reader(ctx, userId).GetPreferences(ctx, userId)
+}
+
+func (r *ReplicatedStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	return r.reader(ctx, userId).GetQuotaSettings(ctx, userId)
+}
+
+func (r *ReplicatedStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	return r.reader(ctx, userId).GetLikesUsed(ctx, userId, day)
+}
+
+func (r *ReplicatedStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	return r.reader(ctx, userId).GetLatestBoost(ctx, userId)
+}
+
+func (r *ReplicatedStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	reciprocal, err := r.Storage.AddDecision(ctx, actorId, recipientId, liked)
+	//The recipient is pinned too so their like count, which may be cached from the next read, includes the like
+	r.pin(ctx, actorId, recipientId)
+	return reciprocal, err
+}
+
+func (r *ReplicatedStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	err := r.Storage.UpdateLocation(ctx, userId, latitude, longitude)
+	r.pin(ctx, userId)
+	return err
+}
+
+func (r *ReplicatedStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	err := r.Storage.UpdateProfile(ctx, userId, profile)
+	r.pin(ctx, userId)
+	return err
+}
+
+func (r *ReplicatedStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	err := r.Storage.UpdatePreferences(ctx, userId, preferences)
+	r.pin(ctx, userId)
+	return err
+}
+
+func (r *ReplicatedStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	consumed, err := r.Storage.ConsumeLike(ctx, userId, day, limit)
+	r.pin(ctx, userId)
+	return consumed, err
+}
+
+func (r *ReplicatedStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	err := r.Storage.RefundLike(ctx, userId, day)
+	r.pin(ctx, userId)
+	return err
+}
+
+func (r *ReplicatedStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	boost, err := r.Storage.ActivateBoost(ctx, userId, duration)
+	r.pin(ctx, userId)
+	return boost, err
+}
+
+// pin sends userIds' reads to the primary until the pin window passes. It's done even if the write failed, as it may
+// still have been committed.
+func (r *ReplicatedStorage) pin(ctx context.Context, userIds ...string) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	var position string
+	if r.positions != nil {
+		//Without a position reads stay on the primary for the whole window
+		position, _ = r.positions.Primary(ctx)
+	}
+
+	if err := r.pins.Set(ctx, userIds, position, r.pinWindow); err != nil {
+		//The users' next reads may go to a replica that hasn't applied their write yet
+		logging.FromContext(ctx).Warn("failed to pin reads to the primary", "error", err)
+	}
+}
+
+// reader returns the storage userId's reads should go to
+func (r *ReplicatedStorage) reader(ctx context.Context, userId string) storage.Storage {
+	if len(r.replicas) == 0 {
+		return r.Storage
+	}
+	replica := int(r.next.Add(1) % uint64(len(r.replicas)))
+
+	position, pinned, err := r.pins.Get(ctx, userId)
+	if err != nil {
+		//Not knowing whether the user has just written, the primary is the only safe choice
+		logging.FromContext(ctx).Debug("failed to read pin", "error", err)
+		return r.Storage
+	}
+	if !pinned {
+		return r.replicas[replica]
+	}
+	if r.positions != nil && position != "" {
+		if reached, err := r.positions.Reached(ctx, replica, position); err == nil && reached {
+			return r.replicas[replica]
+		}
+	}
+	return r.Storage
+}
@@ -0,0 +1,155 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/storage"
+	storageMock "muzz-project/storage/mocks"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePositions has a primary at position "2" and replicas that have reached whatever's in replicaPositions
+type fakePositions struct {
+	replicaPositions []string
+}
+
+func (f *fakePositions) Primary(ctx context.Context) (string, error) {
+	return "2", nil
+}
+
+func (f *fakePositions) Reached(ctx context.Context, replica int, position string) (bool, error) {
+	if f.replicaPositions[replica] == "" {
+		return false, fmt.Errorf("replica unavailable")
+	}
+	return f.replicaPositions[replica] >= position, nil
+}
+
+// failingPins is a pin store that's always unavailable
+type failingPins struct{}
+
+func (failingPins) Set(ctx context.Context, userIds []string, position string, ttl time.Duration) error {
+	return fmt.Errorf("pins unavailable")
+}
+
+func (failingPins) Get(ctx context.Context, userId string) (string, bool, error) {
+	return "", false, fmt.Errorf("pins unavailable")
+}
+
+func TestReplicatedStorage_Routing(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	primary := storageMock.NewMockStorage(mockCtrl)
+	replica1 := storageMock.NewMockStorage(mockCtrl)
+	replica2 := storageMock.NewMockStorage(mockCtrl)
+	r := NewReplicatedStorage(primary, []storage.Storage{replica1, replica2})
+
+	//Reads are spread over the replicas
+	replica1.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(1).Return(int64(1), nil)
+	replica2.EXPECT().GetLikesCountForUser(gomock.Any(), "1").Times(1).Return(int64(1), nil)
+	for i := 0; i < 2; i++ {
+		_, err := r.GetLikesCountForUser(ctx, "1")
+		assert.NoError(t, err)
+	}
+
+	//Writes, and reads that aren't made while browsing, go to the primary
+	primary.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(false, nil)
+	primary.EXPECT().GetScoreCursor(gomock.Any()).Times(1).Return(int64(0), nil)
+	_, err := r.AddDecision(ctx, "1", "2", true)
+	assert.NoError(t, err)
+	_, err = r.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+}
+
+func TestReplicatedStorage_PinWindow(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	primary := storageMock.NewMockStorage(mockCtrl)
+	replica := storageMock.NewMockStorage(mockCtrl)
+	pins := NewMemoryPins()
+	r := NewReplicatedStorage(primary, []storage.Storage{replica}, WithPinWindow(time.Second), WithPins(pins))
+	now := time.Now()
+	pins.now = func() time.Time { return now }
+
+	primary.EXPECT().AddDecision(gomock.Any(), "1", "2", true).Times(1).Return(true, nil)
+	_, err := r.AddDecision(ctx, "1", "2", true)
+	assert.NoError(t, err)
+
+	//The actor reads their own write, users who weren't part of it still read from the replica
+	primary.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return(nil, nil)
+	replica.EXPECT().GetLikesForUser(gomock.Any(), "3", 0, storage.LikesFilter{}).Times(1).Return(nil, nil)
+	_, err = r.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	_, err = r.GetLikesForUser(ctx, "3", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+
+	now = now.Add(time.Second)
+	replica.EXPECT().GetLikesForUser(gomock.Any(), "1", 0, storage.LikesFilter{}).Times(1).Return(nil, nil)
+	_, err = r.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.NotContains(t, pins.entries, "1")
+}
+
+func TestReplicatedStorage_Positions(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	primary := storageMock.NewMockStorage(mockCtrl)
+	behind := storageMock.NewMockStorage(mockCtrl)
+	caughtUp := storageMock.NewMockStorage(mockCtrl)
+	unavailable := storageMock.NewMockStorage(mockCtrl)
+	positions := &fakePositions{replicaPositions: []string{"1", "2", ""}}
+	r := NewReplicatedStorage(primary, []storage.Storage{behind, caughtUp, unavailable}, WithPinWindow(time.Minute), WithPositions(positions))
+
+	primary.EXPECT().UpdatePreferences(gomock.Any(), "1", gomock.Any()).Times(1).Return(nil)
+	assert.NoError(t, r.UpdatePreferences(ctx, "1", &storage.Preferences{}))
+
+	//Only the replica that has applied the write is read from, otherwise the primary is
+	caughtUp.EXPECT().GetPreferences(gomock.Any(), "1").Times(1).Return(&storage.Preferences{}, nil)
+	primary.EXPECT().GetPreferences(gomock.Any(), "1").Times(2).Return(&storage.Preferences{}, nil)
+	for i := 0; i < 3; i++ {
+		_, err := r.GetPreferences(ctx, "1")
+		assert.NoError(t, err)
+	}
+}
+
+func TestReplicatedStorage_NoReplicas(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	primary := storageMock.NewMockStorage(mockCtrl)
+	pins := NewMemoryPins()
+	r := NewReplicatedStorage(primary, nil, WithPins(pins))
+
+	primary.EXPECT().UpdateLocation(gomock.Any(), "1", 1.0, 2.0).Times(1).Return(nil)
+	primary.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 10.0, 0).Times(1).Return(nil, nil)
+	assert.NoError(t, r.UpdateLocation(context.Background(), "1", 1.0, 2.0))
+	_, err := r.GetCandidatesForUser(context.Background(), "1", 10.0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, pins.entries)
+}
+
+func TestReplicatedStorage_PinsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	primary := storageMock.NewMockStorage(mockCtrl)
+	replica := storageMock.NewMockStorage(mockCtrl)
+	r := NewReplicatedStorage(primary, []storage.Storage{replica}, WithPins(failingPins{}))
+
+	//Writes still succeed, and reads go to the primary since the user might have just written
+	primary.EXPECT().UpdateLocation(gomock.Any(), "1", 1.0, 2.0).Times(1).Return(nil)
+	primary.EXPECT().GetCandidatesForUser(gomock.Any(), "1", 10.0, 0).Times(1).Return(nil, nil)
+	assert.NoError(t, r.UpdateLocation(ctx, "1", 1.0, 2.0))
+	_, err := r.GetCandidatesForUser(ctx, "1", 10.0, 0)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,93 @@
+CREATE TABLE IF NOT EXISTS Users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    first_name VARCHAR(50) NOT NULL,
+    last_name VARCHAR(50) NOT NULL,
+    birthdate DATE NULL,
+    gender INTEGER NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    latitude REAL NULL,
+    longitude REAL NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_Users_location ON Users (latitude, longitude);
+
+CREATE TABLE IF NOT EXISTS Decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    recipient_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    liked BOOLEAN NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_Decisions UNIQUE (actor_id, recipient_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_Decisions_recipient ON Decisions (recipient_id, created_at);
+
+CREATE TABLE IF NOT EXISTS Preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES Users(id) ON DELETE CASCADE,
+    min_age INTEGER NOT NULL DEFAULT 0,
+    max_age INTEGER NOT NULL DEFAULT 0,
+    genders INTEGER NOT NULL DEFAULT 0,
+    intent INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS Entitlements (
+    user_id INTEGER PRIMARY KEY REFERENCES Users(id) ON DELETE CASCADE,
+    daily_like_limit INTEGER NULL,
+    expires_at DATETIME NULL
+);
+
+CREATE TABLE IF NOT EXISTS DailyLikeQuotas (
+    user_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    likes_used INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS DesirabilityScores (
+    user_id INTEGER PRIMARY KEY REFERENCES Users(id) ON DELETE CASCADE,
+    score REAL NOT NULL,
+    likes_given INTEGER NOT NULL DEFAULT 0,
+    decisions_given INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS JobCursors (
+    name VARCHAR(64) PRIMARY KEY,
+    last_id INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Recommendations (
+    user_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    candidate_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    ranking INTEGER NOT NULL,
+    score REAL NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, candidate_id)
+);
+
+CREATE TABLE IF NOT EXISTS BoostEntitlements (
+    user_id INTEGER PRIMARY KEY REFERENCES Users(id) ON DELETE CASCADE,
+    boosts_remaining INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NULL
+);
+
+CREATE TABLE IF NOT EXISTS Boosts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_Boosts_user_ends ON Boosts (user_id, ends_at);
+
+CREATE TABLE IF NOT EXISTS LikeCounters (
+    user_id INTEGER PRIMARY KEY REFERENCES Users(id) ON DELETE CASCADE,
+    likes_received INTEGER NOT NULL DEFAULT 0
+);
+
+-- Counts likes made before the counters existed, users who already have a counter are left alone
+INSERT INTO LikeCounters (user_id, likes_received)
+SELECT recipient_id, COUNT(*) FROM Decisions WHERE liked = TRUE GROUP BY recipient_id
+ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,640 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"muzz-project/geo"
+	"muzz-project/score"
+	"muzz-project/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Schema creates every table SqliteStorage uses, it's safe to run more than once
+//
+//go:embed schema.sql
+var Schema string
+
+// distanceBandKm groups candidates by distance so more desirable users can come first among those similarly close
+const distanceBandKm = 10
+
+const desirabilityCursor = "desirability"
+
+// pragmas are set on every connection. Foreign keys are off by default in SQLite, and the busy timeout waits for
+// other processes' writes rather than failing straight away.
+var pragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+type SqliteStorage struct {
+	db          *sql.DB
+	maxPageSize int
+}
+
+var _ storage.Storage = (*SqliteStorage)(nil)
+
+func NewSqliteStorage(db *sql.DB, maxPageSize int) *SqliteStorage {
+	return &SqliteStorage{
+		db:          db,
+		maxPageSize: maxPageSize,
+	}
+}
+
+// Open opens the database file at dsn, creating it and its schema if they don't exist yet.
+func Open(dsn string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	//Times are stored as text in SQLite's own format so they compare correctly with CURRENT_TIMESTAMP
+	dsn += separator + "_time_format=sqlite"
+	for _, pragma := range pragmas {
+		dsn += "&_pragma=" + pragma
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	//SQLite allows one writer at a time, sharing a single connection queues writers instead of failing them with
+	//SQLITE_BUSY. It also means an in-memory database is the same database for every query.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(Schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *SqliteStorage) GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return s.getLikes(ctx, userId, "", filter, paginationToken)
+}
+
+func (s *SqliteStorage) GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter storage.LikesFilter) ([]*storage.Decision, error) {
+	return s.getLikes(ctx, userId, " AND NOT EXISTS (SELECT 1 FROM Decisions d2 WHERE d2.actor_id = d.recipient_id AND d2.recipient_id = d.actor_id)", filter, paginationToken)
+}
+
+func (s *SqliteStorage) getLikes(ctx context.Context, userId string, condition string, filter storage.LikesFilter, paginationToken int) ([]*storage.Decision, error) {
+	var query strings.Builder
+
+	query.WriteString("SELECT d.id, d.actor_id, d.recipient_id, d.liked, d.created_at, " + distanceKm("a", "r") + " AS distance_km FROM Decisions d JOIN Users a ON a.id = d.actor_id JOIN Users r ON r.id = d.recipient_id")
+	if filter.MatchPreferences {
+		query.WriteString(" LEFT JOIN Preferences ap ON ap.user_id = a.id LEFT JOIN Preferences rp ON rp.user_id = r.id")
+	}
+	query.WriteString(" WHERE d.recipient_id = ? AND d.liked = TRUE")
+	query.WriteString(condition)
+	if filter.MatchPreferences {
+		query.WriteString(" AND " + matchesPreferences("rp", "a", "ap"))
+	}
+	query.WriteString(" ORDER BY d.created_at DESC, d.id DESC LIMIT ? OFFSET ?")
+
+	rows, err := s.db.QueryContext(ctx, query.String(), parseId(userId), s.maxPageSize, paginationToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*storage.Decision
+	for rows.Next() {
+		var decision storage.Decision
+		var distance sql.NullFloat64
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt, &distance); err != nil {
+			return nil, err
+		}
+		decision.DistanceKm = nullFloatPtr(distance)
+		decisions = append(decisions, &decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// distanceKm is the haversine distance between users a and b, NULL if either has no location. It uses the same
+// radius as MySQL's ST_Distance_Sphere so distances agree across backends.
+func distanceKm(a, b string) string {
+	return strings.NewReplacer("{a}", a, "{b}", b).Replace(fmt.Sprintf(
+		"%g * 2 * ASIN(MIN(1, SQRT(POWER(SIN(RADIANS({b}.latitude - {a}.latitude) / 2), 2)"+
+			" + COS(RADIANS({a}.latitude)) * COS(RADIANS({b}.latitude)) * POWER(SIN(RADIANS({b}.longitude - {a}.longitude) / 2), 2))))", geo.EarthRadiusKm))
+}
+
+// age is the whole number of years since user's birthdate, SQLite has no date difference function
+func age(user string) string {
+	return strings.ReplaceAll("(CAST(strftime('%Y', 'now') AS INTEGER) - CAST(strftime('%Y', {user}.birthdate) AS INTEGER)"+
+		" - (strftime('%m-%d', 'now') < strftime('%m-%d', {user}.birthdate)))", "{user}", user)
+}
+
+// matchesPreferences is a condition that is true when other fits userPrefs. userPrefs and otherPrefs are LEFT JOINed
+// Preferences rows so a missing row, like a zero value, means no restriction. Users who haven't set a birthdate or
+// gender never match a restriction on it.
+func matchesPreferences(userPrefs, other, otherPrefs string) string {
+	return strings.NewReplacer("{userPrefs}", userPrefs, "{other}", other, "{otherPrefs}", otherPrefs, "{age}", age(other)).Replace(
+		"(COALESCE({userPrefs}.min_age, 0) = 0 OR {age} >= {userPrefs}.min_age)" +
+			" AND (COALESCE({userPrefs}.max_age, 0) = 0 OR {age} <= {userPrefs}.max_age)" +
+			" AND (COALESCE({userPrefs}.genders, 0) = 0 OR ({userPrefs}.genders & (1 << {other}.gender)) <> 0)" +
+			" AND (COALESCE({userPrefs}.intent, 0) = 0 OR COALESCE({otherPrefs}.intent, 0) = 0 OR {userPrefs}.intent = {otherPrefs}.intent)")
+}
+
+func (s *SqliteStorage) GetLikesCountForUser(ctx context.Context, userId string) (int64, error) {
+	var count int64
+	query := `SELECT likes_received FROM LikeCounters WHERE user_id = ?`
+
+	err := s.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *SqliteStorage) AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	//Check to see if recipient has already liked actor - if so it's a match!
+	reciprocal := false
+
+	if liked {
+		matchQuery := `SELECT EXISTS (SELECT 1 FROM Decisions WHERE recipient_id = ? AND actor_id = ? AND liked = TRUE)`
+		if err := tx.QueryRowContext(ctx, matchQuery, parseId(actorId), parseId(recipientId)).Scan(&reciprocal); err != nil {
+			return false, err
+		}
+	}
+
+	query := `INSERT INTO Decisions (actor_id, recipient_id, liked, created_at) VALUES (?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, parseId(actorId), parseId(recipientId), liked, now())
+	if isSqliteError(err, sqlite3.SQLITE_CONSTRAINT_UNIQUE) {
+		return false, storage.ErrDuplicateDecision
+	}
+	if isSqliteError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+		return false, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if liked {
+		counterQuery := `INSERT INTO LikeCounters (user_id, likes_received) VALUES (?, 1) ON CONFLICT (user_id) DO UPDATE SET likes_received = LikeCounters.likes_received + 1`
+		if _, err := tx.ExecContext(ctx, counterQuery, parseId(recipientId)); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return reciprocal, nil
+}
+
+func (s *SqliteStorage) UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error {
+	query := `UPDATE Users SET latitude = ?, longitude = ? WHERE id = ?`
+	result, err := s.db.ExecContext(ctx, query, latitude, longitude, parseId(userId))
+	return userUpdated(result, err)
+}
+
+func (s *SqliteStorage) GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*storage.Candidate, error) {
+	var latitude, longitude sql.NullFloat64
+
+	err := s.db.QueryRowContext(ctx, `SELECT latitude, longitude FROM Users WHERE id = ?`, parseId(userId)).Scan(&latitude, &longitude)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var query strings.Builder
+	t := now()
+	args := []any{parseId(userId), t, t}
+
+	//The ranking is worked out in a subquery so the distance is only calculated once per user
+	query.WriteString("SELECT c.id, c.distance_km FROM (SELECT u.id, " + distanceKm("u", "me") + " AS distance_km, b.user_id IS NOT NULL AS boosted, rec.ranking, COALESCE(ds.score, " + fmt.Sprintf("%g", score.InitialScore) + ") AS score")
+	query.WriteString(" FROM Users u JOIN Users me ON me.id = ? LEFT JOIN Preferences up ON up.user_id = u.id LEFT JOIN Preferences mp ON mp.user_id = me.id LEFT JOIN DesirabilityScores ds ON ds.user_id = u.id LEFT JOIN Recommendations rec ON rec.user_id = me.id AND rec.candidate_id = u.id LEFT JOIN Boosts b ON b.user_id = u.id AND b.starts_at <= ? AND b.ends_at > ?")
+	query.WriteString(" WHERE u.id <> me.id AND NOT EXISTS (SELECT 1 FROM Decisions d WHERE d.actor_id = me.id AND d.recipient_id = u.id)")
+	query.WriteString(" AND " + matchesPreferences("mp", "u", "up"))
+	query.WriteString(" AND " + matchesPreferences("up", "me", "mp"))
+
+	if maxDistanceKm > 0 {
+		if !latitude.Valid || !longitude.Valid {
+			return nil, storage.ErrLocationUnknown
+		}
+
+		//Bounding box lets the location index discard most users before the exact distance is calculated
+		box := geo.NewBoundingBox(latitude.Float64, longitude.Float64, maxDistanceKm)
+		query.WriteString(" AND u.latitude BETWEEN ? AND ?")
+		args = append(args, box.MinLatitude, box.MaxLatitude)
+		if box.CrossesAntimeridian() {
+			query.WriteString(" AND (u.longitude >= ? OR u.longitude <= ?)")
+		} else {
+			query.WriteString(" AND u.longitude BETWEEN ? AND ?")
+		}
+		args = append(args, box.MinLongitude, box.MaxLongitude)
+		query.WriteString(") c WHERE c.distance_km <= ?")
+		args = append(args, maxDistanceKm)
+	} else {
+		query.WriteString(") c")
+	}
+
+	//Boosted users come first, then recommended users, then nearby users ranked by desirability within each distance band
+	fmt.Fprintf(&query, " ORDER BY c.boosted DESC, c.ranking IS NULL, c.ranking, c.distance_km IS NULL, CAST(c.distance_km / %d AS INTEGER), c.score DESC, c.id", distanceBandKm)
+	query.WriteString(" LIMIT ? OFFSET ?")
+	args = append(args, s.maxPageSize, paginationToken)
+
+	rows, err := s.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*storage.Candidate
+	for rows.Next() {
+		var candidate storage.Candidate
+		var distance sql.NullFloat64
+		if err := rows.Scan(&candidate.UserID, &distance); err != nil {
+			return nil, err
+		}
+		candidate.DistanceKm = nullFloatPtr(distance)
+		candidates = append(candidates, &candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+func (s *SqliteStorage) UpdateProfile(ctx context.Context, userId string, profile *storage.Profile) error {
+	query := `UPDATE Users SET birthdate = ?, gender = ?, timezone = ? WHERE id = ?`
+	result, err := s.db.ExecContext(ctx, query, profile.Birthdate.Format(time.DateOnly), int32(profile.Gender), profile.TimeZone, parseId(userId))
+	return userUpdated(result, err)
+}
+
+func (s *SqliteStorage) GetPreferences(ctx context.Context, userId string) (*storage.Preferences, error) {
+	var preferences storage.Preferences
+	query := `SELECT min_age, max_age, genders, intent FROM Preferences WHERE user_id = ?`
+
+	err := s.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&preferences.MinAge, &preferences.MaxAge, &preferences.Genders, &preferences.Intent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &storage.Preferences{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+func (s *SqliteStorage) UpdatePreferences(ctx context.Context, userId string, preferences *storage.Preferences) error {
+	query := `INSERT INTO Preferences (user_id, min_age, max_age, genders, intent) VALUES (?, ?, ?, ?, ?) ON CONFLICT (user_id) DO UPDATE SET min_age = excluded.min_age, max_age = excluded.max_age, genders = excluded.genders, intent = excluded.intent`
+	_, err := s.db.ExecContext(ctx, query, parseId(userId), preferences.MinAge, preferences.MaxAge, int64(preferences.Genders), int32(preferences.Intent))
+	if isSqliteError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+		return storage.ErrUserNotFound
+	}
+	return err
+}
+
+func (s *SqliteStorage) GetQuotaSettings(ctx context.Context, userId string) (*storage.QuotaSettings, error) {
+	var settings storage.QuotaSettings
+	var hasEntitlement bool
+	var entitlement storage.Entitlement
+	var dailyLikeLimit sql.NullInt64
+	var expiresAt sql.NullTime
+
+	query := `SELECT u.timezone, e.user_id IS NOT NULL, e.daily_like_limit, e.expires_at FROM Users u LEFT JOIN Entitlements e ON e.user_id = u.id AND (e.expires_at IS NULL OR e.expires_at > ?) WHERE u.id = ?`
+
+	err := s.db.QueryRowContext(ctx, query, now(), parseId(userId)).Scan(&settings.TimeZone, &hasEntitlement, &dailyLikeLimit, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hasEntitlement {
+		if dailyLikeLimit.Valid {
+			limit := int(dailyLikeLimit.Int64)
+			entitlement.DailyLikeLimit = &limit
+		}
+		if expiresAt.Valid {
+			entitlement.ExpiresAt = &expiresAt.Time
+		}
+		settings.Entitlement = &entitlement
+	}
+	return &settings, nil
+}
+
+func (s *SqliteStorage) GetLikesUsed(ctx context.Context, userId string, day string) (int, error) {
+	var used int
+	query := `SELECT likes_used FROM DailyLikeQuotas WHERE user_id = ? AND day = ?`
+
+	err := s.db.QueryRowContext(ctx, query, parseId(userId), day).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return used, nil
+}
+
+func (s *SqliteStorage) ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error) {
+	//The update is skipped, changing no rows, when the limit's been reached
+	query := `INSERT INTO DailyLikeQuotas (user_id, day, likes_used) VALUES (?, ?, 1) ON CONFLICT (user_id, day) DO UPDATE SET likes_used = likes_used + 1 WHERE likes_used < ?`
+
+	result, err := s.db.ExecContext(ctx, query, parseId(userId), day, limit)
+	if isSqliteError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+		return false, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *SqliteStorage) RefundLike(ctx context.Context, userId string, day string) error {
+	query := `UPDATE DailyLikeQuotas SET likes_used = likes_used - 1 WHERE user_id = ? AND day = ? AND likes_used > 0`
+	_, err := s.db.ExecContext(ctx, query, parseId(userId), day)
+	return err
+}
+
+func (s *SqliteStorage) ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*storage.Decision, error) {
+	query := `SELECT id, actor_id, recipient_id, liked, created_at FROM Decisions WHERE id > ? ORDER BY id LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*storage.Decision
+	for rows.Next() {
+		var decision storage.Decision
+		if err := rows.Scan(&decision.ID, &decision.ActorID, &decision.RecipientID, &decision.Liked, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, &decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+func (s *SqliteStorage) GetScores(ctx context.Context, userIds []int64) (map[int64]*storage.Score, error) {
+	scores := make(map[int64]*storage.Score)
+	if len(userIds) == 0 {
+		return scores, nil
+	}
+
+	args := make([]any, len(userIds))
+	for i, id := range userIds {
+		args[i] = id
+	}
+	query := "SELECT user_id, score, likes_given, decisions_given, updated_at FROM DesirabilityScores WHERE user_id IN (?" + strings.Repeat(", ?", len(userIds)-1) + ")"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sc storage.Score
+		if err := rows.Scan(&sc.UserID, &sc.Score, &sc.LikesGiven, &sc.DecisionsGiven, &sc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scores[sc.UserID] = &sc
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+func (s *SqliteStorage) SaveScores(ctx context.Context, scores []*storage.Score, lastDecisionId int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(scores) > 0 {
+		var args []any
+		for _, sc := range scores {
+			args = append(args, sc.UserID, sc.Score, sc.LikesGiven, sc.DecisionsGiven, sc.UpdatedAt.UTC())
+		}
+		query := "INSERT INTO DesirabilityScores (user_id, score, likes_given, decisions_given, updated_at) VALUES (?, ?, ?, ?, ?)" + strings.Repeat(", (?, ?, ?, ?, ?)", len(scores)-1) +
+			" ON CONFLICT (user_id) DO UPDATE SET score = excluded.score, likes_given = excluded.likes_given, decisions_given = excluded.decisions_given, updated_at = excluded.updated_at"
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	cursorQuery := `INSERT INTO JobCursors (name, last_id) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET last_id = excluded.last_id`
+	if _, err := tx.ExecContext(ctx, cursorQuery, desirabilityCursor, lastDecisionId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqliteStorage) GetScoreCursor(ctx context.Context) (int64, error) {
+	var lastId int64
+	query := `SELECT last_id FROM JobCursors WHERE name = ?`
+
+	err := s.db.QueryRowContext(ctx, query, desirabilityCursor).Scan(&lastId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastId, nil
+}
+
+func (s *SqliteStorage) SaveRecommendations(ctx context.Context, userId int64, recommendations []*storage.Recommendation) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM Recommendations WHERE user_id = ?`, userId); err != nil {
+		return err
+	}
+
+	if len(recommendations) > 0 {
+		var args []any
+		for i, r := range recommendations {
+			args = append(args, userId, r.CandidateID, i+1, r.Score)
+		}
+		query := "INSERT INTO Recommendations (user_id, candidate_id, ranking, score) VALUES (?, ?, ?, ?)" + strings.Repeat(", (?, ?, ?, ?)", len(recommendations)-1)
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqliteStorage) ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*storage.Boost, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	//Boosts are stored to the second
+	t := now().Truncate(time.Second)
+	id := parseId(userId)
+
+	spendQuery := `UPDATE BoostEntitlements SET boosts_remaining = boosts_remaining - 1 WHERE user_id = ? AND boosts_remaining > 0 AND (expires_at IS NULL OR expires_at > ?)`
+	result, err := tx.ExecContext(ctx, spendQuery, id, t)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, storage.ErrNoBoosts
+	}
+
+	var active bool
+	activeQuery := `SELECT EXISTS (SELECT 1 FROM Boosts WHERE user_id = ? AND ends_at > ?)`
+	if err := tx.QueryRowContext(ctx, activeQuery, id, t).Scan(&active); err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, storage.ErrBoostActive
+	}
+
+	boost := &storage.Boost{
+		StartsAt: t,
+		EndsAt:   t.Add(duration),
+	}
+	insertQuery := `INSERT INTO Boosts (user_id, starts_at, ends_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, insertQuery, id, boost.StartsAt, boost.EndsAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return boost, nil
+}
+
+func (s *SqliteStorage) GetLatestBoost(ctx context.Context, userId string) (*storage.Boost, error) {
+	var boost storage.Boost
+	query := `SELECT b.starts_at, b.ends_at, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = b.user_id AND d.liked = TRUE AND d.created_at >= b.starts_at AND d.created_at < b.ends_at) FROM Boosts b WHERE b.user_id = ? ORDER BY b.starts_at DESC LIMIT 1`
+
+	err := s.db.QueryRowContext(ctx, query, parseId(userId)).Scan(&boost.StartsAt, &boost.EndsAt, &boost.LikesReceived)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrBoostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &boost, nil
+}
+
+// now is the current time in UTC. Times are compared as text in SQLite, which only works if they share an offset.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func (s *SqliteStorage) RepairLikeCounters(ctx context.Context) ([]*storage.LikeCounterDrift, error) {
+	driftQuery := `SELECT c.id, c.stored, c.actual FROM (SELECT u.id, COALESCE(lc.likes_received, 0) AS stored, (SELECT COUNT(*) FROM Decisions d WHERE d.recipient_id = u.id AND d.liked = TRUE) AS actual FROM Users u LEFT JOIN LikeCounters lc ON lc.user_id = u.id) c WHERE c.stored <> c.actual ORDER BY c.id`
+
+	rows, err := s.db.QueryContext(ctx, driftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drifts []*storage.LikeCounterDrift
+	for rows.Next() {
+		var drift storage.LikeCounterDrift
+		if err := rows.Scan(&drift.UserID, &drift.Stored, &drift.Actual); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, &drift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	//Recounted as each counter is written so likes made since the drift was found aren't lost
+	repairQuery := `INSERT INTO LikeCounters (user_id, likes_received) SELECT ?, COUNT(*) FROM Decisions WHERE recipient_id = ? AND liked = TRUE ON CONFLICT (user_id) DO UPDATE SET likes_received = excluded.likes_received`
+	for _, drift := range drifts {
+		if _, err := s.db.ExecContext(ctx, repairQuery, drift.UserID, drift.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	return drifts, nil
+}
+
+// parseId converts a user ID to an integer. SQLite would otherwise store and compare a non-numeric ID as text, IDs
+// that can't be parsed become 0, which is never assigned, and behave like unknown users.
+func parseId(userId string) int64 {
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// userUpdated returns the error from an UPDATE of a user's row in Users, or ErrUserNotFound if it matched no rows.
+// SQLite counts the rows an UPDATE matches, whether or not their values change.
+func userUpdated(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func isSqliteError(err error, code int) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == code
+}
+
+func nullFloatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
@@ -3,15 +3,75 @@ package storage
 import (
 	"context"
 	"fmt"
+	"muzz-project/geo"
 	"muzz-project/service/protos"
+	"sort"
 	"time"
 )
 
+var (
+	ErrUserNotFound        = fmt.Errorf("user not found")
+	ErrLocationUnknown     = fmt.Errorf("user has no location")
+	ErrDuplicateDecision   = fmt.Errorf("decision already made")
+	ErrDecisionPartlySaved = fmt.Errorf("decision partly saved")
+	ErrNoBoosts            = fmt.Errorf("user has no boosts remaining")
+	ErrBoostActive         = fmt.Errorf("user already has an active boost")
+	ErrBoostNotFound       = fmt.Errorf("user has never boosted")
+)
+
 type Storage interface {
-	GetLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*Decision, error)
-	GetNewLikesForUser(ctx context.Context, userId string, paginationToken int) ([]*Decision, error)
+	GetLikesForUser(ctx context.Context, userId string, paginationToken int, filter LikesFilter) ([]*Decision, error)
+	GetNewLikesForUser(ctx context.Context, userId string, paginationToken int, filter LikesFilter) ([]*Decision, error)
+	// GetLikesCountForUser reads userId's like counter rather than counting their likes
 	GetLikesCountForUser(ctx context.Context, userId string) (int64, error)
+	// AddDecision returns true if recipientId has already liked actorId. It returns ErrDuplicateDecision if actorId
+	// has already made a decision on recipientId and ErrUserNotFound if either user doesn't exist. A like adds one to
+	// recipientId's like counter in the same transaction, anything that removes a like must take one off.
 	AddDecision(ctx context.Context, actorId string, recipientId string, liked bool) (bool, error)
+	// UpdateLocation returns ErrUserNotFound if userId doesn't exist
+	UpdateLocation(ctx context.Context, userId string, latitude float64, longitude float64) error
+	// GetCandidatesForUser returns users that userId hasn't made a decision on and whose preferences are compatible
+	// with userId's in both directions, with boosted users first. A maxDistanceKm of 0 means no limit, otherwise ErrLocationUnknown is returned
+	// if userId has no location.
+	GetCandidatesForUser(ctx context.Context, userId string, maxDistanceKm float64, paginationToken int) ([]*Candidate, error)
+	// UpdateProfile returns ErrUserNotFound if userId doesn't exist
+	UpdateProfile(ctx context.Context, userId string, profile *Profile) error
+	// GetPreferences returns empty Preferences, which match everybody, if userId has never set any
+	GetPreferences(ctx context.Context, userId string) (*Preferences, error)
+	// UpdatePreferences returns ErrUserNotFound if userId doesn't exist
+	UpdatePreferences(ctx context.Context, userId string, preferences *Preferences) error
+	GetQuotaSettings(ctx context.Context, userId string) (*QuotaSettings, error)
+	// GetLikesUsed returns how many likes userId has sent on day, a YYYY-MM-DD date in the user's time zone
+	GetLikesUsed(ctx context.Context, userId string, day string) (int, error)
+	// ConsumeLike atomically adds one to the likes used on day, unless limit has already been reached in which case
+	// it returns false
+	ConsumeLike(ctx context.Context, userId string, day string, limit int) (bool, error)
+	// RefundLike gives back a like taken by ConsumeLike when the decision couldn't be saved
+	RefundLike(ctx context.Context, userId string, day string) error
+	// ListDecisionsAfter returns up to limit decisions with an ID greater than afterId, in ID order. A decision can
+	// become visible after one with a higher ID while its transaction commits, so a cursor shouldn't pass recent ones.
+	ListDecisionsAfter(ctx context.Context, afterId int64, limit int) ([]*Decision, error)
+	// GetScores returns the stored scores of userIds, users who have never been scored are left out
+	GetScores(ctx context.Context, userIds []int64) (map[int64]*Score, error)
+	// SaveScores stores scores along with the ID of the last decision they include, in a single transaction
+	SaveScores(ctx context.Context, scores []*Score, lastDecisionId int64) error
+	// GetScoreCursor returns the ID of the last decision included in the stored scores
+	GetScoreCursor(ctx context.Context) (int64, error)
+	// SaveRecommendations replaces userId's recommendations, which are in order of preference
+	SaveRecommendations(ctx context.Context, userId int64, recommendations []*Recommendation) error
+	// ActivateBoost spends one of the boosts in userId's active entitlement on a boost starting now. It returns
+	// ErrNoBoosts if there are none left and ErrBoostActive, without spending one, if a boost is already running.
+	ActivateBoost(ctx context.Context, userId string, duration time.Duration) (*Boost, error)
+	// GetLatestBoost returns ErrBoostNotFound if userId has never boosted
+	GetLatestBoost(ctx context.Context, userId string) (*Boost, error)
+	// RepairLikeCounters recounts every user's likes from their decisions, fixes the counters that disagree and
+	// returns what they were
+	RepairLikeCounters(ctx context.Context) ([]*LikeCounterDrift, error)
+}
+
+type LikesFilter struct {
+	// MatchPreferences only includes likers who fit the recipient's preferences
+	MatchPreferences bool
 }
 
 type Decision struct {
@@ -20,11 +80,149 @@ type Decision struct {
 	RecipientID int64     `db:"recipient_id"`
 	Liked       bool      `db:"liked"`
 	CreatedAt   time.Time `db:"created_at"`
+	DistanceKm  *float64  `db:"distance_km"`
 }
 
 func (d Decision) ToProto() *protos.ListLikedYouResponse_Liker {
 	return &protos.ListLikedYouResponse_Liker{
 		ActorId:       fmt.Sprintf("%d", d.ActorID),
 		UnixTimestamp: uint64(d.CreatedAt.Unix()),
+		DistanceKm:    approximateDistance(d.DistanceKm),
+	}
+}
+
+type Candidate struct {
+	UserID     int64    `db:"id"`
+	DistanceKm *float64 `db:"distance_km"`
+}
+
+func (c Candidate) ToProto() *protos.ListCandidatesResponse_Candidate {
+	return &protos.ListCandidatesResponse_Candidate{
+		UserId:     fmt.Sprintf("%d", c.UserID),
+		DistanceKm: approximateDistance(c.DistanceKm),
+	}
+}
+
+func approximateDistance(distanceKm *float64) *uint32 {
+	if distanceKm == nil {
+		return nil
+	}
+	approx := geo.ApproximateKm(*distanceKm)
+	return &approx
+}
+
+type Profile struct {
+	Birthdate time.Time     `db:"birthdate"`
+	Gender    protos.Gender `db:"gender"`
+	// TimeZone is an IANA name, empty means UTC
+	TimeZone string `db:"timezone"`
+}
+
+type QuotaSettings struct {
+	TimeZone string `db:"timezone"`
+	// Entitlement is nil unless the user has an active override of the default quota
+	Entitlement *Entitlement
+}
+
+type Entitlement struct {
+	// DailyLikeLimit of nil means unlimited likes
+	DailyLikeLimit *int       `db:"daily_like_limit"`
+	ExpiresAt      *time.Time `db:"expires_at"`
+}
+
+// Score is a user's desirability, along with the decisions they've given which are used to weight their own likes
+type Score struct {
+	UserID         int64     `db:"user_id"`
+	Score          float64   `db:"score"`
+	LikesGiven     int64     `db:"likes_given"`
+	DecisionsGiven int64     `db:"decisions_given"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// LikeCounterDrift is a like counter that didn't match the number of likes a user had received
+type LikeCounterDrift struct {
+	UserID int64 `db:"user_id"`
+	Stored int64 `db:"stored"`
+	Actual int64 `db:"actual"`
+}
+
+// Recommendation is a candidate suggested for a user by the collaborative filter
+type Recommendation struct {
+	CandidateID int64   `db:"candidate_id"`
+	Score       float64 `db:"score"`
+}
+
+type Boost struct {
+	StartsAt time.Time `db:"starts_at"`
+	EndsAt   time.Time `db:"ends_at"`
+	// LikesReceived is the number of likes received between StartsAt and EndsAt
+	LikesReceived int64 `db:"likes_received"`
+}
+
+func (b Boost) ToProto() *protos.Boost {
+	return &protos.Boost{
+		StartUnixTimestamp: uint64(b.StartsAt.Unix()),
+		EndUnixTimestamp:   uint64(b.EndsAt.Unix()),
+		Active:             time.Now().Before(b.EndsAt),
+		LikesReceived:      uint64(b.LikesReceived),
+	}
+}
+
+// GenderSet is a bitmask of protos.Gender values
+type GenderSet uint32
+
+func NewGenderSet(genders ...protos.Gender) GenderSet {
+	var set GenderSet
+	for _, g := range genders {
+		set |= 1 << uint32(g)
+	}
+	return set
+}
+
+func (s GenderSet) Contains(gender protos.Gender) bool {
+	return s&(1<<uint32(gender)) != 0
+}
+
+func (s GenderSet) Genders() []protos.Gender {
+	var genders []protos.Gender
+	for g := range protos.Gender_name {
+		if s.Contains(protos.Gender(g)) {
+			genders = append(genders, protos.Gender(g))
+		}
+	}
+	sort.Slice(genders, func(i, j int) bool { return genders[i] < genders[j] })
+	return genders
+}
+
+// Preferences use 0 for an unbounded age and an empty GenderSet for any gender
+type Preferences struct {
+	MinAge  int                       `db:"min_age"`
+	MaxAge  int                       `db:"max_age"`
+	Genders GenderSet                 `db:"genders"`
+	Intent  protos.RelationshipIntent `db:"intent"`
+}
+
+func PreferencesFromProto(p *protos.Preferences) *Preferences {
+	return &Preferences{
+		MinAge:  int(p.GetMinAge()),
+		MaxAge:  int(p.GetMaxAge()),
+		Genders: NewGenderSet(p.GetGenders()...),
+		Intent:  p.GetIntent(),
+	}
+}
+
+func (p Preferences) ToProto() *protos.Preferences {
+	out := &protos.Preferences{
+		Genders: p.Genders.Genders(),
+		Intent:  p.Intent,
+	}
+	if p.MinAge > 0 {
+		minAge := uint32(p.MinAge)
+		out.MinAge = &minAge
+	}
+	if p.MaxAge > 0 {
+		maxAge := uint32(p.MaxAge)
+		out.MaxAge = &maxAge
 	}
+	return out
 }
@@ -0,0 +1,528 @@
+// Package storagetest is a behavioural test suite for storage.Storage implementations. Every backend runs it from
+// its own tests to show it behaves the same as the others:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T, maxPageSize int, users int) storage.Storage {
+//			...
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"muzz-project/service/protos"
+	"muzz-project/storage"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory returns a new, empty Storage returning pages of up to maxPageSize rows. It must already hold users with
+// the IDs 1 to users and nothing else, and be cleaned up by the factory when t finishes.
+type Factory func(t *testing.T, maxPageSize int, users int) storage.Storage
+
+// Run runs every test in the suite against storages made by newStorage
+func Run(t *testing.T, newStorage Factory) {
+	tests := map[string]func(t *testing.T, newStorage Factory){
+		"AddDecision":            testAddDecision,
+		"LikesOrdering":          testLikesOrdering,
+		"LikesPagination":        testLikesPagination,
+		"NewLikes":               testNewLikes,
+		"Candidates":             testCandidates,
+		"CandidatesPagination":   testCandidatesPagination,
+		"Recommendations":        testRecommendations,
+		"Preferences":            testPreferences,
+		"Quota":                  testQuota,
+		"Scores":                 testScores,
+		"LikeCounters":           testLikeCounters,
+		"ConcurrentDecisions":    testConcurrentDecisions,
+		"ConcurrentDuplicates":   testConcurrentDuplicates,
+		"ConcurrentConsumeLikes": testConcurrentConsumeLikes,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStorage)
+		})
+	}
+}
+
+func id(userId int) string {
+	return fmt.Sprintf("%d", userId)
+}
+
+func addDecision(t *testing.T, s storage.Storage, actorId, recipientId int, liked bool) {
+	t.Helper()
+	if _, err := s.AddDecision(context.Background(), id(actorId), id(recipientId), liked); err != nil {
+		t.Fatalf("AddDecision(%d, %d) failed: %v", actorId, recipientId, err)
+	}
+}
+
+func actorIds(likes []*storage.Decision) []int64 {
+	ids := []int64{}
+	for _, l := range likes {
+		ids = append(ids, l.ActorID)
+	}
+	return ids
+}
+
+func candidateIds(candidates []*storage.Candidate) []int64 {
+	ids := []int64{}
+	for _, c := range candidates {
+		ids = append(ids, c.UserID)
+	}
+	return ids
+}
+
+func testAddDecision(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 4)
+
+	tests := []struct {
+		name        string
+		actorId     string
+		recipientId string
+		liked       bool
+		want        bool
+		wantErr     error
+	}{
+		{name: "first like", actorId: "1", recipientId: "2", liked: true, want: false, wantErr: nil},
+		{name: "users match", actorId: "2", recipientId: "1", liked: true, want: true, wantErr: nil},
+		{name: "pass on a user who liked you", actorId: "3", recipientId: "2", liked: false, want: false, wantErr: nil},
+		{name: "like a user who passed", actorId: "2", recipientId: "3", liked: true, want: false, wantErr: nil},
+		{name: "pass on a user who passed", actorId: "4", recipientId: "3", liked: false, want: false, wantErr: nil},
+		{name: "like a user who hasn't decided", actorId: "4", recipientId: "1", liked: true, want: false, wantErr: nil},
+		{name: "decision already made", actorId: "1", recipientId: "2", liked: false, want: false, wantErr: storage.ErrDuplicateDecision},
+		{name: "unknown recipient", actorId: "1", recipientId: "99", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+		{name: "unknown actor", actorId: "99", recipientId: "1", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+		{name: "invalid actor", actorId: "abc", recipientId: "1", liked: true, want: false, wantErr: storage.ErrUserNotFound},
+	}
+	//Decisions build on each other so run in order
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.AddDecision(ctx, tt.actorId, tt.recipientId, tt.liked)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+
+	//Passes and failed decisions aren't counted
+	for userId, want := range map[string]int64{"1": 2, "2": 1, "3": 1, "4": 0} {
+		count, err := s.GetLikesCountForUser(ctx, userId)
+		assert.NoError(t, err)
+		assert.Equal(t, want, count, "likes for user %s", userId)
+	}
+}
+
+func testLikesOrdering(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 5)
+
+	//Decisions made within the same second are ordered by when they were saved
+	for _, actorId := range []int{3, 5, 2, 4} {
+		addDecision(t, s, actorId, 1, true)
+	}
+
+	likes, err := s.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 2, 5, 3}, actorIds(likes))
+
+	for i := 1; i < len(likes); i++ {
+		assert.False(t, likes[i].CreatedAt.After(likes[i-1].CreatedAt), "likes should be newest first")
+		assert.Equal(t, int64(1), likes[i].RecipientID)
+		assert.True(t, likes[i].Liked)
+	}
+}
+
+func testLikesPagination(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 2, 7)
+
+	for actorId := 2; actorId <= 6; actorId++ {
+		addDecision(t, s, actorId, 1, true)
+	}
+	addDecision(t, s, 7, 1, false)
+
+	tests := map[string]struct {
+		paginationToken int
+		want            []int64
+	}{
+		"first page":          {paginationToken: 0, want: []int64{6, 5}},
+		"second page":         {paginationToken: 2, want: []int64{4, 3}},
+		"last partial page":   {paginationToken: 4, want: []int64{2}},
+		"offset at the end":   {paginationToken: 5, want: []int64{}},
+		"offset past the end": {paginationToken: 100, want: []int64{}},
+		"unaligned offset":    {paginationToken: 1, want: []int64{5, 4}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			likes, err := s.GetLikesForUser(ctx, "1", tt.paginationToken, storage.LikesFilter{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, actorIds(likes))
+		})
+	}
+
+	likes, err := s.GetLikesForUser(ctx, "2", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, likes)
+}
+
+func testNewLikes(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 2, 6)
+
+	for actorId := 2; actorId <= 6; actorId++ {
+		addDecision(t, s, actorId, 1, actorId != 6)
+	}
+	//Likes from users 1 has decided on aren't new, whatever 1 decided
+	addDecision(t, s, 1, 2, true)
+	addDecision(t, s, 1, 4, false)
+
+	firstPage, err := s.GetNewLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 3}, actorIds(firstPage))
+
+	secondPage, err := s.GetNewLikesForUser(ctx, "1", 2, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, secondPage)
+
+	//They're still likes
+	all, err := s.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 4}, actorIds(all))
+}
+
+func testCandidates(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 6)
+
+	locations := map[string][2]float64{
+		"1": {51.5074, -0.1278}, // London
+		"2": {51.5500, -0.1000}, // ~5km away
+		"3": {51.5100, -0.1300}, // <1km away
+		"4": {48.8566, 2.3522},  // Paris
+	}
+	for userId, location := range locations {
+		assert.NoError(t, s.UpdateLocation(ctx, userId, location[0], location[1]))
+	}
+	//Moving to where they already are isn't mistaken for an unknown user
+	assert.NoError(t, s.UpdateLocation(ctx, "1", locations["1"][0], locations["1"][1]))
+	assert.Equal(t, storage.ErrUserNotFound, s.UpdateLocation(ctx, "99", 51.5, -0.1))
+	addDecision(t, s, 1, 6, false)
+	//Decisions on user 1 don't stop them being a candidate
+	addDecision(t, s, 5, 1, true)
+
+	all, err := s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5}, candidateIds(all))
+	if assert.Len(t, all, 4) {
+		if assert.NotNil(t, all[0].DistanceKm) {
+			assert.InDelta(t, 5.1, *all[0].DistanceKm, 0.2)
+		}
+		assert.Nil(t, all[3].DistanceKm)
+	}
+
+	nearby, err := s.GetCandidatesForUser(ctx, "1", 50, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, candidateIds(nearby))
+
+	_, err = s.GetCandidatesForUser(ctx, "5", 50, 0)
+	assert.Equal(t, storage.ErrLocationUnknown, err)
+
+	_, err = s.GetCandidatesForUser(ctx, "99", 0, 0)
+	assert.Equal(t, storage.ErrUserNotFound, err)
+
+	//Compatible in both directions
+	assert.NoError(t, s.UpdateProfile(ctx, "1", &storage.Profile{Birthdate: time.Now().AddDate(-30, 0, -7), Gender: protos.Gender_GENDER_MALE}))
+	assert.NoError(t, s.UpdateProfile(ctx, "2", &storage.Profile{Birthdate: time.Now().AddDate(-25, 0, -7), Gender: protos.Gender_GENDER_FEMALE}))
+	assert.NoError(t, s.UpdateProfile(ctx, "3", &storage.Profile{Birthdate: time.Now().AddDate(-40, 0, -7), Gender: protos.Gender_GENDER_FEMALE}))
+	assert.Equal(t, storage.ErrUserNotFound, s.UpdateProfile(ctx, "99", &storage.Profile{Birthdate: time.Now().AddDate(-30, 0, 0), Gender: protos.Gender_GENDER_MALE}))
+	assert.NoError(t, s.UpdatePreferences(ctx, "1", &storage.Preferences{MaxAge: 35, Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE)}))
+	assert.NoError(t, s.UpdatePreferences(ctx, "2", &storage.Preferences{MinAge: 31}))
+
+	compatible, err := s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, compatible)
+
+	assert.NoError(t, s.UpdatePreferences(ctx, "2", &storage.Preferences{MinAge: 30}))
+	compatible, err = s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, candidateIds(compatible))
+}
+
+func testCandidatesPagination(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 2, 7)
+
+	addDecision(t, s, 1, 3, true)
+
+	tests := map[string]struct {
+		paginationToken int
+		want            []int64
+	}{
+		"first page":          {paginationToken: 0, want: []int64{2, 4}},
+		"second page":         {paginationToken: 2, want: []int64{5, 6}},
+		"last partial page":   {paginationToken: 4, want: []int64{7}},
+		"offset at the end":   {paginationToken: 5, want: []int64{}},
+		"offset past the end": {paginationToken: 100, want: []int64{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			candidates, err := s.GetCandidatesForUser(ctx, "1", 0, tt.paginationToken)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, candidateIds(candidates))
+		})
+	}
+}
+
+func testRecommendations(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 6)
+
+	assert.NoError(t, s.SaveRecommendations(ctx, 1, []*storage.Recommendation{{CandidateID: 6, Score: 2}, {CandidateID: 4, Score: 1}}))
+	candidates, err := s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{6, 4, 2, 3, 5}, candidateIds(candidates))
+
+	//Saving replaces the previous recommendations
+	assert.NoError(t, s.SaveRecommendations(ctx, 1, []*storage.Recommendation{{CandidateID: 5, Score: 1}}))
+	candidates, err = s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 2, 3, 4, 6}, candidateIds(candidates))
+
+	//Only for the user they were made for
+	candidates, err = s.GetCandidatesForUser(ctx, "2", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 3, 4, 5, 6}, candidateIds(candidates))
+
+	assert.NoError(t, s.SaveRecommendations(ctx, 1, nil))
+	candidates, err = s.GetCandidatesForUser(ctx, "1", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5, 6}, candidateIds(candidates))
+}
+
+func testPreferences(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 1)
+
+	preferences, err := s.GetPreferences(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.Preferences{}, preferences)
+
+	want := &storage.Preferences{
+		MinAge:  25,
+		MaxAge:  35,
+		Genders: storage.NewGenderSet(protos.Gender_GENDER_FEMALE, protos.Gender_GENDER_NON_BINARY),
+		Intent:  protos.RelationshipIntent_RELATIONSHIP_INTENT_LONG_TERM,
+	}
+	assert.NoError(t, s.UpdatePreferences(ctx, "1", want))
+	preferences, err = s.GetPreferences(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, want, preferences)
+
+	//Updating replaces every preference
+	assert.NoError(t, s.UpdatePreferences(ctx, "1", &storage.Preferences{MinAge: 30}))
+	preferences, err = s.GetPreferences(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.Preferences{MinAge: 30}, preferences)
+
+	assert.Equal(t, storage.ErrUserNotFound, s.UpdatePreferences(ctx, "99", want))
+}
+
+func testQuota(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 2)
+
+	settings, err := s.GetQuotaSettings(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &storage.QuotaSettings{}, settings)
+
+	_, err = s.GetQuotaSettings(ctx, "99")
+	assert.Equal(t, storage.ErrUserNotFound, err)
+
+	for i := 0; i < 2; i++ {
+		ok, err := s.ConsumeLike(ctx, "1", "2024-06-01", 2)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := s.ConsumeLike(ctx, "1", "2024-06-01", 2)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	//Each day and user has its own count
+	ok, err = s.ConsumeLike(ctx, "1", "2024-06-02", 2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	used, err := s.GetLikesUsed(ctx, "2", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, used)
+
+	assert.NoError(t, s.RefundLike(ctx, "1", "2024-06-01"))
+	used, err = s.GetLikesUsed(ctx, "1", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, used)
+
+	//Refunds never take the count below zero
+	assert.NoError(t, s.RefundLike(ctx, "1", "2024-06-02"))
+	assert.NoError(t, s.RefundLike(ctx, "1", "2024-06-02"))
+	used, err = s.GetLikesUsed(ctx, "1", "2024-06-02")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, used)
+}
+
+func testScores(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 3)
+
+	addDecision(t, s, 1, 2, true)
+	addDecision(t, s, 1, 3, true)
+	addDecision(t, s, 2, 3, false)
+
+	decisions, err := s.ListDecisionsAfter(ctx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, decisions, 3) {
+		assert.Less(t, decisions[0].ID, decisions[1].ID)
+		assert.Less(t, decisions[1].ID, decisions[2].ID)
+
+		after, err := s.ListDecisionsAfter(ctx, decisions[0].ID, 1)
+		assert.NoError(t, err)
+		if assert.Len(t, after, 1) {
+			assert.Equal(t, decisions[1].ID, after[0].ID)
+			assert.Equal(t, int64(1), after[0].ActorID)
+			assert.Equal(t, int64(3), after[0].RecipientID)
+		}
+
+		after, err = s.ListDecisionsAfter(ctx, decisions[2].ID, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, after)
+	}
+
+	cursor, err := s.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), cursor)
+
+	updatedAt := time.Now().UTC().Truncate(time.Second)
+	assert.NoError(t, s.SaveScores(ctx, []*storage.Score{{UserID: 2, Score: 1510, LikesGiven: 0, DecisionsGiven: 1, UpdatedAt: updatedAt}}, 3))
+	assert.NoError(t, s.SaveScores(ctx, []*storage.Score{{UserID: 3, Score: 1520, LikesGiven: 0, DecisionsGiven: 0, UpdatedAt: updatedAt}}, 3))
+
+	cursor, err = s.GetScoreCursor(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), cursor)
+
+	scores, err := s.GetScores(ctx, []int64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Len(t, scores, 2)
+	if assert.Contains(t, scores, int64(2)) {
+		assert.Equal(t, 1510.0, scores[2].Score)
+		assert.Equal(t, int64(1), scores[2].DecisionsGiven)
+		assert.True(t, updatedAt.Equal(scores[2].UpdatedAt))
+	}
+
+	scores, err = s.GetScores(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, scores)
+}
+
+func testLikeCounters(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 3)
+
+	addDecision(t, s, 1, 2, true)
+	addDecision(t, s, 3, 2, true)
+	addDecision(t, s, 2, 1, false)
+
+	//Counters kept up to date by AddDecision never need repairing
+	drifts, err := s.RepairLikeCounters(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	count, err := s.GetLikesCountForUser(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func testConcurrentDecisions(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	const users = 20
+	s := newStorage(t, users, users)
+
+	var wg sync.WaitGroup
+	for actorId := 2; actorId <= users; actorId++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.AddDecision(ctx, id(actorId), "1", true)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	count, err := s.GetLikesCountForUser(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(users-1), count)
+
+	likes, err := s.GetLikesForUser(ctx, "1", 0, storage.LikesFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, likes, users-1)
+}
+
+func testConcurrentDuplicates(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 2)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved, duplicates := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.AddDecision(ctx, "1", "2", i%2 == 0)
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				saved++
+			case storage.ErrDuplicateDecision:
+				duplicates++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, saved)
+	assert.Equal(t, 9, duplicates)
+}
+
+func testConcurrentConsumeLikes(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	s := newStorage(t, 10, 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.ConsumeLike(ctx, "1", "2024-06-01", 5)
+			assert.NoError(t, err)
+			if ok {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, consumed)
+
+	used, err := s.GetLikesUsed(ctx, "1", "2024-06-01")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, used)
+}
@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"context"
+	"muzz-project/service"
+	"muzz-project/service/protos"
+	"muzz-project/storage/instrumented"
+	"muzz-project/storage/mysql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	clientTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	clientSpanID  = "00f067aa0ba902b7"
+)
+
+func newTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func TestInterceptor_UnaryInterceptor(t *testing.T) {
+	tests := map[string]struct {
+		method     string
+		md         metadata.MD
+		err        error
+		wantSpan   bool
+		wantParent bool
+		wantFailed bool
+	}{
+		"continues the client's trace": {
+			method:     "/protos.ExploreService/CountLikedYou",
+			md:         metadata.Pairs("traceparent", "00-"+clientTraceID+"-"+clientSpanID+"-01"),
+			wantSpan:   true,
+			wantParent: true,
+		},
+		"starts a trace": {
+			method:   "/protos.ExploreService/CountLikedYou",
+			wantSpan: true,
+		},
+		"malformed traceparent starts a trace": {
+			method:   "/protos.ExploreService/CountLikedYou",
+			md:       metadata.Pairs("traceparent", "00-nonsense-01"),
+			wantSpan: true,
+		},
+		"refused": {
+			method:   "/protos.ExploreService/CountLikedYou",
+			err:      status.Error(codes.NotFound, "User not found"),
+			wantSpan: true,
+		},
+		"failed": {
+			method:     "/protos.ExploreService/CountLikedYou",
+			err:        status.Error(codes.Internal, "database down"),
+			wantSpan:   true,
+			wantFailed: true,
+		},
+		"health checks aren't traced": {
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tp, exporter := newTestProvider()
+			i := NewInterceptor(tp)
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			var handlerSpan trace.SpanContext
+			_, err := i.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req any) (any, error) {
+				handlerSpan = trace.SpanContextFromContext(ctx)
+				return nil, tt.err
+			})
+			assert.Equal(t, tt.err, err)
+
+			spans := exporter.GetSpans()
+			if !tt.wantSpan {
+				assert.Empty(t, spans)
+				return
+			}
+			assert.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, "protos.ExploreService/CountLikedYou", span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, span.SpanContext, handlerSpan)
+			assert.Subset(t, span.Attributes, []any{
+				semconv.RPCSystemGRPC,
+				semconv.RPCService("protos.ExploreService"),
+				semconv.RPCMethod("CountLikedYou"),
+				semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(tt.err))),
+			})
+			assert.Equal(t, tt.wantParent, span.Parent.IsRemote())
+			if tt.wantParent {
+				assert.Equal(t, clientTraceID, span.SpanContext.TraceID().String())
+				assert.Equal(t, clientSpanID, span.Parent.SpanID().String())
+			}
+			assert.Equal(t, tt.wantFailed, span.Status.Code == otelcodes.Error)
+		})
+	}
+}
+
+// fakeStream receives nothing, it only carries a context
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptor_StreamInterceptor(t *testing.T) {
+	tp, exporter := newTestProvider()
+	i := NewInterceptor(tp)
+	md := metadata.Pairs("traceparent", "00-"+clientTraceID+"-"+clientSpanID+"-01")
+	ss := &fakeStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+	var handlerSpan trace.SpanContext
+	err := i.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: "/protos.ExploreService/Watch"}, func(srv any, stream grpc.ServerStream) error {
+		handlerSpan = trace.SpanContextFromContext(stream.Context())
+		return nil
+	})
+
+	assert.NoError(t, err)
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "protos.ExploreService/Watch", spans[0].Name)
+	assert.Equal(t, spans[0].SpanContext, handlerSpan)
+	assert.Equal(t, clientTraceID, handlerSpan.TraceID().String())
+}
+
+// TestSpanTree follows an RPC through the service and storage down to its SQL statements
+func TestSpanTree(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	mock.ExpectQuery(`SELECT d\.id, .* FROM Decisions d .* NOT EXISTS`).WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "recipient_id", "liked", "created_at", "distance_km"}))
+
+	tp, exporter := newTestProvider()
+	s := instrumented.NewInstrumentedStorage(mysql.NewMysqlStorage(mockDB, 10, mysql.WithTracerProvider(tp)), prometheus.NewRegistry(), instrumented.WithTracerProvider(tp))
+	explore := service.NewExploreService(s, 10)
+	md := metadata.Pairs("traceparent", "00-"+clientTraceID+"-"+clientSpanID+"-01")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	_, err = NewInterceptor(tp).UnaryInterceptor()(ctx, &protos.ListLikedYouRequest{RecipientUserId: "1"},
+		&grpc.UnaryServerInfo{FullMethod: "/protos.ExploreService/ListNewLikedYou"},
+		func(ctx context.Context, req any) (any, error) {
+			return explore.ListNewLikedYou(ctx, req.(*protos.ListLikedYouRequest))
+		})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		byName[span.Name] = span
+	}
+	assert.Len(t, byName, 3)
+	rpc := byName["protos.ExploreService/ListNewLikedYou"]
+	call := byName["storage.GetNewLikesForUser"]
+	statement := byName["SELECT Decisions"]
+
+	assert.Equal(t, clientSpanID, rpc.Parent.SpanID().String())
+	assert.Equal(t, rpc.SpanContext.SpanID(), call.Parent.SpanID())
+	assert.Equal(t, call.SpanContext.SpanID(), statement.Parent.SpanID())
+	for _, span := range []tracetest.SpanStub{rpc, call, statement} {
+		assert.Equal(t, clientTraceID, span.SpanContext.TraceID().String())
+	}
+	assert.Equal(t, trace.SpanKindClient, statement.SpanKind)
+	assert.Contains(t, statement.Attributes, semconv.DBCollectionName("Decisions"))
+}
@@ -0,0 +1,73 @@
+// Package tracing traces requests through the server with OpenTelemetry. Traces started by clients that send a W3C
+// traceparent in their gRPC metadata are continued, and the spans are sent to an exporter chosen by configuration.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporters are the exporters NewExporter accepts
+var Exporters = []string{"none", "stdout", "file", "otlp"}
+
+// NewExporter returns the exporter named kind. stdout and file write spans as JSON to stdout or the end of the file
+// at path. otlp sends them over gRPC to the OpenTelemetry collector at endpoint, e.g. http://collector:4317 with http
+// for a plaintext connection, or where the standard OTEL_EXPORTER_OTLP_ environment variables say if endpoint is
+// empty. none returns nil, as spans don't need exporting.
+func NewExporter(ctx context.Context, kind string, path string, endpoint string) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New()
+	case "file":
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, f: f}, nil
+	case "otlp":
+		var opts []otlptracegrpc.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(endpoint))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q", kind)
+}
+
+// NewProvider returns a tracer provider batching spans to exporter. It samples ratio of the traces started here, and
+// the traces clients have sampled, so a trace is either recorded in full or not at all.
+func NewProvider(service string, exporter sdktrace.SpanExporter, ratio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+}
+
+// fileExporter closes the file spans are written to once they've all been written
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewExporter(t *testing.T) {
+	ctx := context.Background()
+
+	exporter, err := NewExporter(ctx, "none", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = NewExporter(ctx, "jaeger", "", "")
+	assert.EqualError(t, err, `unknown trace exporter "jaeger"`)
+
+	_, err = NewExporter(ctx, "file", filepath.Join(t.TempDir(), "missing", "traces.json"), "")
+	assert.Error(t, err)
+}
+
+func TestNewExporter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	exporter, err := NewExporter(context.Background(), "file", path, "")
+	assert.NoError(t, err)
+
+	tp := NewProvider("explore", exporter, 1)
+	_, span := tp.Tracer("test").Start(context.Background(), "rpc")
+	span.End()
+	assert.NoError(t, tp.Shutdown(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var exported struct {
+		Name     string
+		Resource []struct{ Key string }
+	}
+	assert.NoError(t, json.Unmarshal(contents, &exported))
+	assert.Equal(t, "rpc", exported.Name)
+	assert.Equal(t, "service.name", exported.Resource[0].Key)
+}
+
+func TestNewProvider_Sampling(t *testing.T) {
+	exporter, err := NewExporter(context.Background(), "file", filepath.Join(t.TempDir(), "traces.json"), "")
+	assert.NoError(t, err)
+	tp := NewProvider("explore", exporter, 0)
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "rpc")
+	span.End()
+	assert.False(t, span.SpanContext().IsSampled())
+
+	//Traces the client sampled are sampled whatever the ratio
+	md := metadata.Pairs("traceparent", "00-"+clientTraceID+"-"+clientSpanID+"-01")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx, _ = NewInterceptor(tp).start(ctx, "/protos.ExploreService/CountLikedYou")
+	assert.True(t, trace.SpanContextFromContext(ctx).IsSampled())
+}